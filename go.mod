@@ -25,6 +25,7 @@ require (
 	go.mongodb.org/mongo-driver v1.10.1
 	go.uber.org/zap v1.10.0
 	google.golang.org/grpc v1.31.0
+	gopkg.in/yaml.v2 v2.4.0
 )
 
 require (
@@ -84,7 +85,6 @@ require (
 	golang.org/x/text v0.3.7 // indirect
 	google.golang.org/genproto v0.0.0-20200825200019-8632dd797987 // indirect
 	google.golang.org/protobuf v1.28.0 // indirect
-	gopkg.in/yaml.v2 v2.4.0 // indirect
 )
 
 replace google.golang.org/grpc => google.golang.org/grpc v1.26.0
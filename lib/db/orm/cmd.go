@@ -36,6 +36,18 @@ func printHelp(errs ...string) {
 
     syncdb     - auto create tables
     sqlall     - print sql of create tables
+    migrate    - run versioned migrations:
+                 migrate up [-n steps]      apply pending migrations
+                 migrate down [-n steps]    revert the latest migrations
+                 migrate status             print state of migrations
+                 migrate create [-type go] <name>
+                                            create empty migration files
+                 migrate diff [-write <name>]
+                                            print sql migrating database to models
+                 mysql and tidb commit DDL implicitly, a failed migration may be
+                 left partly applied, keep one DDL statement in each migration
+    models     - generate go models from tables of database:
+                 models [-dir models] [-pkg name] [-tables a,b] [-force]
     help       - print this help
 `
 
@@ -63,7 +75,10 @@ func RunCommand() {
 
 	if cmd, ok := commands[name]; ok {
 		cmd.Parse(os.Args[3:])
-		cmd.Run()
+		if err := cmd.Run(); err != nil {
+			fmt.Println(err.Error())
+			os.Exit(2)
+		}
 		os.Exit(0)
 	} else {
 		if name == "" {
@@ -106,7 +121,7 @@ func (d *commandSyncDb) Run() error {
 	db := d.al.DB
 
 	if d.force {
-		for i, mi := range modelCache.allOrdered() {
+		for i, mi := range modelCache.allOrderedOf(d.al) {
 			query := drops[i]
 			if !d.noInfo {
 				fmt.Printf("drop table `%s`\n", mi.table)
@@ -134,7 +149,7 @@ func (d *commandSyncDb) Run() error {
 		fmt.Printf("    %s\n", err.Error())
 	}
 
-	for i, mi := range modelCache.allOrdered() {
+	for i, mi := range modelCache.allOrderedOf(d.al) {
 		if tables[mi.table] {
 			if !d.noInfo {
 				fmt.Printf("table `%s` already exists, skip\n", mi.table)
@@ -247,7 +262,7 @@ func (d *commandSQLAll) Parse(args []string) {
 func (d *commandSQLAll) Run() error {
 	sqls, indexes := getDbCreateSQL(d.al)
 	var all []string
-	for i, mi := range modelCache.allOrdered() {
+	for i, mi := range modelCache.allOrderedOf(d.al) {
 		queries := []string{sqls[i]}
 		for _, idx := range indexes[mi.table] {
 			queries = append(queries, idx.SQL)
@@ -263,6 +278,8 @@ func (d *commandSQLAll) Run() error {
 func init() {
 	commands["syncdb"] = new(commandSyncDb)
 	commands["sqlall"] = new(commandSQLAll)
+	commands["migrate"] = new(commandMigrate)
+	commands["models"] = new(commandModels)
 }
 
 // RunSyncdb run syncdb command line.
@@ -0,0 +1,201 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// the generated migration fails until it is written.
+const goMigrationTpl = `package %[1]s
+
+import (
+	"errors"
+
+	"github.com/gopherchai/contrib/lib/db/orm"
+)
+
+func init() {
+	orm.RegisterMigration(%[2]s, "%[3]s", func(o orm.Ormer) error {
+		// TODO: apply the migration
+		return errors.New("migration %[2]s_%[3]s.go: up is not written")
+	}, func(o orm.Ormer) error {
+		// TODO: revert the migration
+		return errors.New("migration %[2]s_%[3]s.go: down is not written")
+	})
+}
+`
+
+var migrationNameRe = regexp.MustCompile(`^\w+$`)
+
+// create new migration files in dir.
+// typ is sql or go, up is the statements of the sql up migration.
+func createMigrationFiles(dir, name, typ string, up []string) ([]string, error) {
+	if !migrationNameRe.MatchString(name) {
+		return nil, fmt.Errorf("migration name `%s` can only contain letters, digits and underscores", name)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	version := time.Now().Format("20060102150405")
+	base := filepath.Join(dir, version+"_"+name)
+
+	var files map[string]string
+	switch typ {
+	case "go":
+		pkg := filepath.Base(dir)
+		files = map[string]string{
+			base + ".go": fmt.Sprintf(goMigrationTpl, pkg, version, name),
+		}
+	case "sql":
+		upSQL := "-- write the sql applying the migration\n"
+		if len(up) > 0 {
+			upSQL = strings.Join(up, "\n\n") + "\n"
+		}
+		files = map[string]string{
+			base + ".up.sql":   upSQL,
+			base + ".down.sql": "-- write the sql reverting the migration\n",
+		}
+	default:
+		return nil, fmt.Errorf("unknown migration type `%s`", typ)
+	}
+
+	var created []string
+	for file, content := range files {
+		if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			return created, err
+		}
+		created = append(created, file)
+	}
+	return created, nil
+}
+
+// migrate command interface.
+// each migration runs in a transaction, except the DDL committed implicitly by mysql and tidb.
+type commandMigrate struct {
+	name  string
+	dir   string
+	steps int
+	typ   string
+	write string
+	sub   string
+	arg   string
+}
+
+// parse orm command line arguments.
+func (d *commandMigrate) Parse(args []string) {
+	if len(args) > 0 {
+		d.sub, args = args[0], args[1:]
+	}
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		d.arg, args = args[0], args[1:]
+	}
+
+	flagSet := flag.NewFlagSet("orm command: migrate", flag.ExitOnError)
+	flagSet.StringVar(&d.name, "db", "default", "DataBase alias name")
+	flagSet.StringVar(&d.dir, "dir", "migrations", "directory of migration files")
+	flagSet.IntVar(&d.steps, "n", 0, "number of migrations to apply or revert")
+	flagSet.StringVar(&d.typ, "type", "sql", "type of created migration, sql or go")
+	flagSet.StringVar(&d.write, "write", "", "save the diff as a sql migration with the name")
+	flagSet.Parse(args)
+
+	if d.arg == "" {
+		d.arg = flagSet.Arg(0)
+	}
+}
+
+// run orm line command.
+func (d *commandMigrate) Run() error {
+	switch d.sub {
+	case "up", "down", "status":
+		if _, err := os.Stat(d.dir); err == nil {
+			if err := LoadMigrations(d.dir); err != nil {
+				return err
+			}
+		}
+	}
+
+	switch d.sub {
+	case "up":
+		done, err := MigrateUp(d.name, d.steps)
+		for _, mg := range done {
+			fmt.Printf("apply migration `%d_%s`\n", mg.Version, mg.Name)
+		}
+		if err == nil && len(done) == 0 {
+			fmt.Println("no pending migration")
+		}
+		return err
+	case "down":
+		done, err := MigrateDown(d.name, d.steps)
+		for _, mg := range done {
+			fmt.Printf("revert migration `%d_%s`\n", mg.Version, mg.Name)
+		}
+		if err == nil && len(done) == 0 {
+			fmt.Println("no applied migration")
+		}
+		return err
+	case "status":
+		states, err := MigrationStatus(d.name)
+		if err != nil {
+			return err
+		}
+		for _, st := range states {
+			state := "pending"
+			if st.Applied {
+				state = "applied " + st.AppliedAt.Format(formatDateTime)
+			}
+			fmt.Printf("%-16d %-40s %s\n", st.Version, st.Name, state)
+		}
+		return nil
+	case "create":
+		if d.arg == "" {
+			return fmt.Errorf("migration name is required")
+		}
+		files, err := createMigrationFiles(d.dir, d.arg, d.typ, nil)
+		for _, file := range files {
+			fmt.Printf("create %s\n", file)
+		}
+		return err
+	case "diff":
+		queries, err := MigrationDiff(d.name)
+		if err != nil {
+			return err
+		}
+		if len(queries) == 0 {
+			fmt.Println("database is up to date with models")
+			return nil
+		}
+		if d.write == "" {
+			fmt.Println(strings.Join(queries, "\n\n"))
+			return nil
+		}
+		files, err := createMigrationFiles(d.dir, d.write, "sql", queries)
+		for _, file := range files {
+			fmt.Printf("create %s\n", file)
+		}
+		return err
+	case "":
+		return fmt.Errorf("migrate command needs up, down, status, create or diff")
+	}
+	return fmt.Errorf("unknown migrate command %s", d.sub)
+}
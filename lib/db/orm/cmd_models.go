@@ -0,0 +1,434 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// words kept upper case in generated go names.
+var goInitialisms = map[string]bool{
+	"id":   true,
+	"ip":   true,
+	"url":  true,
+	"uri":  true,
+	"uid":  true,
+	"uuid": true,
+	"api":  true,
+	"http": true,
+	"json": true,
+	"sql":  true,
+}
+
+// methods of generated model, fields can not use these names.
+var modelMethodNames = map[string]bool{
+	"TableName":   true,
+	"TableIndex":  true,
+	"TableUnique": true,
+	"SetID":       true,
+	"GetID":       true,
+}
+
+// convert snake database name to go name, like user_id to UserID.
+func goName(s string) string {
+	var buf strings.Builder
+	for _, part := range strings.Split(s, "_") {
+		if part == "" {
+			continue
+		}
+		if goInitialisms[strings.ToLower(part)] {
+			buf.WriteString(strings.ToUpper(part))
+		} else {
+			buf.WriteString(strings.ToUpper(part[:1]) + part[1:])
+		}
+	}
+	name := buf.String()
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		name = "X" + name
+	}
+	return name
+}
+
+// get go type and orm tags of column.
+func schemaGoType(col *schemaColumn) (string, []string) {
+	typ := col.typ
+	var args []string
+	if i := strings.Index(typ, "("); i >= 0 {
+		if j := strings.Index(typ[i:], ")"); j > 0 {
+			args = strings.Split(typ[i+1:i+j], ",")
+		}
+		typ = typ[:i] + " " + typ[i:]
+	}
+	unsigned := strings.Contains(typ, "unsigned")
+	base := ""
+	if fields := strings.Fields(typ); len(fields) > 0 {
+		base = fields[0]
+	}
+	intType := func(bits string) string {
+		if unsigned {
+			return "uint" + bits
+		}
+		return "int" + bits
+	}
+
+	switch base {
+	case "bool", "boolean":
+		return "bool", nil
+	case "tinyint":
+		if len(args) == 1 && args[0] == "1" {
+			return "bool", nil
+		}
+		return intType("8"), nil
+	case "smallint", "int2":
+		return intType("16"), nil
+	case "mediumint", "int", "integer", "int4", "serial":
+		return intType(""), nil
+	case "bigint", "int8", "bigserial":
+		return intType("64"), nil
+	case "float", "double", "real", "float4", "float8":
+		return "float64", nil
+	case "decimal", "numeric":
+		if len(args) == 2 {
+			return "float64", []string{"digits(" + strings.TrimSpace(args[0]) + ")", "decimals(" + strings.TrimSpace(args[1]) + ")"}
+		}
+		return "float64", nil
+	case "char", "character", "nchar":
+		if len(args) == 1 {
+			return "string", []string{"size(" + args[0] + ")", "type(char)"}
+		}
+		return "string", []string{"type(char)"}
+	case "varchar", "nvarchar", "varchar2":
+		if len(args) == 1 {
+			return "string", []string{"size(" + args[0] + ")"}
+		}
+		return "string", []string{"type(text)"}
+	case "json", "jsonb":
+		return "string", []string{"type(" + base + ")"}
+	case "date":
+		return "time.Time", []string{"type(date)"}
+	case "datetime", "timestamp":
+		return "time.Time", []string{"type(datetime)"}
+	case "time":
+		return "time.Time", []string{"type(time)"}
+	}
+	return "string", []string{"type(text)"}
+}
+
+// get on_delete tag value of foreign key.
+func schemaOnDelete(fk *schemaForeignKey, null bool) string {
+	switch fk.onDelete {
+	case "CASCADE":
+		return ""
+	case "SET NULL":
+		if null {
+			return "set_null"
+		}
+	}
+	return "do_nothing"
+}
+
+// generated field of model.
+type modelField struct {
+	name string
+	typ  string
+	tags []string
+}
+
+// generate go source of model for table schema, schemas are all the generated tables.
+func generateModel(pkg string, schema *tableSchema, schemas map[string]*tableSchema) ([]byte, error) {
+	structName := goName(schema.name)
+	pks := schema.pks()
+
+	fks := make(map[string]*schemaForeignKey, len(schema.foreignKeys))
+	for _, fk := range schema.foreignKeys {
+		fks[fk.column] = fk
+	}
+
+	var (
+		fields   []*modelField
+		columns  = make(map[string]*modelField, len(schema.columns))
+		used     = make(map[string]bool, len(schema.columns))
+		hasTime  bool
+		pkField  *modelField
+		intPk    bool
+		comments []string
+	)
+	uniqueName := func(name, column string) string {
+		if used[name] || modelMethodNames[name] {
+			name = goName(column)
+		}
+		for used[name] || modelMethodNames[name] {
+			name += "Col"
+		}
+		used[name] = true
+		return name
+	}
+
+	for _, col := range schema.columns {
+		field := &modelField{tags: []string{"column(" + col.name + ")"}}
+
+		// foreign key to the single primary key of a generated table
+		fk := fks[col.name]
+		var refPks []*schemaColumn
+		if fk != nil && schemas[fk.refTable] != nil {
+			refPks = schemas[fk.refTable].pks()
+		}
+		if len(refPks) == 1 && (fk.refColumn == "" || fk.refColumn == refPks[0].name) && (!col.pk || len(pks) > 1) {
+			name := col.name
+			if n := len(name) - 3; n > 0 && strings.EqualFold(name[n:], "_id") {
+				name = name[:n]
+			}
+			field.name = uniqueName(goName(name), col.name)
+			field.typ = "*" + goName(fk.refTable)
+			if schema.isUnique(col.name) {
+				field.tags = append(field.tags, "rel(one)")
+			} else {
+				field.tags = append(field.tags, "rel(fk)")
+			}
+			if col.pk {
+				// part of composite primary key
+				field.tags = append(field.tags, "pk")
+			} else if col.null {
+				field.tags = append(field.tags, "null")
+			}
+			if onDelete := schemaOnDelete(fk, col.null); onDelete != "" {
+				field.tags = append(field.tags, "on_delete("+onDelete+")")
+			}
+		} else {
+			typ, tags := schemaGoType(col)
+			field.name = uniqueName(goName(col.name), col.name)
+			field.typ = typ
+			if len(pks) == 1 && col.pk {
+				pkField = field
+				if col.auto && strings.Contains(typ, "int") {
+					// auto pk only support int, int32, int64, uint, uint32 and uint64
+					if strings.HasSuffix(typ, "8") || strings.HasSuffix(typ, "16") {
+						field.typ = strings.TrimRight(typ, "0123456789")
+					}
+					field.tags = append(field.tags, "auto")
+				} else {
+					field.tags = append(field.tags, "pk")
+				}
+				intPk = strings.Contains(field.typ, "int")
+			} else if col.pk {
+				field.tags = append(field.tags, "pk")
+			} else if col.null {
+				field.tags = append(field.tags, "null")
+			}
+			field.tags = append(field.tags, tags...)
+			if typ == "time.Time" {
+				hasTime = true
+			}
+		}
+		fields = append(fields, field)
+		columns[col.name] = field
+	}
+
+	if len(pks) == 0 {
+		comments = append(comments, "// table has no primary key, orm needs pk fields.")
+	}
+
+	var uniques, indexes [][]string
+	for _, idx := range schema.indexes {
+		if len(idx.columns) == 1 {
+			field, ok := columns[idx.columns[0]]
+			if !ok || field == pkField || schema.column(idx.columns[0]).pk {
+				continue
+			}
+			switch {
+			case strings.HasPrefix(field.typ, "*"):
+				// rel(one) is unique already, rel(fk) is indexed by orm
+			case idx.unique:
+				field.tags = append(field.tags, "unique")
+			default:
+				field.tags = append(field.tags, "index")
+			}
+			continue
+		}
+		names := make([]string, 0, len(idx.columns))
+		for _, column := range idx.columns {
+			if field, ok := columns[column]; ok {
+				names = append(names, field.name)
+			}
+		}
+		if idx.unique {
+			uniques = append(uniques, names)
+		} else {
+			indexes = append(indexes, names)
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by orm models command from table `%s`.\n\n", schema.name)
+	fmt.Fprintf(&buf, "package %s\n\n", pkg)
+	if hasTime || intPk {
+		buf.WriteString("import (\n")
+		if hasTime {
+			buf.WriteString("\t\"time\"\n\n")
+		}
+		if intPk {
+			buf.WriteString("\t\"github.com/gopherchai/contrib/lib/model\"\n")
+		}
+		buf.WriteString(")\n\n")
+	}
+
+	for _, c := range comments {
+		buf.WriteString(c + "\n")
+	}
+	fmt.Fprintf(&buf, "type %s struct {\n", structName)
+	for _, field := range fields {
+		fmt.Fprintf(&buf, "\t%s %s `orm:\"%s\"`\n", field.name, field.typ, strings.Join(field.tags, ";"))
+	}
+	buf.WriteString("}\n\n")
+
+	fmt.Fprintf(&buf, "func (m *%s) TableName() string {\n\treturn %q\n}\n", structName, schema.name)
+	writeNames := func(method string, names [][]string) {
+		if len(names) == 0 {
+			return
+		}
+		fmt.Fprintf(&buf, "\nfunc (m *%s) %s() [][]string {\n\treturn [][]string{\n", structName, method)
+		for _, list := range names {
+			quoted := make([]string, len(list))
+			for i, name := range list {
+				quoted[i] = fmt.Sprintf("%q", name)
+			}
+			fmt.Fprintf(&buf, "\t\t{%s},\n", strings.Join(quoted, ", "))
+		}
+		buf.WriteString("\t}\n}\n")
+	}
+	writeNames("TableIndex", indexes)
+	writeNames("TableUnique", uniques)
+
+	if intPk {
+		fmt.Fprintf(&buf, "\nvar _ model.BaseModel = new(%s)\n", structName)
+		fmt.Fprintf(&buf, "\nfunc (m *%s) SetID(id int64) {\n\tm.%s = %s(id)\n}\n", structName, pkField.name, pkField.typ)
+		fmt.Fprintf(&buf, "\nfunc (m *%s) GetID() int64 {\n\treturn int64(m.%s)\n}\n", structName, pkField.name)
+	}
+
+	return format.Source(buf.Bytes())
+}
+
+// generate go models of tables in database of alias, empty tables means all tables.
+// it returns the sources keyed by table name.
+func generateModels(al *alias, pkg string, tables []string) (map[string][]byte, error) {
+	db := al.DB
+	if len(tables) == 0 {
+		all, err := al.DbBaser.GetTables(db)
+		if err != nil {
+			return nil, err
+		}
+		for table := range all {
+			if table == MigrationTable || strings.HasPrefix(table, "sqlite_") {
+				continue
+			}
+			tables = append(tables, table)
+		}
+		sort.Strings(tables)
+	}
+
+	schemas := make(map[string]*tableSchema, len(tables))
+	for _, table := range tables {
+		schema, err := al.DbBaser.GetTableSchema(db, table)
+		if err != nil {
+			return nil, err
+		}
+		if len(schema.columns) == 0 {
+			return nil, fmt.Errorf("table `%s` not found", table)
+		}
+		schemas[table] = schema
+	}
+
+	sources := make(map[string][]byte, len(schemas))
+	for table, schema := range schemas {
+		src, err := generateModel(pkg, schema, schemas)
+		if err != nil {
+			return nil, fmt.Errorf("generate model of table `%s`: %s", table, err.Error())
+		}
+		sources[table] = src
+	}
+	return sources, nil
+}
+
+// models generation command interface.
+type commandModels struct {
+	al     *alias
+	dir    string
+	pkg    string
+	tables string
+	force  bool
+}
+
+// parse orm command line arguments.
+func (d *commandModels) Parse(args []string) {
+	var name string
+
+	flagSet := flag.NewFlagSet("orm command: models", flag.ExitOnError)
+	flagSet.StringVar(&name, "db", "default", "DataBase alias name")
+	flagSet.StringVar(&d.dir, "dir", "models", "directory of generated files")
+	flagSet.StringVar(&d.pkg, "pkg", "", "package name of generated files, default is the name of dir")
+	flagSet.StringVar(&d.tables, "tables", "", "comma separated tables, default is all tables")
+	flagSet.BoolVar(&d.force, "force", false, "overwrite the existing files")
+	flagSet.Parse(args)
+
+	d.al = getDbAlias(name)
+}
+
+// run orm line command.
+func (d *commandModels) Run() error {
+	pkg := d.pkg
+	if pkg == "" {
+		pkg = filepath.Base(d.dir)
+	}
+	var tables []string
+	for _, table := range strings.Split(d.tables, ",") {
+		if table = strings.TrimSpace(table); table != "" {
+			tables = append(tables, table)
+		}
+	}
+
+	sources, err := generateModels(d.al, pkg, tables)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(d.dir, 0755); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(sources))
+	for table := range sources {
+		names = append(names, table)
+	}
+	sort.Strings(names)
+	for _, table := range names {
+		file := filepath.Join(d.dir, table+".go")
+		if _, err := os.Stat(file); err == nil && !d.force {
+			fmt.Printf("file `%s` already exists, skip\n", file)
+			continue
+		}
+		if err := ioutil.WriteFile(file, sources[table], 0644); err != nil {
+			return err
+		}
+		fmt.Printf("create model of table `%s` in %s\n", table, file)
+	}
+	return nil
+}
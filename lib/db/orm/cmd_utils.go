@@ -35,7 +35,7 @@ func getDbDropSQL(al *alias) (sqls []string) {
 
 	Q := al.DbBaser.TableQuote()
 
-	for _, mi := range modelCache.allOrdered() {
+	for _, mi := range modelCache.allOrderedOf(al) {
 		sqls = append(sqls, fmt.Sprintf(`DROP TABLE IF EXISTS %s%s%s`, Q, mi.table, Q))
 	}
 	return sqls
@@ -46,6 +46,10 @@ func getColumnTyp(al *alias, fi *fieldInfo) (col string) {
 	T := al.DbBaser.DbTypes()
 	fieldType := fi.fieldType
 	fieldSize := fi.size
+	if fi.encrypt {
+		// ciphertext is longer than the plaintext
+		fieldType = TypeTextField
+	}
 
 checkColumn:
 	switch fieldType {
@@ -99,12 +103,18 @@ checkColumn:
 	case TypeJSONField:
 		if al.Driver != DRPostgres {
 			fieldType = TypeVarCharField
+			if fi.isJSON {
+				fieldType = TypeTextField
+			}
 			goto checkColumn
 		}
 		col = T["json"]
 	case TypeJsonbField:
 		if al.Driver != DRPostgres {
 			fieldType = TypeVarCharField
+			if fi.isJSON {
+				fieldType = TypeTextField
+			}
 			goto checkColumn
 		}
 		col = T["jsonb"]
@@ -146,7 +156,7 @@ func getDbCreateSQL(al *alias) (sqls []string, tableIndexes map[string][]dbIndex
 
 	tableIndexes = make(map[string][]dbIndex)
 
-	for _, mi := range modelCache.allOrdered() {
+	for _, mi := range modelCache.allOrderedOf(al) {
 		sql := fmt.Sprintf("-- %s\n", strings.Repeat("-", 50))
 		sql += fmt.Sprintf("--  Table Structure for `%s`\n", mi.fullName)
 		sql += fmt.Sprintf("-- %s\n", strings.Repeat("-", 50))
@@ -169,8 +179,11 @@ func getDbCreateSQL(al *alias) (sqls []string, tableIndexes map[string][]dbIndex
 				default:
 					column += col + " " + T["auto"]
 				}
-			} else if fi.pk {
+			} else if fi.pk && len(mi.fields.pks) == 1 {
 				column += col + " " + T["pk"]
+			} else if fi.pk {
+				// composite primary key is added as table constraint
+				column += col + " " + "NOT NULL"
 			} else {
 				column += col
 
@@ -205,6 +218,14 @@ func getDbCreateSQL(al *alias) (sqls []string, tableIndexes map[string][]dbIndex
 			columns = append(columns, column)
 		}
 
+		if len(mi.fields.pks) > 1 {
+			cols := make([]string, 0, len(mi.fields.pks))
+			for _, fi := range mi.fields.pks {
+				cols = append(cols, fi.column)
+			}
+			columns = append(columns, fmt.Sprintf("    PRIMARY KEY (%s%s%s)", Q, strings.Join(cols, sep), Q))
+		}
+
 		if mi.model != nil {
 			allnames := getTableUnique(mi.addrField)
 			if !mi.manual && len(mi.uniques) > 0 {
@@ -59,7 +59,8 @@ var (
 		// "month":       true,
 		// "day":         true,
 		// "week_day":    true,
-		"isnull": true,
+		"isnull":        true,
+		"json_contains": true,
 		// "search":      true,
 	}
 )
@@ -116,15 +117,43 @@ func (d *dbBase) collectValues(mi *modelInfo, ind reflect.Value, cols []string,
 }
 
 // get one field value in struct column as interface.
+// encrypted field is encrypted, blind index field is computed from its encrypted field.
 func (d *dbBase) collectFieldValue(mi *modelInfo, fi *fieldInfo, ind reflect.Value, insert bool, tz *time.Location) (interface{}, error) {
+	if efi := fi.blindIndexOf; efi != nil {
+		value, err := d.collectPlainValue(mi, efi, ind, insert, tz)
+		if err != nil {
+			return nil, err
+		}
+		if value, err = getBlindIndexValue(efi, value); err != nil {
+			return nil, err
+		}
+		if field := ind.FieldByIndex(fi.fieldIndex); value != nil && field.Kind() == reflect.String && field.CanSet() {
+			field.SetString(ToStr(value))
+		}
+		return value, nil
+	}
+	value, err := d.collectPlainValue(mi, fi, ind, insert, tz)
+	if err != nil || !fi.encrypt {
+		return value, err
+	}
+	return getEncryptValue(fi, value)
+}
+
+// get one field value in struct column as interface, the value of encrypted field is plaintext.
+func (d *dbBase) collectPlainValue(mi *modelInfo, fi *fieldInfo, ind reflect.Value, insert bool, tz *time.Location) (interface{}, error) {
 	var value interface{}
 	if fi.pk {
-		_, value, _ = getExistPk(mi, ind)
+		value, _ = getFieldPk(fi, ind)
 	} else {
 		field := ind.FieldByIndex(fi.fieldIndex)
 		if fi.isFielder {
 			f := field.Addr().Interface().(Fielder)
 			value = f.RawValue()
+		} else if fi.isJSON {
+			var err error
+			if value, err = getJSONValue(fi, field); err != nil {
+				return nil, err
+			}
 		} else {
 			switch fi.fieldType {
 			case TypeBooleanField:
@@ -325,12 +354,12 @@ func (d *dbBase) Read(q dbQuerier, mi *modelInfo, ind reflect.Value, tz *time.Lo
 		}
 	} else {
 		// default use pk value as where condtion.
-		pkColumn, pkValue, ok := getExistPk(mi, ind)
+		pkColumns, pkValues, ok := getExistPks(mi, ind)
 		if !ok {
 			return ErrMissPK
 		}
-		whereCols = []string{pkColumn}
-		args = append(args, pkValue)
+		whereCols = pkColumns
+		args = append(args, pkValues...)
 	}
 
 	Q := d.ins.TableQuote()
@@ -481,7 +510,8 @@ func (d *dbBase) InsertValue(q dbQuerier, mi *modelInfo, isMulti bool, names []s
 	if isMulti || !d.ins.HasReturningID(mi, &query) {
 		res, err := q.Exec(query, values...)
 		if err == nil {
-			if isMulti {
+			// composite primary key has no insert id
+			if isMulti || len(mi.fields.pks) > 1 {
 				return res.RowsAffected()
 			}
 			return res.LastInsertId()
@@ -504,11 +534,19 @@ func (d *dbBase) InsertOrUpdate(q dbQuerier, mi *modelInfo, ind reflect.Value, a
 	switch a.Driver {
 	case DRMySQL:
 		iouStr = "ON DUPLICATE KEY UPDATE"
-	case DRPostgres:
-		if len(args) == 0 {
+	case DRPostgres, DRSqlite:
+		if len(args) > 0 {
+			args0 = strings.ToLower(args[0])
+		} else if len(mi.fields.pks) > 1 {
+			// composite primary key is the default conflict columns
+			cols := make([]string, 0, len(mi.fields.pks))
+			for _, fi := range mi.fields.pks {
+				cols = append(cols, fi.column)
+			}
+			args0 = strings.Join(cols, ", ")
+		} else {
 			return 0, fmt.Errorf("`%s` use InsertOrUpdate must have a conflict column", a.DriverName)
 		}
-		args0 = strings.ToLower(args[0])
 		iouStr = fmt.Sprintf("ON CONFLICT (%s) DO UPDATE SET", args0)
 	default:
 		return 0, fmt.Errorf("`%s` nonsupport InsertOrUpdate in beego", a.DriverName)
@@ -535,17 +573,17 @@ func (d *dbBase) InsertOrUpdate(q dbQuerier, mi *modelInfo, ind reflect.Value, a
 	updateValues := make([]interface{}, 0)
 	updates := make([]string, len(names))
 	var conflitValue interface{}
-	for i, v := range names {
+	for i, name := range names {
 		// identifier in database may not be case-sensitive, so quote it
-		v = fmt.Sprintf("%s%s%s", Q, v, Q)
+		v := fmt.Sprintf("%s%s%s", Q, name, Q)
 		marks[i] = "?"
-		valueStr := argsMap[strings.ToLower(v)]
-		if v == args0 {
+		valueStr := argsMap[strings.ToLower(name)]
+		if strings.ToLower(name) == args0 {
 			conflitValue = values[i]
 		}
 		if valueStr != "" {
 			switch a.Driver {
-			case DRMySQL:
+			case DRMySQL, DRSqlite:
 				updates[i] = v + "=" + valueStr
 			case DRPostgres:
 				if conflitValue != nil {
@@ -582,7 +620,8 @@ func (d *dbBase) InsertOrUpdate(q dbQuerier, mi *modelInfo, ind reflect.Value, a
 	if isMulti || !d.ins.HasReturningID(mi, &query) {
 		res, err := q.Exec(query, values...)
 		if err == nil {
-			if isMulti {
+			// composite primary key has no insert id
+			if isMulti || len(mi.fields.pks) > 1 {
 				return res.RowsAffected()
 			}
 			return res.LastInsertId()
@@ -599,9 +638,201 @@ func (d *dbBase) InsertOrUpdate(q dbQuerier, mi *modelInfo, ind reflect.Value, a
 	return id, err
 }
 
+// multi-insert sql with given slice struct reflect.Value,
+// the rows conflicting on conflictCols are updated with updateCols.
+// default updateCols are all inserted columns except conflictCols, pk and auto_now_add columns,
+// auto_now columns are always updated and version column is increased.
+// it returns the affected rows of every bulk.
+func (d *dbBase) InsertMultiOrUpdate(q dbQuerier, mi *modelInfo, sind reflect.Value, bulk int, a *alias, conflictCols, updateCols []string) ([]int64, error) {
+	if bulk < 1 {
+		bulk = 1
+	}
+
+	conflicts, err := getDbColumns(mi, conflictCols)
+	if err != nil {
+		return nil, err
+	}
+	if len(conflicts) == 0 && len(mi.fields.pks) > 1 {
+		// composite primary key is the default conflict columns
+		for _, fi := range mi.fields.pks {
+			conflicts = append(conflicts, fi.column)
+		}
+	}
+	Q := d.ins.TableQuote()
+
+	var iouStr string
+	switch a.Driver {
+	case DRMySQL, DRTiDB:
+		iouStr = "ON DUPLICATE KEY UPDATE"
+	case DRPostgres, DRSqlite:
+		if len(conflicts) == 0 {
+			return nil, fmt.Errorf("`%s` use InsertMultiOrUpdate must have conflict columns", a.DriverName)
+		}
+		iouStr = fmt.Sprintf("ON CONFLICT (%s%s%s) DO UPDATE SET", Q, strings.Join(conflicts, Q+", "+Q), Q)
+	default:
+		return nil, fmt.Errorf("`%s` nonsupport InsertMultiOrUpdate in beego", a.DriverName)
+	}
+
+	var (
+		cnts       []int64
+		nums       int
+		values     []interface{}
+		names      []string
+		autoFields []string
+		query      string
+	)
+
+	length := sind.Len()
+	for i := 1; i <= length; i++ {
+		ind := reflect.Indirect(sind.Index(i - 1))
+
+		if i == 1 {
+			var vus []interface{}
+			vus, autoFields, err = d.collectValues(mi, ind, mi.fields.dbcols, false, true, &names, a.TZ)
+			if err != nil {
+				return cnts, err
+			}
+			values = make([]interface{}, bulk*len(vus))
+			nums += copy(values, vus)
+
+			updates, err := d.getUpsertSets(mi, a, names, conflicts, updateCols)
+			if err != nil {
+				return cnts, err
+			}
+			if len(updates) == 0 {
+				// nothing to update, keep the conflicting rows
+				if a.Driver == DRMySQL || a.Driver == DRTiDB {
+					updates = []string{fmt.Sprintf("%s%s%s = %s%s%s", Q, names[0], Q, Q, names[0], Q)}
+				} else {
+					iouStr = strings.TrimSuffix(iouStr, "UPDATE SET") + "NOTHING"
+				}
+			}
+			query = iouStr + " " + strings.Join(updates, ", ")
+		} else {
+			vus, _, err := d.collectValues(mi, ind, mi.fields.dbcols, false, true, nil, a.TZ)
+			if err != nil {
+				return cnts, err
+			}
+
+			if len(vus) != len(names) {
+				return cnts, ErrArgs
+			}
+
+			nums += copy(values[nums:], vus)
+		}
+
+		if i%bulk == 0 || length == i {
+			marks := make([]string, len(names))
+			for j := range marks {
+				marks[j] = "?"
+			}
+			qmarks := strings.Join(marks, ", ")
+			qmarks = strings.Repeat(qmarks+"), (", nums/len(names)-1) + qmarks
+
+			sep := fmt.Sprintf("%s, %s", Q, Q)
+			columns := strings.Join(names, sep)
+			iouQuery := fmt.Sprintf("INSERT INTO %s%s%s (%s%s%s) VALUES (%s) %s", Q, mi.table, Q, Q, columns, Q, qmarks, query)
+
+			d.ins.ReplaceMarks(&iouQuery)
+
+			res, err := q.Exec(iouQuery, values[:nums]...)
+			if err != nil {
+				return cnts, err
+			}
+			num, err := res.RowsAffected()
+			if err != nil {
+				return cnts, err
+			}
+			cnts = append(cnts, num)
+			nums = 0
+		}
+	}
+
+	if len(autoFields) > 0 {
+		err = d.ins.setval(q, mi, autoFields)
+	}
+
+	return cnts, err
+}
+
+// get the SET expressions of upsert for the inserted columns names.
+func (d *dbBase) getUpsertSets(mi *modelInfo, a *alias, names, conflicts, updateCols []string) ([]string, error) {
+	Q := d.ins.TableQuote()
+
+	inserted := make(map[string]bool, len(names))
+	for _, name := range names {
+		inserted[name] = true
+	}
+
+	var cols []string
+	if len(updateCols) > 0 {
+		var err error
+		if cols, err = getDbColumns(mi, updateCols); err != nil {
+			return nil, err
+		}
+	} else {
+	loopNames:
+		for _, name := range names {
+			fi := mi.fields.GetByColumn(name)
+			if fi.pk || fi.autoNowAdd {
+				continue
+			}
+			for _, col := range conflicts {
+				if col == name {
+					continue loopNames
+				}
+			}
+			cols = append(cols, name)
+		}
+	}
+	for _, fi := range mi.fields.fieldsDB {
+		if fi.autoNow && inserted[fi.column] {
+			cols = append(cols, fi.column)
+		}
+	}
+
+	sets := make([]string, 0, len(cols)+1)
+	seen := make(map[string]bool, len(cols))
+	for _, col := range cols {
+		if seen[col] || !inserted[col] || mi.fields.version != nil && col == mi.fields.version.column {
+			continue
+		}
+		seen[col] = true
+		switch a.Driver {
+		case DRMySQL, DRTiDB:
+			sets = append(sets, fmt.Sprintf("%s%s%s = VALUES(%s%s%s)", Q, col, Q, Q, col, Q))
+		default:
+			sets = append(sets, fmt.Sprintf("%s%s%s = EXCLUDED.%s%s%s", Q, col, Q, Q, col, Q))
+		}
+	}
+
+	if fi := mi.fields.version; fi != nil && len(sets) > 0 {
+		switch a.Driver {
+		case DRMySQL, DRTiDB:
+			sets = append(sets, fmt.Sprintf("%s%s%s = %s%s%s + 1", Q, fi.column, Q, Q, fi.column, Q))
+		default:
+			sets = append(sets, fmt.Sprintf("%s%s%s = %s%s%s.%s%s%s + 1", Q, fi.column, Q, Q, mi.table, Q, Q, fi.column, Q))
+		}
+	}
+	return sets, nil
+}
+
+// get the columns of field names or columns of model.
+func getDbColumns(mi *modelInfo, cols []string) ([]string, error) {
+	columns := make([]string, 0, len(cols))
+	for _, col := range cols {
+		fi, ok := mi.fields.GetByAny(col)
+		if !ok || !fi.dbcol {
+			return nil, fmt.Errorf("wrong db field/column name `%s` for model `%s`", col, mi.fullName)
+		}
+		columns = append(columns, fi.column)
+	}
+	return columns, nil
+}
+
 // execute update sql dbQuerier with given struct reflect.Value.
 func (d *dbBase) Update(q dbQuerier, mi *modelInfo, ind reflect.Value, tz *time.Location, cols []string) (int64, error) {
-	pkName, pkValue, ok := getExistPk(mi, ind)
+	pkNames, pkValues, ok := getExistPks(mi, ind)
 	if !ok {
 		return 0, ErrMissPK
 	}
@@ -637,27 +868,91 @@ func (d *dbBase) Update(q dbQuerier, mi *modelInfo, ind reflect.Value, tz *time.
 		setNames = make([]string, 0, len(cols))
 	}
 
+	// version column is never set directly, it is increased by the update itself.
+	vfi := mi.fields.version
+	if vfi != nil {
+		resCols := make([]string, 0, len(cols))
+		for _, col := range cols {
+			if fi, ok := mi.fields.GetByAny(col); ok && fi == vfi {
+				continue
+			}
+			resCols = append(resCols, col)
+		}
+		cols = resCols
+	}
+
+	// blind index is updated with its encrypted field
+	for _, col := range cols {
+		if fi, ok := mi.fields.GetByAny(col); ok && fi.blindIndex != nil {
+			found := false
+			for _, c := range cols {
+				if bfi, ok := mi.fields.GetByAny(c); ok && bfi == fi.blindIndex {
+					found = true
+					break
+				}
+			}
+			if !found {
+				cols = append(cols, fi.blindIndex.column)
+			}
+		}
+	}
+
 	setValues, _, err := d.collectValues(mi, ind, cols, true, false, &setNames, tz)
 	if err != nil {
 		return 0, err
 	}
 
-	setValues = append(setValues, pkValue)
-
 	Q := d.ins.TableQuote()
 
-	sep := fmt.Sprintf("%s = ?, %s", Q, Q)
-	setColumns := strings.Join(setNames, sep)
+	sets := make([]string, 0, len(setNames)+1)
+	for _, name := range setNames {
+		sets = append(sets, fmt.Sprintf("%s%s%s = ?", Q, name, Q))
+	}
+	sep := fmt.Sprintf("%s = ? AND %s", Q, Q)
+	where := fmt.Sprintf("%s%s%s = ?", Q, strings.Join(pkNames, sep), Q)
+	setValues = append(setValues, pkValues...)
+
+	pkWhere := where
+	var version reflect.Value
+	if vfi != nil {
+		version = ind.FieldByIndex(vfi.fieldIndex)
+		sets = append(sets, fmt.Sprintf("%s%s%s = %s%s%s + 1", Q, vfi.column, Q, Q, vfi.column, Q))
+		where += fmt.Sprintf(" AND %s%s%s = ?", Q, vfi.column, Q)
+		setValues = append(setValues, version.Interface())
+	}
 
-	query := fmt.Sprintf("UPDATE %s%s%s SET %s%s%s = ? WHERE %s%s%s = ?", Q, mi.table, Q, Q, setColumns, Q, Q, pkName, Q)
+	query := fmt.Sprintf("UPDATE %s%s%s SET %s WHERE %s", Q, mi.table, Q, strings.Join(sets, ", "), where)
 
 	d.ins.ReplaceMarks(&query)
 
 	res, err := q.Exec(query, setValues...)
-	if err == nil {
-		return res.RowsAffected()
+	if err != nil {
+		return 0, err
 	}
-	return 0, err
+	num, err := res.RowsAffected()
+	if err != nil || vfi == nil {
+		return num, err
+	}
+	if num == 0 {
+		// the version is stale only if the row exists
+		query = fmt.Sprintf("SELECT COUNT(*) FROM %s%s%s WHERE %s", Q, mi.table, Q, pkWhere)
+		d.ins.ReplaceMarks(&query)
+		var cnt int64
+		if err := q.QueryRow(query, pkValues...).Scan(&cnt); err != nil {
+			return 0, err
+		}
+		if cnt > 0 {
+			return 0, ErrStaleObject
+		}
+		return 0, nil
+	}
+	switch version.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		version.SetUint(version.Uint() + 1)
+	default:
+		version.SetInt(version.Int() + 1)
+	}
+	return num, nil
 }
 
 // execute delete sql dbQuerier with given struct reflect.Value.
@@ -675,12 +970,12 @@ func (d *dbBase) Delete(q dbQuerier, mi *modelInfo, ind reflect.Value, tz *time.
 		}
 	} else {
 		// default use pk value as where condtion.
-		pkColumn, pkValue, ok := getExistPk(mi, ind)
+		pkColumns, pkValues, ok := getExistPks(mi, ind)
 		if !ok {
 			return 0, ErrMissPK
 		}
-		whereCols = []string{pkColumn}
-		args = append(args, pkValue)
+		whereCols = pkColumns
+		args = append(args, pkValues...)
 	}
 
 	Q := d.ins.TableQuote()
@@ -723,6 +1018,24 @@ func (d *dbBase) UpdateBatch(q dbQuerier, qs *querySet, mi *modelInfo, cond *Con
 	for col, val := range params {
 		if fi, ok := mi.fields.GetByAny(col); !ok || !fi.dbcol {
 			panic(fmt.Errorf("wrong field/column name `%s`", col))
+		} else if fi.encrypt {
+			if _, ok := val.(colValue); ok {
+				panic(fmt.Errorf("encrypted field `%s` cannot use ColValue", fi.fullName))
+			}
+			value, err := getEncryptValue(fi, val)
+			if err != nil {
+				return 0, err
+			}
+			columns = append(columns, fi.column)
+			values = append(values, value)
+			if fi.blindIndex != nil {
+				index, err := getBlindIndexValue(fi, val)
+				if err != nil {
+					return 0, err
+				}
+				columns = append(columns, fi.blindIndex.column)
+				values = append(values, index)
+			}
 		} else {
 			columns = append(columns, fi.column)
 			values = append(values, val)
@@ -735,6 +1048,7 @@ func (d *dbBase) UpdateBatch(q dbQuerier, qs *querySet, mi *modelInfo, cond *Con
 
 	tables := newDbTables(mi, d.ins)
 	if qs != nil {
+		tables.tenant = qs.orm.currentTenant()
 		tables.parseRelated(qs.related, qs.relDepth)
 	}
 
@@ -778,8 +1092,18 @@ func (d *dbBase) UpdateBatch(q dbQuerier, qs *querySet, mi *modelInfo, cond *Con
 	if d.ins.SupportUpdateJoin() {
 		query = fmt.Sprintf("UPDATE %s%s%s T0 %sSET %s%s", Q, mi.table, Q, join, sets, where)
 	} else {
-		supQuery := fmt.Sprintf("SELECT T0.%s%s%s FROM %s%s%s T0 %s%s", Q, mi.fields.pk.column, Q, Q, mi.table, Q, join, where)
-		query = fmt.Sprintf("UPDATE %s%s%s SET %sWHERE %s%s%s IN ( %s )", Q, mi.table, Q, sets, Q, mi.fields.pk.column, Q, supQuery)
+		pkCols := make([]string, 0, len(mi.fields.pks))
+		for _, fi := range mi.fields.pks {
+			pkCols = append(pkCols, fi.column)
+		}
+		sep := fmt.Sprintf("%s, %s", Q, Q)
+		pks := fmt.Sprintf("%s%s%s", Q, strings.Join(pkCols, sep), Q)
+		supQuery := fmt.Sprintf("SELECT T0.%s%s%s FROM %s%s%s T0 %s%s", Q, strings.Join(pkCols, fmt.Sprintf("%s, T0.%s", Q, Q)), Q, Q, mi.table, Q, join, where)
+		if len(pkCols) > 1 {
+			// composite primary key is compared by row value
+			pks = "(" + pks + ")"
+		}
+		query = fmt.Sprintf("UPDATE %s%s%s SET %sWHERE %s IN ( %s )", Q, mi.table, Q, sets, pks, supQuery)
 	}
 
 	d.ins.ReplaceMarks(&query)
@@ -830,6 +1154,7 @@ func (d *dbBase) DeleteBatch(q dbQuerier, qs *querySet, mi *modelInfo, cond *Con
 	tables.skipEnd = true
 
 	if qs != nil {
+		tables.tenant = qs.orm.currentTenant()
 		tables.parseRelated(qs.related, qs.relDepth)
 	}
 
@@ -842,7 +1167,12 @@ func (d *dbBase) DeleteBatch(q dbQuerier, qs *querySet, mi *modelInfo, cond *Con
 	where, args := tables.getCondSQL(cond, false, tz)
 	join := tables.getJoinSQL()
 
-	cols := fmt.Sprintf("T0.%s%s%s", Q, mi.fields.pk.column, Q)
+	pks := mi.fields.pks
+	pkCols := make([]string, 0, len(pks))
+	for _, fi := range pks {
+		pkCols = append(pkCols, fi.column)
+	}
+	cols := fmt.Sprintf("T0.%s%s%s", Q, strings.Join(pkCols, fmt.Sprintf("%s, T0.%s", Q, Q)), Q)
 	query := fmt.Sprintf("SELECT %s FROM %s%s%s T0 %s%s", cols, Q, mi.table, Q, join, where)
 
 	d.ins.ReplaceMarks(&query)
@@ -855,18 +1185,24 @@ func (d *dbBase) DeleteBatch(q dbQuerier, qs *querySet, mi *modelInfo, cond *Con
 	rs = r
 	defer rs.Close()
 
-	var ref interface{}
+	refs := make([]interface{}, len(pks))
+	for i := range refs {
+		var ref interface{}
+		refs[i] = &ref
+	}
 	args = make([]interface{}, 0)
 	cnt := 0
 	for rs.Next() {
-		if err := rs.Scan(&ref); err != nil {
+		if err := rs.Scan(refs...); err != nil {
 			return 0, err
 		}
-		pkValue, err := d.convertValueFromDB(mi.fields.pk, reflect.ValueOf(ref).Interface(), tz)
-		if err != nil {
-			return 0, err
+		for i, fi := range pks {
+			pkValue, err := d.convertValueFromDB(fi, reflect.Indirect(reflect.ValueOf(refs[i])).Interface(), tz)
+			if err != nil {
+				return 0, err
+			}
+			args = append(args, pkValue)
 		}
-		args = append(args, pkValue)
 		cnt++
 	}
 
@@ -874,12 +1210,23 @@ func (d *dbBase) DeleteBatch(q dbQuerier, qs *querySet, mi *modelInfo, cond *Con
 		return 0, nil
 	}
 
-	marks := make([]string, len(args))
-	for i := range marks {
-		marks[i] = "?"
+	if len(pks) == 1 {
+		marks := make([]string, len(args))
+		for i := range marks {
+			marks[i] = "?"
+		}
+		sqlIn := fmt.Sprintf("IN (%s)", strings.Join(marks, ", "))
+		query = fmt.Sprintf("DELETE FROM %s%s%s WHERE %s%s%s %s", Q, mi.table, Q, Q, mi.fields.pk.column, Q, sqlIn)
+	} else {
+		// composite primary key rows are matched one by one
+		sep := fmt.Sprintf("%s = ? AND %s", Q, Q)
+		row := fmt.Sprintf("(%s%s%s = ?)", Q, strings.Join(pkCols, sep), Q)
+		rows := make([]string, cnt)
+		for i := range rows {
+			rows[i] = row
+		}
+		query = fmt.Sprintf("DELETE FROM %s%s%s WHERE %s", Q, mi.table, Q, strings.Join(rows, " OR "))
 	}
-	sqlIn := fmt.Sprintf("IN (%s)", strings.Join(marks, ", "))
-	query = fmt.Sprintf("DELETE FROM %s%s%s WHERE %s%s%s %s", Q, mi.table, Q, Q, mi.fields.pk.column, Q, sqlIn)
 
 	d.ins.ReplaceMarks(&query)
 	var res sql.Result
@@ -893,7 +1240,7 @@ func (d *dbBase) DeleteBatch(q dbQuerier, qs *querySet, mi *modelInfo, cond *Con
 		if err != nil {
 			return 0, err
 		}
-		if num > 0 {
+		if num > 0 && len(pks) == 1 {
 			err := d.deleteRels(q, mi, args, tz)
 			if err != nil {
 				return num, err
@@ -940,6 +1287,86 @@ func (d *dbBase) ReadBatch(q dbQuerier, qs *querySet, mi *modelInfo, cond *Condi
 		}
 	}
 
+	query, args, tCols, tables, colsNum, err := d.readBatchQuery(qs, mi, cond, tz, cols)
+	if err != nil {
+		return 0, err
+	}
+
+	var rs *sql.Rows
+	if qs != nil && qs.forContext {
+		rs, err = q.QueryContext(qs.ctx, query, args...)
+		if err != nil {
+			return 0, err
+		}
+	} else {
+		rs, err = q.Query(query, args...)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	refs := make([]interface{}, colsNum)
+	for i := range refs {
+		var ref interface{}
+		refs[i] = &ref
+	}
+
+	defer rs.Close()
+
+	slice := ind
+
+	var cnt int64
+	for rs.Next() {
+		if one && cnt == 0 || !one {
+			if err := rs.Scan(refs...); err != nil {
+				return 0, err
+			}
+
+			elm := reflect.New(mi.addrField.Elem().Type())
+			mind := reflect.Indirect(elm)
+			d.setRowValues(mi, &mind, tCols, tables, refs, tz)
+
+			if one {
+				ind.Set(mind)
+			} else {
+				if cnt == 0 {
+					// you can use a empty & caped container list
+					// orm will not replace it
+					if ind.Len() != 0 {
+						// if container is not empty
+						// create a new one
+						slice = reflect.New(ind.Type()).Elem()
+					}
+				}
+
+				if isPtr {
+					slice = reflect.Append(slice, mind.Addr())
+				} else {
+					slice = reflect.Append(slice, mind)
+				}
+			}
+		}
+		cnt++
+	}
+
+	if !one {
+		if cnt > 0 {
+			ind.Set(slice)
+		} else {
+			// when a result is empty and container is nil
+			// to set a empty container
+			if ind.IsNil() {
+				ind.Set(reflect.MakeSlice(ind.Type(), 0, 0))
+			}
+		}
+	}
+
+	return cnt, nil
+}
+
+// create the select sql of ReadBatch.
+// it returns the selected columns of model, the tables with related models and the number of all selected columns.
+func (d *dbBase) readBatchQuery(qs *querySet, mi *modelInfo, cond *Condition, tz *time.Location, cols []string) (string, []interface{}, []string, *dbTables, int, error) {
 	rlimit := qs.limit
 	offset := qs.offset
 
@@ -960,7 +1387,7 @@ func (d *dbBase) ReadBatch(q dbQuerier, qs *querySet, mi *modelInfo, cond *Condi
 					maps[fi.column] = true
 				}
 			} else {
-				return 0, fmt.Errorf("wrong field/column name `%s`", col)
+				return "", nil, nil, nil, 0, fmt.Errorf("wrong field/column name `%s`", col)
 			}
 		}
 		if hasRel {
@@ -981,6 +1408,7 @@ func (d *dbBase) ReadBatch(q dbQuerier, qs *querySet, mi *modelInfo, cond *Condi
 	sels := fmt.Sprintf("T0.%s%s%s", Q, strings.Join(tCols, sep), Q)
 
 	tables := newDbTables(mi, d.ins)
+	tables.tenant = qs.orm.currentTenant()
 	tables.parseRelated(qs.related, qs.relDepth)
 
 	where, args := tables.getCondSQL(cond, false, tz)
@@ -1009,132 +1437,71 @@ func (d *dbBase) ReadBatch(q dbQuerier, qs *querySet, mi *modelInfo, cond *Condi
 
 	d.ins.ReplaceMarks(&query)
 
-	var rs *sql.Rows
-	var err error
-	if qs != nil && qs.forContext {
-		rs, err = q.QueryContext(qs.ctx, query, args...)
-		if err != nil {
-			return 0, err
-		}
-	} else {
-		rs, err = q.Query(query, args...)
-		if err != nil {
-			return 0, err
-		}
-	}
-
-	refs := make([]interface{}, colsNum)
-	for i := range refs {
-		var ref interface{}
-		refs[i] = &ref
-	}
+	return query, args, tCols, tables, colsNum, nil
+}
 
-	defer rs.Close()
+// set the values of scanned row refs to model mind and the selected related models.
+func (d *dbBase) setRowValues(mi *modelInfo, mind *reflect.Value, tCols []string, tables *dbTables, refs []interface{}, tz *time.Location) {
+	cacheV := make(map[string]*reflect.Value)
+	cacheM := make(map[string]*modelInfo)
+	trefs := refs
 
-	slice := ind
+	d.setColsValues(mi, mind, tCols, refs[:len(tCols)], tz)
+	trefs = refs[len(tCols):]
 
-	var cnt int64
-	for rs.Next() {
-		if one && cnt == 0 || !one {
-			if err := rs.Scan(refs...); err != nil {
-				return 0, err
-			}
-
-			elm := reflect.New(mi.addrField.Elem().Type())
-			mind := reflect.Indirect(elm)
-
-			cacheV := make(map[string]*reflect.Value)
-			cacheM := make(map[string]*modelInfo)
-			trefs := refs
-
-			d.setColsValues(mi, &mind, tCols, refs[:len(tCols)], tz)
-			trefs = refs[len(tCols):]
-
-			for _, tbl := range tables.tables {
-				// loop selected tables
-				if tbl.sel {
-					last := mind
-					names := ""
-					mmi := mi
-					// loop cascade models
-					for _, name := range tbl.names {
-						names += name
-						if val, ok := cacheV[names]; ok {
-							last = *val
-							mmi = cacheM[names]
-						} else {
-							fi := mmi.fields.GetByName(name)
-							lastm := mmi
-							mmi = fi.relModelInfo
-							field := last
-							if last.Kind() != reflect.Invalid {
-								field = reflect.Indirect(last.FieldByIndex(fi.fieldIndex))
-								if field.IsValid() {
-									d.setColsValues(mmi, &field, mmi.fields.dbcols, trefs[:len(mmi.fields.dbcols)], tz)
-									for _, fi := range mmi.fields.fieldsReverse {
-										if fi.inModel && fi.reverseFieldInfo.mi == lastm {
-											if fi.reverseFieldInfo != nil {
-												f := field.FieldByIndex(fi.fieldIndex)
-												if f.Kind() == reflect.Ptr {
-													f.Set(last.Addr())
-												}
-											}
+	for _, tbl := range tables.tables {
+		// loop selected tables
+		if tbl.sel {
+			last := *mind
+			names := ""
+			mmi := mi
+			// loop cascade models
+			for _, name := range tbl.names {
+				names += name
+				if val, ok := cacheV[names]; ok {
+					last = *val
+					mmi = cacheM[names]
+				} else {
+					fi := mmi.fields.GetByName(name)
+					lastm := mmi
+					mmi = fi.relModelInfo
+					field := last
+					if last.Kind() != reflect.Invalid {
+						field = reflect.Indirect(last.FieldByIndex(fi.fieldIndex))
+						if field.IsValid() {
+							d.setColsValues(mmi, &field, mmi.fields.dbcols, trefs[:len(mmi.fields.dbcols)], tz)
+							for _, fi := range mmi.fields.fieldsReverse {
+								if fi.inModel && fi.reverseFieldInfo.mi == lastm {
+									if fi.reverseFieldInfo != nil {
+										f := field.FieldByIndex(fi.fieldIndex)
+										if f.Kind() == reflect.Ptr {
+											f.Set(last.Addr())
 										}
 									}
-									last = field
 								}
 							}
-							cacheV[names] = &field
-							cacheM[names] = mmi
+							last = field
 						}
 					}
-					trefs = trefs[len(mmi.fields.dbcols):]
-				}
-			}
-
-			if one {
-				ind.Set(mind)
-			} else {
-				if cnt == 0 {
-					// you can use a empty & caped container list
-					// orm will not replace it
-					if ind.Len() != 0 {
-						// if container is not empty
-						// create a new one
-						slice = reflect.New(ind.Type()).Elem()
-					}
+					cacheV[names] = &field
+					cacheM[names] = mmi
 				}
-
-				if isPtr {
-					slice = reflect.Append(slice, mind.Addr())
-				} else {
-					slice = reflect.Append(slice, mind)
-				}
-			}
-		}
-		cnt++
-	}
-
-	if !one {
-		if cnt > 0 {
-			ind.Set(slice)
-		} else {
-			// when a result is empty and container is nil
-			// to set a empty container
-			if ind.IsNil() {
-				ind.Set(reflect.MakeSlice(ind.Type(), 0, 0))
 			}
+			trefs = trefs[len(mmi.fields.dbcols):]
 		}
 	}
-
-	return cnt, nil
 }
 
 // excute count sql and return count result int64.
 func (d *dbBase) Count(q dbQuerier, qs *querySet, mi *modelInfo, cond *Condition, tz *time.Location) (cnt int64, err error) {
 	tables := newDbTables(mi, d.ins)
+	tables.tenant = qs.orm.currentTenant()
 	tables.parseRelated(qs.related, qs.relDepth)
 
+	for _, agg := range qs.aggregates {
+		tables.aliases = append(tables.aliases, agg.alias)
+	}
+
 	where, args := tables.getCondSQL(cond, false, tz)
 	groupBy := tables.getGroupSQL(qs.groups)
 	tables.getOrderSQL(qs.orders)
@@ -1253,6 +1620,13 @@ func (d *dbBase) convertValueFromDB(fi *fieldInfo, val interface{}, tz *time.Loc
 		return nil, nil
 	}
 
+	if fi.encrypt {
+		var err error
+		if val, err = getDecryptValue(fi, val); err != nil {
+			return nil, err
+		}
+	}
+
 	var value interface{}
 	var tErr error
 
@@ -1411,6 +1785,10 @@ func (d *dbBase) setFieldValue(fi *fieldInfo, value interface{}, field reflect.V
 	fieldType := fi.fieldType
 	isNative := !fi.isFielder
 
+	if fi.isJSON {
+		return value, setJSONValue(fi, value, field)
+	}
+
 setValue:
 	switch {
 	case fieldType == TypeBooleanField:
@@ -1631,6 +2009,7 @@ func (d *dbBase) ReadValues(q dbQuerier, qs *querySet, mi *modelInfo, cond *Cond
 	}
 
 	tables := newDbTables(mi, d.ins)
+	tables.tenant = qs.orm.currentTenant()
 
 	var (
 		cols  []string
@@ -1638,6 +2017,11 @@ func (d *dbBase) ReadValues(q dbQuerier, qs *querySet, mi *modelInfo, cond *Cond
 	)
 
 	hasExprs := len(exprs) > 0
+	if !hasExprs && len(qs.aggregates) > 0 {
+		// only the group fields are selected with the aggregates
+		exprs = qs.groups
+		hasExprs = true
+	}
 
 	Q := d.ins.TableQuote()
 
@@ -1661,6 +2045,13 @@ func (d *dbBase) ReadValues(q dbQuerier, qs *querySet, mi *modelInfo, cond *Cond
 		}
 	}
 
+	for _, agg := range qs.aggregates {
+		col, fi := tables.getAggregateSQL(agg)
+		cols = append(cols, col)
+		infos = append(infos, fi)
+		tables.aliases = append(tables.aliases, agg.alias)
+	}
+
 	where, args := tables.getCondSQL(cond, false, tz)
 	groupBy := tables.getGroupSQL(qs.groups)
 	orderBy := tables.getOrderSQL(qs.orders)
@@ -1710,11 +2101,9 @@ func (d *dbBase) ReadValues(q dbQuerier, qs *querySet, mi *modelInfo, cond *Cond
 		case 1:
 			params := make(Params, len(cols))
 			for i, ref := range refs {
-				fi := infos[i]
-
 				val := reflect.Indirect(reflect.ValueOf(ref)).Interface()
 
-				value, err := d.convertValueFromDB(fi, val, tz)
+				value, err := d.convertValuesColumn(infos, qs.aggregates, i, val, tz)
 				if err != nil {
 					panic(fmt.Errorf("db value convert failed `%v` %s", val, err.Error()))
 				}
@@ -1725,11 +2114,9 @@ func (d *dbBase) ReadValues(q dbQuerier, qs *querySet, mi *modelInfo, cond *Cond
 		case 2:
 			params := make(ParamsList, 0, len(cols))
 			for i, ref := range refs {
-				fi := infos[i]
-
 				val := reflect.Indirect(reflect.ValueOf(ref)).Interface()
 
-				value, err := d.convertValueFromDB(fi, val, tz)
+				value, err := d.convertValuesColumn(infos, qs.aggregates, i, val, tz)
 				if err != nil {
 					panic(fmt.Errorf("db value convert failed `%v` %s", val, err.Error()))
 				}
@@ -1739,11 +2126,9 @@ func (d *dbBase) ReadValues(q dbQuerier, qs *querySet, mi *modelInfo, cond *Cond
 			lists = append(lists, params)
 		case 3:
 			for i, ref := range refs {
-				fi := infos[i]
-
 				val := reflect.Indirect(reflect.ValueOf(ref)).Interface()
 
-				value, err := d.convertValueFromDB(fi, val, tz)
+				value, err := d.convertValuesColumn(infos, qs.aggregates, i, val, tz)
 				if err != nil {
 					panic(fmt.Errorf("db value convert failed `%v` %s", val, err.Error()))
 				}
@@ -1886,3 +2271,8 @@ func (d *dbBase) ShowColumnsQuery(table string) string {
 func (d *dbBase) IndexExists(dbQuerier, string, string) bool {
 	panic(ErrNotImplement)
 }
+
+// not implement.
+func (d *dbBase) GetTableSchema(dbQuerier, string) (*tableSchema, error) {
+	return nil, ErrNotImplement
+}
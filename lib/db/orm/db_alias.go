@@ -18,6 +18,7 @@ import (
 	"database/sql"
 	"fmt"
 	"reflect"
+	"sort"
 	"sync"
 	"time"
 )
@@ -103,6 +104,18 @@ func (ac *_dbCache) getDefault() (al *alias) {
 	return
 }
 
+// get all database aliases ordered by name.
+func (ac *_dbCache) all() []*alias {
+	ac.mux.RLock()
+	defer ac.mux.RUnlock()
+	als := make([]*alias, 0, len(ac.cache))
+	for _, al := range ac.cache {
+		als = append(als, al)
+	}
+	sort.Slice(als, func(i, j int) bool { return als[i].Name < als[j].Name })
+	return als
+}
+
 type alias struct {
 	Name         string
 	Driver       DriverType
@@ -114,6 +127,14 @@ type alias struct {
 	DbBaser      dbBaser
 	TZ           *time.Location
 	Engine       string
+	Replicas     replicaSet
+	Interceptors []Interceptor // replaced by AddInterceptor under interceptMux, never changed in place
+
+	interceptMux sync.RWMutex
+
+	// zero means the connections are reused forever
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
 }
 
 func detectTZ(al *alias) {
@@ -285,6 +306,26 @@ func SetMaxOpenConns(aliasName string, maxOpenConns int) {
 	}
 }
 
+// SetConnMaxLifetime Change the max lifetime of conns for *sql.DB and its replicas, use specify database alias name
+func SetConnMaxLifetime(aliasName string, d time.Duration) {
+	al := getDbAlias(aliasName)
+	al.ConnMaxLifetime = d
+	al.DB.SetConnMaxLifetime(d)
+	for _, r := range al.Replicas.all() {
+		r.DB.SetConnMaxLifetime(d)
+	}
+}
+
+// SetConnMaxIdleTime Change the max idle time of conns for *sql.DB and its replicas, use specify database alias name
+func SetConnMaxIdleTime(aliasName string, d time.Duration) {
+	al := getDbAlias(aliasName)
+	al.ConnMaxIdleTime = d
+	al.DB.SetConnMaxIdleTime(d)
+	for _, r := range al.Replicas.all() {
+		r.DB.SetConnMaxIdleTime(d)
+	}
+}
+
 // GetDB Get *sql.DB from registered database by db alias name.
 // Use "default" as alias name if you not set.
 func GetDB(aliasNames ...string) (*sql.DB, error) {
@@ -0,0 +1,253 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// prefix of encrypted column value, the value is enc:<key version>:<base64 of nonce and sealed data>.
+const encryptPrefix = "enc:"
+
+// KeyProvider provides the keys of encrypted fields.
+// the fields with encrypt tag are encrypted by AES-GCM with the current key,
+// and decrypted with the key of the version stored in the value,
+// so the keys can be rotated by changing the current version and keeping the old keys.
+// the blind index key must not be changed, or the stored blind indexes no longer match.
+// the encrypted field can be filtered by exact, in and isnull with a blind index field,
+// which stores the HMAC of the plaintext and is set by orm:
+//	Phone      string `orm:"encrypt;blind_index(PhoneIndex)"`
+//	PhoneIndex string `orm:"size(64);index"`
+// Raw queries read the encrypted values as is.
+type KeyProvider interface {
+	// version and AES key of 16, 24 or 32 bytes for encrypting
+	EncryptKey() (version string, key []byte, err error)
+	// AES key of version for decrypting
+	DecryptKey(version string) ([]byte, error)
+	// HMAC key of blind index
+	BlindIndexKey() ([]byte, error)
+}
+
+// StaticKeyProvider is a KeyProvider of fixed keys.
+type StaticKeyProvider struct {
+	Current  string            // version of the key for encrypting
+	Keys     map[string][]byte // AES keys by version
+	IndexKey []byte            // HMAC key of blind index
+}
+
+var _ KeyProvider = new(StaticKeyProvider)
+
+// EncryptKey returns the key of current version.
+func (p *StaticKeyProvider) EncryptKey() (string, []byte, error) {
+	key, err := p.DecryptKey(p.Current)
+	return p.Current, key, err
+}
+
+// DecryptKey returns the key of version.
+func (p *StaticKeyProvider) DecryptKey(version string) ([]byte, error) {
+	key, ok := p.Keys[version]
+	if !ok {
+		return nil, fmt.Errorf("unknown encrypt key version `%s`", version)
+	}
+	return key, nil
+}
+
+// BlindIndexKey returns the HMAC key of blind index.
+func (p *StaticKeyProvider) BlindIndexKey() ([]byte, error) {
+	if len(p.IndexKey) == 0 {
+		return nil, errors.New("blind index key is empty")
+	}
+	return p.IndexKey, nil
+}
+
+var (
+	keyProviderMux sync.RWMutex
+	keyProvider    KeyProvider
+)
+
+// SetKeyProvider set the key provider of encrypted fields.
+// for example:
+//	orm.SetKeyProvider(&orm.StaticKeyProvider{
+//		Current:  "v2",
+//		Keys:     map[string][]byte{"v1": oldKey, "v2": newKey},
+//		IndexKey: indexKey,
+//	})
+func SetKeyProvider(p KeyProvider) {
+	keyProviderMux.Lock()
+	defer keyProviderMux.Unlock()
+	keyProvider = p
+}
+
+func getKeyProvider() (KeyProvider, error) {
+	keyProviderMux.RLock()
+	defer keyProviderMux.RUnlock()
+	if keyProvider == nil {
+		return nil, errors.New("encrypted field needs a key provider, use SetKeyProvider")
+	}
+	return keyProvider, nil
+}
+
+// encrypt the plaintext by the current key.
+func encryptString(plaintext string) (string, error) {
+	p, err := getKeyProvider()
+	if err != nil {
+		return "", err
+	}
+	version, key, err := p.EncryptKey()
+	if err != nil {
+		return "", err
+	}
+	if strings.Contains(version, ":") {
+		return "", fmt.Errorf("encrypt key version `%s` cannot contain `:`", version)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(version))
+	return encryptPrefix + version + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// decrypt the value created by encryptString.
+// the value without encrypt prefix is written before the field is encrypted, it's returned as is.
+func decryptString(value string) (string, error) {
+	if !strings.HasPrefix(value, encryptPrefix) {
+		return value, nil
+	}
+	parts := strings.SplitN(strings.TrimPrefix(value, encryptPrefix), ":", 2)
+	if len(parts) != 2 {
+		return "", errors.New("wrong encrypted value")
+	}
+	p, err := getKeyProvider()
+	if err != nil {
+		return "", err
+	}
+	key, err := p.DecryptKey(parts[0])
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", errors.New("wrong encrypted value")
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(parts[0]))
+	if err != nil {
+		return "", fmt.Errorf("decrypt value failed, %s", err.Error())
+	}
+	return string(plain), nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// get the blind index of plaintext, it's the hex of HMAC-SHA256.
+func blindIndex(plaintext string) (string, error) {
+	p, err := getKeyProvider()
+	if err != nil {
+		return "", err
+	}
+	key, err := p.BlindIndexKey()
+	if err != nil {
+		return "", err
+	}
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(plaintext))
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// encrypt the collected value of encrypted field fi, NULL is not encrypted.
+func getEncryptValue(fi *fieldInfo, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	s, err := encryptString(ToStr(value))
+	if err != nil {
+		return nil, fmt.Errorf("field `%s` encrypt failed, %s", fi.fullName, err.Error())
+	}
+	return s, nil
+}
+
+// decrypt the value of encrypted field fi read from database.
+func getDecryptValue(fi *fieldInfo, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	s, err := decryptString(ToStr(value))
+	if err != nil {
+		return nil, fmt.Errorf("field `%s` decrypt failed, %s", fi.fullName, err.Error())
+	}
+	return s, nil
+}
+
+// get the blind index of the collected value of encrypted field fi.
+func getBlindIndexValue(fi *fieldInfo, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	s, err := blindIndex(ToStr(value))
+	if err != nil {
+		return nil, fmt.Errorf("field `%s` blind index failed, %s", fi.fullName, err.Error())
+	}
+	return s, nil
+}
+
+// replace the condition on encrypted field fi by the condition on its blind index field.
+// only exact, in and isnull are supported.
+func getBlindIndexCond(fi *fieldInfo, operator string, args []interface{}, tz *time.Location) (*fieldInfo, []interface{}) {
+	switch operator {
+	case "isnull":
+		return fi, args
+	case "exact", "in":
+	default:
+		panic(fmt.Errorf("encrypted field `%s` only support exact, in and isnull operator", fi.fullName))
+	}
+	if fi.blindIndex == nil {
+		panic(fmt.Errorf("encrypted field `%s` needs blind_index to be filtered", fi.fullName))
+	}
+	params := getFlatParams(fi, args, tz)
+	for i, param := range params {
+		v, err := getBlindIndexValue(fi, param)
+		if err != nil {
+			panic(err)
+		}
+		params[i] = v
+	}
+	return fi.blindIndex, params
+}
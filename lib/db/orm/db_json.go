@@ -0,0 +1,165 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// key of json path, array index is digits.
+var jsonKeyRegexp = regexp.MustCompile(`^\w+$`)
+
+// check whether the field type is marshaled as json document, like struct, map and slice.
+func isJSONType(typ reflect.Type) bool {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	switch typ.Kind() {
+	case reflect.Struct:
+		return typ != reflect.TypeOf(time.Time{}) && typ != reflect.TypeOf(sql.NullString{})
+	case reflect.Map, reflect.Array:
+		return true
+	case reflect.Slice:
+		return typ.Elem().Kind() != reflect.Uint8
+	}
+	return false
+}
+
+// marshal the json field value, nil value of null field is NULL.
+func getJSONValue(fi *fieldInfo, field reflect.Value) (interface{}, error) {
+	switch field.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice:
+		if field.IsNil() && fi.null {
+			return nil, nil
+		}
+	}
+	b, err := json.Marshal(field.Interface())
+	if err != nil {
+		return nil, fmt.Errorf("field `%s` marshal json failed, %s", fi.fullName, err.Error())
+	}
+	return string(b), nil
+}
+
+// unmarshal the json document to field, NULL or empty document set field to zero.
+func setJSONValue(fi *fieldInfo, value interface{}, field reflect.Value) error {
+	s := ""
+	if value != nil {
+		s = ToStr(value)
+	}
+	if s == "" || s == "null" {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+	ptr := reflect.New(field.Type())
+	if err := json.Unmarshal([]byte(s), ptr.Interface()); err != nil {
+		return fmt.Errorf("field `%s` unmarshal json failed, %s", fi.fullName, err.Error())
+	}
+	field.Set(ptr.Elem())
+	return nil
+}
+
+// check the keys of json path, they are put into sql directly.
+func checkJSONPath(fi *fieldInfo, path []string) {
+	if fi.fieldType != TypeJSONField && fi.fieldType != TypeJsonbField {
+		panic(fmt.Errorf("field `%s` is not a json field", fi.fullName))
+	}
+	for _, key := range path {
+		if !jsonKeyRegexp.MatchString(key) {
+			panic(fmt.Errorf("wrong json path key `%s` of field `%s`", key, fi.fullName))
+		}
+	}
+}
+
+// json path of mysql and sqlite, like $.size.width or $.tags[0].
+func jsonPathSQL(path []string) string {
+	s := "$"
+	for _, key := range path {
+		if _, err := StrTo(key).Int(); err == nil {
+			s += "[" + key + "]"
+		} else {
+			s += "." + key
+		}
+	}
+	return s
+}
+
+// generate sql of the json path value in leftCol, args are the values compared with it.
+func (d *dbBase) GenerateJSONPath(leftCol string, path []string, args []interface{}) string {
+	return fmt.Sprintf("JSON_UNQUOTE(JSON_EXTRACT(%s, '%s'))", leftCol, jsonPathSQL(path))
+}
+
+// generate condition sql of json document in leftCol contains the json value of mark.
+// path is the sub document of leftCol, it can be empty.
+func (d *dbBase) GenerateJSONContains(leftCol string, path []string) string {
+	if len(path) == 0 {
+		return fmt.Sprintf("JSON_CONTAINS(%s, ?)", leftCol)
+	}
+	return fmt.Sprintf("JSON_CONTAINS(%s, ?, '%s')", leftCol, jsonPathSQL(path))
+}
+
+// get the json document of json_contains arg.
+func getJSONContainsArg(operator string, args []interface{}) string {
+	if len(args) != 1 {
+		panic(fmt.Errorf("operator `%s` need 1 args not %d", operator, len(args)))
+	}
+	b, err := json.Marshal(args[0])
+	if err != nil {
+		panic(fmt.Errorf("operator `%s` marshal json failed, %s", operator, err.Error()))
+	}
+	return string(b)
+}
+
+// check the args compared with json path value are all numbers.
+func isJSONNumberArgs(args []interface{}) bool {
+	if len(args) == 0 {
+		return false
+	}
+	for _, arg := range args {
+		val := reflect.Indirect(reflect.ValueOf(arg))
+		switch val.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+		case reflect.Slice, reflect.Array:
+			if val.Type().Elem().Kind() == reflect.Uint8 {
+				return false
+			}
+			for i := 0; i < val.Len(); i++ {
+				if !isJSONNumberArgs([]interface{}{val.Index(i).Interface()}) {
+					return false
+				}
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// split the json path from exprs, like Attrs__json__size__width.
+func splitJSONPath(exprs []string) ([]string, []string) {
+	for i := 1; i < len(exprs); i++ {
+		if strings.ToLower(exprs[i]) == "json" {
+			return exprs[:i], exprs[i+1:]
+		}
+	}
+	return exprs, nil
+}
@@ -99,6 +99,72 @@ func (d *dbBaseMysql) IndexExists(db dbQuerier, table string, name string) bool
 	return cnt > 0
 }
 
+// read structure of table from information_schema of mysql.
+func (d *dbBaseMysql) GetTableSchema(db dbQuerier, table string) (*tableSchema, error) {
+	schema := &tableSchema{name: table}
+
+	rows, err := db.Query("SELECT COLUMN_NAME, COLUMN_TYPE, IS_NULLABLE, COLUMN_KEY, EXTRA FROM information_schema.columns "+
+		"WHERE table_schema = DATABASE() AND table_name = ? ORDER BY ORDINAL_POSITION", table)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var name, typ, null, key, extra string
+		if err := rows.Scan(&name, &typ, &null, &key, &extra); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		schema.columns = append(schema.columns, &schemaColumn{
+			name: name,
+			typ:  strings.ToLower(typ),
+			null: null == "YES",
+			pk:   key == "PRI",
+			auto: strings.Contains(strings.ToLower(extra), "auto_increment"),
+		})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.Query("SELECT INDEX_NAME, COLUMN_NAME, NON_UNIQUE FROM information_schema.statistics "+
+		"WHERE table_schema = DATABASE() AND table_name = ? AND INDEX_NAME != 'PRIMARY' ORDER BY INDEX_NAME, SEQ_IN_INDEX", table)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var name, column string
+		var nonUnique int
+		if err := rows.Scan(&name, &column, &nonUnique); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		schema.addIndexColumn(name, column, nonUnique == 0)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.Query("SELECT k.COLUMN_NAME, k.REFERENCED_TABLE_NAME, k.REFERENCED_COLUMN_NAME, r.DELETE_RULE "+
+		"FROM information_schema.key_column_usage k JOIN information_schema.referential_constraints r "+
+		"ON r.CONSTRAINT_SCHEMA = k.CONSTRAINT_SCHEMA AND r.CONSTRAINT_NAME = k.CONSTRAINT_NAME AND r.TABLE_NAME = k.TABLE_NAME "+
+		"WHERE k.table_schema = DATABASE() AND k.table_name = ? AND k.REFERENCED_TABLE_NAME IS NOT NULL", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		fk := new(schemaForeignKey)
+		if err := rows.Scan(&fk.column, &fk.refTable, &fk.refColumn, &fk.onDelete); err != nil {
+			return nil, err
+		}
+		fk.onDelete = schemaDeleteRule(fk.onDelete)
+		schema.foreignKeys = append(schema.foreignKeys, fk)
+	}
+	return schema, rows.Err()
+}
+
 // InsertOrUpdate a row
 // If your primary key or unique column conflict will update
 // If no will insert
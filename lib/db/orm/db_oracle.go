@@ -15,6 +15,7 @@
 package orm
 
 import (
+	"context"
 	"fmt"
 	"strings"
 )
@@ -135,3 +136,8 @@ func (d *dbBaseOracle) InsertValue(q dbQuerier, mi *modelInfo, isMulti bool, nam
 	err := row.Scan(&id)
 	return id, err
 }
+
+// explain is not supported by oracle, it needs a plan table.
+func (d *dbBaseOracle) explain(ctx context.Context, q dbQuerier, query string, args []interface{}) (*QueryPlan, error) {
+	return nil, ErrNotImplement
+}
@@ -15,8 +15,12 @@
 package orm
 
 import (
+	"context"
+	"database/sql"
+	"encoding/json"
 	"fmt"
 	"strconv"
+	"strings"
 )
 
 // postgresql operators.
@@ -83,6 +87,23 @@ func (d *dbBasePostgres) GenerateOperatorLeftCol(fi *fieldInfo, operator string,
 	}
 }
 
+// generate sql of the json path value in leftCol, like ("T0"."attrs" #>> '{size,width}').
+// #>> returns text, it is cast to numeric when compared with numbers.
+func (d *dbBasePostgres) GenerateJSONPath(leftCol string, path []string, args []interface{}) string {
+	if isJSONNumberArgs(args) {
+		return fmt.Sprintf("(%s #>> '{%s}')::numeric", leftCol, strings.Join(path, ","))
+	}
+	return fmt.Sprintf("(%s #>> '{%s}')", leftCol, strings.Join(path, ","))
+}
+
+// generate condition sql of json document contains, the document is compared as jsonb.
+func (d *dbBasePostgres) GenerateJSONContains(leftCol string, path []string) string {
+	if len(path) == 0 {
+		return fmt.Sprintf("%s::jsonb @> ?::jsonb", leftCol)
+	}
+	return fmt.Sprintf("(%s::jsonb #> '{%s}') @> ?::jsonb", leftCol, strings.Join(path, ","))
+}
+
 // postgresql unsupports updating joined record.
 func (d *dbBasePostgres) SupportUpdateJoin() bool {
 	return false
@@ -98,29 +119,34 @@ func (d *dbBasePostgres) TableQuote() string {
 }
 
 // postgresql value placeholder is $n.
-// replace default ? to $n.
+// replace default ? outside of string literals and quoted names to $n, the $n in query are kept.
+// ?? is the escaped ?, it is replaced to ?, like the jsonb operators attrs ?? 'color'.
 func (d *dbBasePostgres) ReplaceMarks(query *string) {
 	q := *query
-	num := 0
-	for _, c := range q {
-		if c == '?' {
-			num++
-		}
-	}
-	if num == 0 {
+	if !strings.Contains(q, "?") {
 		return
 	}
-	data := make([]byte, 0, len(q)+num)
-	num = 1
+	data := make([]byte, 0, len(q)+8)
+	num := 1
+	var quote byte
 	for i := 0; i < len(q); i++ {
 		c := q[i]
-		if c == '?' {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '?' && i+1 < len(q) && q[i+1] == '?':
+			i++
+		case c == '?':
 			data = append(data, '$')
-			data = append(data, []byte(strconv.Itoa(num))...)
+			data = append(data, strconv.Itoa(num)...)
 			num++
-		} else {
-			data = append(data, c)
+			continue
 		}
+		data = append(data, c)
 	}
 	*query = string(data)
 }
@@ -128,7 +154,7 @@ func (d *dbBasePostgres) ReplaceMarks(query *string) {
 // make returning sql support for postgresql.
 func (d *dbBasePostgres) HasReturningID(mi *modelInfo, query *string) bool {
 	fi := mi.fields.pk
-	if fi.fieldType&IsPositiveIntegerField == 0 && fi.fieldType&IsIntegerField == 0 {
+	if fi.fieldType&IsPositiveIntegerField == 0 && fi.fieldType&IsIntegerField == 0 || len(mi.fields.pks) > 1 {
 		return false
 	}
 
@@ -181,6 +207,151 @@ func (d *dbBasePostgres) IndexExists(db dbQuerier, table string, name string) bo
 	return cnt > 0
 }
 
+// read structure of table in current schema from information_schema and pg_index of postgresql.
+func (d *dbBasePostgres) GetTableSchema(db dbQuerier, table string) (*tableSchema, error) {
+	schema := &tableSchema{name: table}
+
+	rows, err := db.Query("SELECT column_name, data_type, character_maximum_length, numeric_precision, numeric_scale, "+
+		"is_nullable, column_default, is_identity FROM information_schema.columns "+
+		"WHERE table_schema = current_schema() AND table_name = $1 ORDER BY ordinal_position", table)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var (
+			name, typ, null, identity string
+			size, precision, scale    sql.NullInt64
+			def                       sql.NullString
+		)
+		if err := rows.Scan(&name, &typ, &size, &precision, &scale, &null, &def, &identity); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		switch typ {
+		case "character varying":
+			typ = "varchar"
+		case "character":
+			typ = "char"
+		case "numeric":
+			typ = "decimal"
+		case "double precision":
+			typ = "double"
+		case "timestamp without time zone", "timestamp with time zone":
+			typ = "timestamp"
+		case "time without time zone", "time with time zone":
+			typ = "time"
+		}
+		switch {
+		case size.Valid:
+			typ = fmt.Sprintf("%s(%d)", typ, size.Int64)
+		case typ == "decimal" && precision.Valid:
+			typ = fmt.Sprintf("decimal(%d,%d)", precision.Int64, scale.Int64)
+		}
+		schema.columns = append(schema.columns, &schemaColumn{
+			name: name,
+			typ:  typ,
+			null: null == "YES",
+			auto: identity == "YES" || strings.HasPrefix(def.String, "nextval("),
+		})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.Query("SELECT i.relname, a.attname, ix.indisunique, ix.indisprimary FROM pg_index ix "+
+		"JOIN pg_class t ON t.oid = ix.indrelid JOIN pg_class i ON i.oid = ix.indexrelid "+
+		"JOIN pg_namespace n ON n.oid = t.relnamespace "+
+		"JOIN LATERAL unnest(ix.indkey) WITH ORDINALITY AS k(attnum, ord) ON true "+
+		"JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = k.attnum "+
+		"WHERE t.relname = $1 AND n.nspname = current_schema() ORDER BY i.relname, k.ord", table)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var name, column string
+		var unique, primary bool
+		if err := rows.Scan(&name, &column, &unique, &primary); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if primary {
+			if col := schema.column(column); col != nil {
+				col.pk = true
+			}
+			continue
+		}
+		schema.addIndexColumn(name, column, unique)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.Query("SELECT kcu.column_name, ccu.table_name, ccu.column_name, rc.delete_rule "+
+		"FROM information_schema.referential_constraints rc "+
+		"JOIN information_schema.key_column_usage kcu ON kcu.constraint_schema = rc.constraint_schema AND kcu.constraint_name = rc.constraint_name "+
+		"JOIN information_schema.constraint_column_usage ccu ON ccu.constraint_schema = rc.unique_constraint_schema AND ccu.constraint_name = rc.unique_constraint_name "+
+		"WHERE kcu.table_schema = current_schema() AND kcu.table_name = $1", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		fk := new(schemaForeignKey)
+		if err := rows.Scan(&fk.column, &fk.refTable, &fk.refColumn, &fk.onDelete); err != nil {
+			return nil, err
+		}
+		fk.onDelete = schemaDeleteRule(fk.onDelete)
+		schema.foreignKeys = append(schema.foreignKeys, fk)
+	}
+	return schema, rows.Err()
+}
+
+// plan node of postgresql EXPLAIN in json format.
+type postgresPlanNode struct {
+	NodeType     string             `json:"Node Type"`
+	RelationName string             `json:"Relation Name"`
+	IndexName    string             `json:"Index Name"`
+	PlanRows     float64            `json:"Plan Rows"`
+	Plans        []postgresPlanNode `json:"Plans"`
+}
+
+// explain query by postgresql, the plan tree is flattened in depth first order.
+func (d *dbBasePostgres) explain(ctx context.Context, q dbQuerier, query string, args []interface{}) (*QueryPlan, error) {
+	raw, err := queryExplainRows(ctx, q, "EXPLAIN (FORMAT JSON) "+query, args)
+	if err != nil {
+		return nil, err
+	}
+	plan := &QueryPlan{Raw: raw}
+	var walk func(node postgresPlanNode)
+	walk = func(node postgresPlanNode) {
+		plan.Steps = append(plan.Steps, PlanStep{
+			Table:    node.RelationName,
+			Access:   node.NodeType,
+			Index:    node.IndexName,
+			Rows:     int64(node.PlanRows),
+			FullScan: node.NodeType == "Seq Scan",
+			Detail:   node.NodeType,
+		})
+		for _, child := range node.Plans {
+			walk(child)
+		}
+	}
+	for _, row := range raw {
+		var plans []struct {
+			Plan postgresPlanNode `json:"Plan"`
+		}
+		if err := json.Unmarshal([]byte(explainString(row["QUERY PLAN"])), &plans); err != nil {
+			return nil, err
+		}
+		for _, p := range plans {
+			walk(p.Plan)
+		}
+	}
+	return plan, nil
+}
+
 // create new postgresql dbBaser.
 func newdbBasePostgres() dbBaser {
 	b := new(dbBasePostgres)
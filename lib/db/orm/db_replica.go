@@ -0,0 +1,267 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ReplicaPolicy decide how a read replica is chosen for a query.
+type ReplicaPolicy int
+
+// Enum the replica policies
+const (
+	ReplicaRoundRobin ReplicaPolicy = iota // pick healthy replicas in turn
+	ReplicaWeighted                        // pick healthy replicas randomly by weight
+)
+
+// ReplicaHealthCheckInterval is the interval between two pings of every replica.
+// a replica failing the ping is skipped until a later ping succeeds.
+var ReplicaHealthCheckInterval = 10 * time.Second
+
+// read replica of a database alias.
+type replica struct {
+	DataSource string
+	DB         *sql.DB
+	Weight     int
+	healthy    int32
+}
+
+func (r *replica) isHealthy() bool {
+	return atomic.LoadInt32(&r.healthy) == 1
+}
+
+func (r *replica) setHealthy(ok bool) {
+	var v int32
+	if ok {
+		v = 1
+	}
+	atomic.StoreInt32(&r.healthy, v)
+}
+
+// replicas registered under one alias.
+type replicaSet struct {
+	mux      sync.RWMutex
+	replicas []*replica
+	policy   ReplicaPolicy
+	next     uint64
+	stop     chan struct{} // closed to stop the running health check loop
+}
+
+// add replica to set.
+func (rs *replicaSet) add(r *replica) {
+	rs.mux.Lock()
+	defer rs.mux.Unlock()
+	rs.replicas = append(rs.replicas, r)
+}
+
+// get all replicas.
+func (rs *replicaSet) all() []*replica {
+	rs.mux.RLock()
+	defer rs.mux.RUnlock()
+	return rs.replicas
+}
+
+// pick a healthy replica following the policy, nil means no replica is usable.
+func (rs *replicaSet) pick() *replica {
+	rs.mux.RLock()
+	defer rs.mux.RUnlock()
+
+	if len(rs.replicas) == 0 {
+		return nil
+	}
+
+	healthy := make([]*replica, 0, len(rs.replicas))
+	total := 0
+	for _, r := range rs.replicas {
+		if r.isHealthy() {
+			healthy = append(healthy, r)
+			total += r.Weight
+		}
+	}
+	if len(healthy) == 0 {
+		return nil
+	}
+
+	switch rs.policy {
+	case ReplicaWeighted:
+		n := rand.Intn(total)
+		for _, r := range healthy {
+			if n < r.Weight {
+				return r
+			}
+			n -= r.Weight
+		}
+	}
+	n := atomic.AddUint64(&rs.next, 1)
+	return healthy[n%uint64(len(healthy))]
+}
+
+// ping every replica and update its health flag.
+func (rs *replicaSet) check() {
+	for _, r := range rs.all() {
+		ctx, cancel := context.WithTimeout(context.Background(), ReplicaHealthCheckInterval)
+		err := r.DB.PingContext(ctx)
+		cancel()
+		if err != nil && r.isHealthy() {
+			DebugLog.Printf("replica `%s` is unhealthy, %s\n", r.DataSource, err.Error())
+		}
+		r.setHealthy(err == nil)
+	}
+}
+
+// start the health check loop if it is not running.
+func (rs *replicaSet) startCheck() {
+	rs.mux.Lock()
+	defer rs.mux.Unlock()
+	if rs.stop != nil {
+		return
+	}
+	stop := make(chan struct{})
+	rs.stop = stop
+	go func() {
+		ticker := time.NewTicker(ReplicaHealthCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				rs.check()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// stop the health check loop if it is running.
+func (rs *replicaSet) stopCheck() {
+	rs.mux.Lock()
+	defer rs.mux.Unlock()
+	if rs.stop != nil {
+		close(rs.stop)
+		rs.stop = nil
+	}
+}
+
+// get a db querier for read only statements.
+// it is served by a healthy replica if the alias has one, otherwise by the primary.
+func (al *alias) readDB() dbQuerier {
+	if r := al.Replicas.pick(); r != nil {
+		return r.DB
+	}
+	return al.DB
+}
+
+// add replica to the registered database alias.
+func addReplica(aliasName string, r *replica) error {
+	al, ok := dataBaseCache.get(aliasName)
+	if !ok {
+		return fmt.Errorf("DataBase alias name `%s` not registered", aliasName)
+	}
+
+	if err := r.DB.Ping(); err != nil {
+		return fmt.Errorf("register replica Ping `%s`, %s", aliasName, err.Error())
+	}
+
+	if r.Weight <= 0 {
+		r.Weight = 1
+	}
+	r.setHealthy(true)
+
+	al.Replicas.add(r)
+	al.Replicas.startCheck()
+	return nil
+}
+
+// AddReplicaWithDB add a read replica *sql.DB to a registered database alias.
+// weight is used by ReplicaWeighted policy, value <= 0 is treated as 1.
+func AddReplicaWithDB(aliasName string, db *sql.DB, weight int) error {
+	return addReplica(aliasName, &replica{DB: db, Weight: weight})
+}
+
+// RegisterReplica Setting a read replica for a registered database alias.
+// SELECT statements of QuerySeter and Ormer.Read are routed to the healthy replicas,
+// writes and everything inside a transaction stay on the primary.
+// params are maxIdle, maxOpen like RegisterDataBase.
+func RegisterReplica(aliasName, dataSource string, weight int, params ...int) error {
+	var (
+		err error
+		db  *sql.DB
+	)
+
+	al, ok := dataBaseCache.get(aliasName)
+	if !ok {
+		err = fmt.Errorf("DataBase alias name `%s` not registered", aliasName)
+		goto end
+	}
+
+	db, err = sql.Open(al.DriverName, dataSource)
+	if err != nil {
+		err = fmt.Errorf("register replica `%s`, %s", aliasName, err.Error())
+		goto end
+	}
+
+	for i, v := range params {
+		switch i {
+		case 0:
+			db.SetMaxIdleConns(v)
+		case 1:
+			db.SetMaxOpenConns(v)
+		}
+	}
+	db.SetConnMaxLifetime(al.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(al.ConnMaxIdleTime)
+
+	err = addReplica(aliasName, &replica{DataSource: dataSource, DB: db, Weight: weight})
+
+end:
+	if err != nil {
+		if db != nil {
+			db.Close()
+		}
+		DebugLog.Println(err.Error())
+	}
+
+	return err
+}
+
+// StopReplicaHealthCheck stop the health check of the replicas of a registered database alias,
+// the replicas keep their last health state. registering another replica starts the check again.
+func StopReplicaHealthCheck(aliasName string) error {
+	al, ok := dataBaseCache.get(aliasName)
+	if !ok {
+		return fmt.Errorf("DataBase alias name `%s` not registered", aliasName)
+	}
+	al.Replicas.stopCheck()
+	return nil
+}
+
+// SetReplicaPolicy Change the policy used to choose a replica, use specify database alias name
+func SetReplicaPolicy(aliasName string, policy ReplicaPolicy) error {
+	al, ok := dataBaseCache.get(aliasName)
+	if !ok {
+		return fmt.Errorf("DataBase alias name `%s` not registered", aliasName)
+	}
+	al.Replicas.mux.Lock()
+	al.Replicas.policy = policy
+	al.Replicas.mux.Unlock()
+	return nil
+}
@@ -0,0 +1,98 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"strings"
+)
+
+// column of database table read by GetTableSchema.
+type schemaColumn struct {
+	name string
+	typ  string // lower case database type, like varchar(30) or int unsigned
+	null bool
+	pk   bool
+	auto bool
+}
+
+// index of database table, the primary key is not included.
+type schemaIndex struct {
+	name    string
+	columns []string
+	unique  bool
+}
+
+// foreign key of database table.
+type schemaForeignKey struct {
+	column    string
+	refTable  string
+	refColumn string // empty means the primary key of refTable
+	onDelete  string // upper case rule, like CASCADE or SET NULL
+}
+
+// table structure read from database.
+type tableSchema struct {
+	name        string
+	columns     []*schemaColumn
+	indexes     []*schemaIndex
+	foreignKeys []*schemaForeignKey
+}
+
+// get column by name.
+func (t *tableSchema) column(name string) *schemaColumn {
+	for _, col := range t.columns {
+		if col.name == name {
+			return col
+		}
+	}
+	return nil
+}
+
+// get the primary key columns.
+func (t *tableSchema) pks() []*schemaColumn {
+	var pks []*schemaColumn
+	for _, col := range t.columns {
+		if col.pk {
+			pks = append(pks, col)
+		}
+	}
+	return pks
+}
+
+// add column of index name, the index is created if not exist.
+func (t *tableSchema) addIndexColumn(name, column string, unique bool) {
+	for _, idx := range t.indexes {
+		if idx.name == name {
+			idx.columns = append(idx.columns, column)
+			return
+		}
+	}
+	t.indexes = append(t.indexes, &schemaIndex{name: name, columns: []string{column}, unique: unique})
+}
+
+// check whether column has a single column unique index.
+func (t *tableSchema) isUnique(column string) bool {
+	for _, idx := range t.indexes {
+		if idx.unique && len(idx.columns) == 1 && idx.columns[0] == column {
+			return true
+		}
+	}
+	return false
+}
+
+// normalize the delete rule of foreign key.
+func schemaDeleteRule(rule string) string {
+	return strings.ToUpper(strings.TrimSpace(rule))
+}
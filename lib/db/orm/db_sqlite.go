@@ -15,8 +15,10 @@
 package orm
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 )
 
 // sqlite operators.
@@ -79,6 +81,20 @@ func (d *dbBaseSqlite) GenerateOperatorLeftCol(fi *fieldInfo, operator string, l
 	}
 }
 
+// generate sql of the json path value in leftCol, like json_extract(`T0`.`attrs`, '$.size.width').
+func (d *dbBaseSqlite) GenerateJSONPath(leftCol string, path []string, args []interface{}) string {
+	return fmt.Sprintf("json_extract(%s, '%s')", leftCol, jsonPathSQL(path))
+}
+
+// generate condition sql of json document contains.
+// sqlite only checks whether one element of the array document equals to the value.
+func (d *dbBaseSqlite) GenerateJSONContains(leftCol string, path []string) string {
+	if len(path) > 0 {
+		leftCol = fmt.Sprintf("%s, '%s'", leftCol, jsonPathSQL(path))
+	}
+	return fmt.Sprintf("EXISTS (SELECT 1 FROM json_each(%s) WHERE json_each.value = json_extract(?, '$'))", leftCol)
+}
+
 // unable updating joined record in sqlite.
 func (d *dbBaseSqlite) SupportUpdateJoin() bool {
 	return false
@@ -143,6 +159,138 @@ func (d *dbBaseSqlite) IndexExists(db dbQuerier, table string, name string) bool
 	return false
 }
 
+// read structure of table from the pragmas of sqlite.
+func (d *dbBaseSqlite) GetTableSchema(db dbQuerier, table string) (*tableSchema, error) {
+	schema := &tableSchema{name: table}
+
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info('%s')", table))
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var (
+			cid, notnull, pk int
+			name, typ        string
+			def              sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notnull, &def, &pk); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		schema.columns = append(schema.columns, &schemaColumn{
+			name: name,
+			typ:  strings.ToLower(typ),
+			null: notnull == 0 && pk == 0,
+			pk:   pk > 0,
+		})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// single integer primary key is the alias of rowid
+	if pks := schema.pks(); len(pks) == 1 && pks[0].typ == "integer" {
+		pks[0].auto = true
+	}
+
+	rows, err = db.Query(fmt.Sprintf("PRAGMA index_list('%s')", table))
+	if err != nil {
+		return nil, err
+	}
+	var indexes []*schemaIndex
+	for rows.Next() {
+		var seq, unique int
+		var name, origin, partial string
+		if err := rows.Scan(&seq, &name, &unique, &origin, &partial); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if origin != "pk" {
+			indexes = append(indexes, &schemaIndex{name: name, unique: unique == 1})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// index_list returns the latest created index first
+	for i := len(indexes) - 1; i >= 0; i-- {
+		idx := indexes[i]
+		rows, err := db.Query(fmt.Sprintf("PRAGMA index_info('%s')", idx.name))
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var seqno, cid int
+			var name string
+			if err := rows.Scan(&seqno, &cid, &name); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			idx.columns = append(idx.columns, name)
+		}
+		rows.Close()
+		schema.indexes = append(schema.indexes, idx)
+	}
+
+	rows, err = db.Query(fmt.Sprintf("PRAGMA foreign_key_list('%s')", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			id, seq                            int
+			refTable, from, onUpdate, onDelete string
+			to, match                          sql.NullString
+		)
+		if err := rows.Scan(&id, &seq, &refTable, &from, &to, &onUpdate, &onDelete, &match); err != nil {
+			return nil, err
+		}
+		schema.foreignKeys = append(schema.foreignKeys, &schemaForeignKey{
+			column:    from,
+			refTable:  refTable,
+			refColumn: to.String,
+			onDelete:  schemaDeleteRule(onDelete),
+		})
+	}
+	return schema, rows.Err()
+}
+
+// explain query by sqlite, the detail of each row is like
+// SCAN T0, SEARCH T0 USING INDEX user_name (user_name=?) or USE TEMP B-TREE FOR ORDER BY.
+func (d *dbBaseSqlite) explain(ctx context.Context, q dbQuerier, query string, args []interface{}) (*QueryPlan, error) {
+	raw, err := queryExplainRows(ctx, q, "EXPLAIN QUERY PLAN "+query, args)
+	if err != nil {
+		return nil, err
+	}
+	plan := &QueryPlan{Raw: raw}
+	for _, row := range raw {
+		detail := explainString(row["detail"])
+		step := PlanStep{Detail: detail}
+		words := strings.Fields(detail)
+		if len(words) > 1 && (words[0] == "SCAN" || words[0] == "SEARCH") {
+			step.Access = words[0]
+			// older sqlite reports SCAN TABLE user AS T0
+			if words = words[1:]; words[0] == "TABLE" && len(words) > 1 {
+				words = words[1:]
+			}
+			step.Table = words[0]
+			for i, word := range words {
+				switch {
+				case word == "INDEX" && i+1 < len(words):
+					step.Index = words[i+1]
+				case word == "PRIMARY" && step.Index == "":
+					step.Index = "PRIMARY KEY"
+				}
+			}
+			step.FullScan = step.Access == "SCAN" && !strings.Contains(detail, " USING ")
+		}
+		plan.Steps = append(plan.Steps, step)
+	}
+	return plan, nil
+}
+
 // create new sqlite dbBaser.
 func newdbBaseSqlite() dbBaser {
 	b := new(dbBaseSqlite)
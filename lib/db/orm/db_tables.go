@@ -40,6 +40,8 @@ type dbTables struct {
 	mi      *modelInfo
 	base    dbBaser
 	skipEnd bool
+	aliases []string
+	tenant  string // tenant of the query, the joined tenant models use its tables
 }
 
 // set table info to collection.
@@ -184,6 +186,18 @@ func (t *dbTables) getJoinSQL() (join string) {
 		}
 		t2 = jt.index
 		table = jt.mi.table
+		if jt.mi.tenant != nil {
+			// join the tables of the same tenant, the query without tenant cannot join tenant models
+			tenant := t.tenant
+			if t.mi.tenantOf != nil {
+				tenant = t.mi.tenantID
+			}
+			tmi, err := getTenantModel(jt.mi, tenant, Q)
+			if err != nil {
+				panic(err)
+			}
+			table = tmi.table
+		}
 
 		switch {
 		case jt.fi.fieldType == RelManyToMany || jt.fi.fieldType == RelReverseMany || jt.fi.reverse && jt.fi.reverseFieldInfo.fieldType == RelManyToMany:
@@ -363,6 +377,9 @@ func (t *dbTables) getCondSQL(cond *Condition, sub bool, tz *time.Location) (whe
 				exprs = exprs[:num]
 			}
 
+			// json path lookup, like Attrs__json__color
+			exprs, path := splitJSONPath(exprs)
+
 			index, _, fi, suc := t.parseExprs(mi, exprs)
 			if !suc {
 				panic(fmt.Errorf("unknown field/column name `%s`", strings.Join(p.exprs, ExprSep)))
@@ -372,15 +389,39 @@ func (t *dbTables) getCondSQL(cond *Condition, sub bool, tz *time.Location) (whe
 				operator = "exact"
 			}
 
+			leftCol := fmt.Sprintf("%s.%s%s%s", index, Q, fi.column, Q)
+			if path != nil || operator == "json_contains" {
+				if path != nil && len(path) == 0 {
+					panic(fmt.Errorf("json lookup `%s` need a path", strings.Join(p.exprs, ExprSep)))
+				}
+				checkJSONPath(fi, path)
+			}
+
+			if operator == "json_contains" && !p.isRaw {
+				where += t.base.GenerateJSONContains(leftCol, path) + " "
+				params = append(params, getJSONContainsArg(operator, p.args))
+				continue
+			}
+			if len(path) > 0 {
+				leftCol = t.base.GenerateJSONPath(leftCol, path, p.args)
+			}
+
 			var operSQL string
 			var args []interface{}
 			if p.isRaw {
 				operSQL = p.sql
+			} else if fi.encrypt {
+				// encrypted field is filtered by its blind index
+				ifi, iargs := getBlindIndexCond(fi, operator, p.args, tz)
+				if ifi != fi {
+					fi = ifi
+					leftCol = fmt.Sprintf("%s.%s%s%s", index, Q, fi.column, Q)
+				}
+				operSQL, args = t.base.GenerateOperatorSQL(mi, fi, operator, iargs, tz)
 			} else {
 				operSQL, args = t.base.GenerateOperatorSQL(mi, fi, operator, p.args, tz)
 			}
 
-			leftCol := fmt.Sprintf("%s.%s%s%s", index, Q, fi.column, Q)
 			t.base.GenerateOperatorLeftCol(fi, operator, &leftCol)
 
 			where += fmt.Sprintf("%s %s ", leftCol, operSQL)
@@ -435,6 +476,10 @@ func (t *dbTables) getOrderSQL(orders []string) (orderSQL string) {
 			asc = "DESC"
 			order = order[1:]
 		}
+		if t.isAlias(order) {
+			orderSqls = append(orderSqls, fmt.Sprintf("%s%s%s %s", Q, order, Q, asc))
+			continue
+		}
 		exprs := strings.Split(order, ExprSep)
 
 		index, _, fi, suc := t.parseExprs(t.mi, exprs)
@@ -449,6 +494,16 @@ func (t *dbTables) getOrderSQL(orders []string) (orderSQL string) {
 	return
 }
 
+// check whether name is the alias of a selected aggregate.
+func (t *dbTables) isAlias(name string) bool {
+	for _, alias := range t.aliases {
+		if alias == name {
+			return true
+		}
+	}
+	return false
+}
+
 // generate limit sql.
 func (t *dbTables) getLimitSQL(mi *modelInfo, offset int64, limit int64) (limits string) {
 	if limit == 0 {
@@ -15,7 +15,10 @@
 package orm
 
 import (
+	"context"
 	"fmt"
+	"strconv"
+	"strings"
 )
 
 // mysql dbBaser implementation.
@@ -55,6 +58,43 @@ func (d *dbBaseTidb) IndexExists(db dbQuerier, table string, name string) bool {
 	return cnt > 0
 }
 
+// explain query by tidb, the operators are like TableFullScan_5 with the access object table:user.
+func (d *dbBaseTidb) explain(ctx context.Context, q dbQuerier, query string, args []interface{}) (*QueryPlan, error) {
+	raw, err := queryExplainRows(ctx, q, "EXPLAIN "+query, args)
+	if err != nil {
+		return nil, err
+	}
+	plan := &QueryPlan{Raw: raw}
+	for _, row := range raw {
+		// trim the tree prefix like └─ and the operator id
+		operator := strings.TrimLeft(explainString(row["id"]), "└├│─ ")
+		if i := strings.LastIndex(operator, "_"); i > 0 {
+			operator = operator[:i]
+		}
+		step := PlanStep{
+			Access: operator,
+			Detail: explainString(row["operator info"]),
+		}
+		for _, obj := range strings.Split(explainString(row["access object"]), ",") {
+			kv := strings.SplitN(strings.TrimSpace(obj), ":", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			switch kv[0] {
+			case "table":
+				step.Table = kv[1]
+			case "index":
+				step.Index = kv[1]
+			}
+		}
+		rows, _ := strconv.ParseFloat(explainString(row["estRows"]), 64)
+		step.Rows = int64(rows)
+		step.FullScan = operator == "TableFullScan"
+		plan.Steps = append(plan.Steps, step)
+	}
+	return plan, nil
+}
+
 // create new mysql dbBaser.
 func newdbBaseTidb() dbBaser {
 	b := new(dbBaseTidb)
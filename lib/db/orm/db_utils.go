@@ -31,7 +31,27 @@ func getDbAlias(name string) *alias {
 // get pk column info.
 func getExistPk(mi *modelInfo, ind reflect.Value) (column string, value interface{}, exist bool) {
 	fi := mi.fields.pk
+	value, exist = getFieldPk(fi, ind)
+	column = fi.column
+	return
+}
+
+// get all pk columns info of composite primary key, exist is false if one of the pk values not exist.
+func getExistPks(mi *modelInfo, ind reflect.Value) (columns []string, values []interface{}, exist bool) {
+	exist = true
+	for _, fi := range mi.fields.pks {
+		value, ok := getFieldPk(fi, ind)
+		if !ok {
+			exist = false
+		}
+		columns = append(columns, fi.column)
+		values = append(values, value)
+	}
+	return
+}
 
+// get value of pk field.
+func getFieldPk(fi *fieldInfo, ind reflect.Value) (value interface{}, exist bool) {
 	v := ind.FieldByIndex(fi.fieldIndex)
 	if fi.fieldType&IsPositiveIntegerField > 0 {
 		vu := v.Uint()
@@ -42,14 +62,15 @@ func getExistPk(mi *modelInfo, ind reflect.Value) (column string, value interfac
 		exist = true
 		value = vu
 	} else if fi.fieldType&IsRelField > 0 {
+		if v.IsNil() {
+			return nil, false
+		}
 		_, value, exist = getExistPk(fi.relModelInfo, reflect.Indirect(v))
 	} else {
 		vu := v.String()
 		exist = vu != ""
 		value = vu
 	}
-
-	column = fi.column
 	return
 }
 
@@ -65,6 +86,11 @@ outFor:
 			continue
 		}
 
+		if t, ok := arg.(fullTime); ok {
+			params = append(params, time.Time(t).In(tz))
+			continue
+		}
+
 		kind := val.Kind()
 		if kind == reflect.Ptr {
 			val = val.Elem()
@@ -159,6 +185,9 @@ outFor:
 				name := getFullName(typ)
 				var value interface{}
 				if mmi, ok := modelCache.getByFullName(name); ok {
+					if len(mmi.fields.pks) > 1 {
+						panic(fmt.Errorf("model `%s` with composite primary key cannot be used as args", name))
+					}
 					if _, vu, exist := getExistPk(mmi, val); exist {
 						value = vu
 					}
@@ -0,0 +1,398 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MigrationTable is the table keeping the applied migrations.
+var MigrationTable = "migrations"
+
+// MigrationFunc change the schema inside the transaction of o.
+// mysql and tidb commit DDL statements implicitly, a failed migration with several DDL statements
+// is left partly applied and not recorded there, so keep one DDL statement in each of their migrations.
+type MigrationFunc func(o Ormer) error
+
+// Migration is a versioned schema change.
+// Version orders the migrations, `orm migrate create` use the timestamp 20060102150405.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      MigrationFunc
+	Down    MigrationFunc
+}
+
+// MigrationState is the state of a migration in a database.
+type MigrationState struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// registered migrations.
+type _migrationCache struct {
+	mux   sync.RWMutex
+	cache map[int64]*Migration
+}
+
+var migrationCache = &_migrationCache{cache: make(map[int64]*Migration)}
+
+// add migration, a version can only be registered once.
+func (mc *_migrationCache) add(m *Migration) error {
+	mc.mux.Lock()
+	defer mc.mux.Unlock()
+	if old, ok := mc.cache[m.Version]; ok {
+		return fmt.Errorf("migration version `%d` already registered by `%s`", m.Version, old.Name)
+	}
+	mc.cache[m.Version] = m
+	return nil
+}
+
+// get migration by version.
+func (mc *_migrationCache) get(version int64) (m *Migration, ok bool) {
+	mc.mux.RLock()
+	defer mc.mux.RUnlock()
+	m, ok = mc.cache[version]
+	return
+}
+
+// get all migrations ordered by version.
+func (mc *_migrationCache) allOrdered() []*Migration {
+	mc.mux.RLock()
+	defer mc.mux.RUnlock()
+	ms := make([]*Migration, 0, len(mc.cache))
+	for _, m := range mc.cache {
+		ms = append(ms, m)
+	}
+	sort.Slice(ms, func(i, j int) bool { return ms[i].Version < ms[j].Version })
+	return ms
+}
+
+// RegisterMigration register a Go migration.
+// down can be nil if the migration cannot be reverted.
+func RegisterMigration(version int64, name string, up, down MigrationFunc) {
+	if up == nil {
+		panic(fmt.Errorf("<orm.RegisterMigration> migration `%d_%s` need an up func", version, name))
+	}
+	m := &Migration{Version: version, Name: name, Up: up, Down: down}
+	if err := migrationCache.add(m); err != nil {
+		panic(fmt.Errorf("<orm.RegisterMigration> %s", err.Error()))
+	}
+}
+
+// RegisterMigrationSQL register a migration executing sql statements.
+// empty down means the migration cannot be reverted.
+func RegisterMigrationSQL(version int64, name string, up, down []string) error {
+	m := &Migration{Version: version, Name: name, Up: execMigrationSQL(up)}
+	if len(down) > 0 {
+		m.Down = execMigrationSQL(down)
+	}
+	return migrationCache.add(m)
+}
+
+// execute statements one by one.
+func execMigrationSQL(queries []string) MigrationFunc {
+	return func(o Ormer) error {
+		for _, query := range queries {
+			if _, err := o.Raw(query).Exec(); err != nil {
+				return fmt.Errorf("%s, %s", query, err.Error())
+			}
+		}
+		return nil
+	}
+}
+
+var migrationFileRe = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// LoadMigrations register the sql migrations in dir.
+// files are named <version>_<name>.up.sql and <version>_<name>.down.sql,
+// statements in a file are separated by a semicolon at the end of line.
+// the down file is optional, a version without up file is an error.
+func LoadMigrations(dir string) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	type sqlMigration struct {
+		name     string
+		up, down []string
+	}
+	migrations := make(map[int64]*sqlMigration)
+	var versions []int64
+
+	for _, f := range files {
+		match := migrationFileRe.FindStringSubmatch(f.Name())
+		if f.IsDir() || match == nil {
+			continue
+		}
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		content, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return err
+		}
+
+		m, ok := migrations[version]
+		if !ok {
+			m = &sqlMigration{name: match[2]}
+			migrations[version] = m
+			versions = append(versions, version)
+		}
+		if match[3] == "up" {
+			m.up = splitSQLStatements(string(content))
+		} else {
+			m.down = splitSQLStatements(string(content))
+		}
+	}
+
+	for _, version := range versions {
+		if migrations[version].up == nil {
+			return fmt.Errorf("migration %d_%s has no up file", version, migrations[version].name)
+		}
+	}
+	for _, version := range versions {
+		m := migrations[version]
+		if err := RegisterMigrationSQL(version, m.name, m.up, m.down); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// split sql script into statements, comment lines are dropped.
+func splitSQLStatements(content string) (queries []string) {
+	var lines []string
+	for _, line := range strings.Split(content, "\n") {
+		trimed := strings.TrimSpace(line)
+		if trimed == "" || strings.HasPrefix(trimed, "--") {
+			continue
+		}
+		lines = append(lines, line)
+		if strings.HasSuffix(trimed, ";") {
+			queries = append(queries, strings.TrimSuffix(strings.TrimSpace(strings.Join(lines, "\n")), ";"))
+			lines = lines[:0]
+		}
+	}
+	if len(lines) > 0 {
+		queries = append(queries, strings.TrimSpace(strings.Join(lines, "\n")))
+	}
+	return
+}
+
+// runs migrations against a database alias.
+type migrator struct {
+	al *alias
+}
+
+// create migrator for the database alias.
+func newMigrator(aliasName string) (*migrator, error) {
+	BootStrap()
+
+	al, ok := dataBaseCache.get(aliasName)
+	if !ok {
+		return nil, fmt.Errorf("DataBase alias name `%s` not registered", aliasName)
+	}
+	return &migrator{al: al}, nil
+}
+
+// create new ormer of the alias.
+func (m *migrator) ormer() (Ormer, error) {
+	o := new(orm)
+	if err := o.Using(m.al.Name); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// create the migration table if not exists.
+func (m *migrator) ensureTable() error {
+	Q := m.al.DbBaser.TableQuote()
+	T := m.al.DbBaser.DbTypes()
+	query := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s%s%s (%s%s%s %s NOT NULL PRIMARY KEY, %s%s%s %s NOT NULL, %s%s%s %s NOT NULL)",
+		Q, MigrationTable, Q,
+		Q, "version", Q, T["int64"],
+		Q, "name", Q, fmt.Sprintf(T["string"], 255),
+		Q, "applied_at", Q, T["time.Time"],
+	)
+	_, err := m.al.DB.Exec(query)
+	return err
+}
+
+// get the applied migrations.
+func (m *migrator) applied() (map[int64]MigrationState, error) {
+	if err := m.ensureTable(); err != nil {
+		return nil, err
+	}
+
+	Q := m.al.DbBaser.TableQuote()
+	query := fmt.Sprintf("SELECT %sversion%s, %sname%s, %sapplied_at%s FROM %s%s%s", Q, Q, Q, Q, Q, Q, Q, MigrationTable, Q)
+	o, err := m.ormer()
+	if err != nil {
+		return nil, err
+	}
+	var maps []Params
+	if _, err := o.Raw(query).Values(&maps); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int64]MigrationState, len(maps))
+	for _, row := range maps {
+		version, _ := StrTo(ToStr(row["version"])).Int64()
+		st := MigrationState{Version: version, Name: ToStr(row["name"]), Applied: true}
+		switch v := row["applied_at"].(type) {
+		case time.Time:
+			st.AppliedAt = v
+		case string:
+			st.AppliedAt, _ = timeParse(v, formatDateTime)
+		}
+		applied[version] = st
+	}
+	return applied, nil
+}
+
+// run fn of migration mg inside transaction and record it.
+// the DDL statements committed implicitly by mysql and tidb are not rolled back on failure.
+func (m *migrator) run(mg *Migration, fn MigrationFunc, up bool) error {
+	o, err := m.ormer()
+	if err != nil {
+		return err
+	}
+	if err := o.Begin(); err != nil {
+		return err
+	}
+
+	Q := m.al.DbBaser.TableQuote()
+	if err = fn(o); err == nil {
+		if up {
+			query := fmt.Sprintf("INSERT INTO %s%s%s (%sversion%s, %sname%s, %sapplied_at%s) VALUES (?, ?, ?)",
+				Q, MigrationTable, Q, Q, Q, Q, Q, Q, Q)
+			_, err = o.Raw(query, mg.Version, mg.Name, time.Now().In(m.al.TZ)).Exec()
+		} else {
+			query := fmt.Sprintf("DELETE FROM %s%s%s WHERE %sversion%s = ?", Q, MigrationTable, Q, Q, Q)
+			_, err = o.Raw(query, mg.Version).Exec()
+		}
+	}
+	if err != nil {
+		o.Rollback()
+		return fmt.Errorf("migration `%d_%s` failed, %s", mg.Version, mg.Name, err.Error())
+	}
+	return o.Commit()
+}
+
+// MigrateUp apply the pending migrations to the database alias in version order.
+// steps limit the number of migrations applied, steps <= 0 applies all of them.
+func MigrateUp(aliasName string, steps int) ([]*Migration, error) {
+	m, err := newMigrator(aliasName)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []*Migration
+	for _, mg := range migrationCache.allOrdered() {
+		if steps > 0 && len(done) >= steps {
+			break
+		}
+		if _, ok := applied[mg.Version]; ok {
+			continue
+		}
+		if err := m.run(mg, mg.Up, true); err != nil {
+			return done, err
+		}
+		done = append(done, mg)
+	}
+	return done, nil
+}
+
+// MigrateDown revert the latest applied migrations of the database alias.
+// steps is the number of migrations reverted, steps <= 0 reverts one.
+func MigrateDown(aliasName string, steps int) ([]*Migration, error) {
+	m, err := newMigrator(aliasName)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	if steps <= 0 {
+		steps = 1
+	}
+
+	versions := make([]int64, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+
+	var done []*Migration
+	for _, version := range versions {
+		if len(done) >= steps {
+			break
+		}
+		mg, ok := migrationCache.get(version)
+		if !ok {
+			return done, fmt.Errorf("migration `%d_%s` is applied but not registered", version, applied[version].Name)
+		}
+		if mg.Down == nil {
+			return done, fmt.Errorf("migration `%d_%s` cannot be reverted", mg.Version, mg.Name)
+		}
+		if err := m.run(mg, mg.Down, false); err != nil {
+			return done, err
+		}
+		done = append(done, mg)
+	}
+	return done, nil
+}
+
+// MigrationStatus get the state of registered and applied migrations ordered by version.
+func MigrationStatus(aliasName string) ([]MigrationState, error) {
+	m, err := newMigrator(aliasName)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var states []MigrationState
+	for _, mg := range migrationCache.allOrdered() {
+		st, ok := applied[mg.Version]
+		if !ok {
+			st = MigrationState{Version: mg.Version, Name: mg.Name}
+		}
+		delete(applied, mg.Version)
+		states = append(states, st)
+	}
+	for _, st := range applied {
+		states = append(states, st)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Version < states[j].Version })
+	return states, nil
+}
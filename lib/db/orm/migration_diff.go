@@ -0,0 +1,227 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	columnTypCheckRe = regexp.MustCompile(`(?i)\s+check\s*\(.*$`)
+	columnTypIntRe   = regexp.MustCompile(`^(tinyint|smallint|mediumint|int|integer|bigint)\(\d+\)`)
+	columnTypSizeRe  = regexp.MustCompile(`\(.*\)`)
+	columnTypSpaceRe = regexp.MustCompile(`\s*,\s*`)
+)
+
+// synonyms of column types reported by the databases.
+var columnTypSynonyms = map[DriverType]map[string]string{
+	DRMySQL: {
+		"integer":          "int",
+		"integer unsigned": "int unsigned",
+		"bool":             "tinyint(1)",
+		"boolean":          "tinyint(1)",
+		"double precision": "double",
+	},
+	DRPostgres: {
+		"varchar":   "character varying",
+		"char":      "character",
+		"bool":      "boolean",
+		"timestamp": "timestamp without time zone",
+		"int":       "integer",
+	},
+}
+
+// normalize column type of driver for comparing.
+func normalizeColumnTyp(driver DriverType, typ string) string {
+	typ = strings.ToLower(strings.TrimSpace(typ))
+	typ = columnTypCheckRe.ReplaceAllString(typ, "")
+	typ = columnTypSpaceRe.ReplaceAllString(typ, ",")
+	switch driver {
+	case DRMySQL:
+		if !strings.HasPrefix(typ, "tinyint(1)") {
+			typ = columnTypIntRe.ReplaceAllString(typ, "$1")
+		}
+	case DRPostgres:
+		// information_schema reports no size
+		typ = columnTypSizeRe.ReplaceAllString(typ, "")
+	}
+	if s, ok := columnTypSynonyms[driver][typ]; ok {
+		typ = s
+	}
+	return typ
+}
+
+// check the live column nullable flag returned by GetColumns.
+func columnNullable(driver DriverType, null string) bool {
+	if driver == DRSqlite {
+		// pragma table_info report notnull
+		return null == "0"
+	}
+	return strings.ToUpper(null) == "YES"
+}
+
+// create alter sql string changing the type and nullable of column.
+func getColumnAlterQueries(al *alias, fi *fieldInfo) []string {
+	Q := al.DbBaser.TableQuote()
+	typ := getColumnTyp(al, fi)
+
+	switch al.Driver {
+	case DRPostgres:
+		typ = columnTypCheckRe.ReplaceAllString(typ, "")
+		null := "DROP NOT NULL"
+		if !fi.null {
+			null = "SET NOT NULL"
+		}
+		return []string{
+			fmt.Sprintf("ALTER TABLE %s%s%s ALTER COLUMN %s%s%s TYPE %s USING %s%s%s::%s",
+				Q, fi.mi.table, Q, Q, fi.column, Q, typ, Q, fi.column, Q, typ),
+			fmt.Sprintf("ALTER TABLE %s%s%s ALTER COLUMN %s%s%s %s",
+				Q, fi.mi.table, Q, Q, fi.column, Q, null),
+		}
+	default:
+		if !fi.null {
+			typ += " " + "NOT NULL"
+		}
+		return []string{fmt.Sprintf("ALTER TABLE %s%s%s MODIFY COLUMN %s%s%s %s %s",
+			Q, fi.mi.table, Q,
+			Q, fi.column, Q,
+			typ, getColumnDefault(fi),
+		)}
+	}
+}
+
+// create drop column sql string.
+func getColumnDropQuery(al *alias, table, column string) string {
+	Q := al.DbBaser.TableQuote()
+	return fmt.Sprintf("ALTER TABLE %s%s%s DROP COLUMN %s%s%s", Q, table, Q, Q, column, Q)
+}
+
+// create the sql rebuilding a sqlite table, which cannot alter columns.
+// columns kept in the new table are copied.
+func getTableRebuildQueries(al *alias, mi *modelInfo, create string, columns map[string][3]string) []string {
+	Q := al.DbBaser.TableQuote()
+	tmp := "_orm_new_" + mi.table
+
+	var cols []string
+	for _, fi := range mi.fields.fieldsDB {
+		if _, ok := columns[fi.column]; ok {
+			cols = append(cols, fi.column)
+		}
+	}
+	sep := fmt.Sprintf("%s, %s", Q, Q)
+
+	return []string{
+		strings.Replace(create, Q+mi.table+Q, Q+tmp+Q, 1),
+		fmt.Sprintf("INSERT INTO %s%s%s (%s%s%s) SELECT %s%s%s FROM %s%s%s;",
+			Q, tmp, Q, Q, strings.Join(cols, sep), Q, Q, strings.Join(cols, sep), Q, Q, mi.table, Q),
+		fmt.Sprintf("DROP TABLE %s%s%s;", Q, mi.table, Q),
+		fmt.Sprintf("ALTER TABLE %s%s%s RENAME TO %s%s%s;", Q, tmp, Q, Q, mi.table, Q),
+	}
+}
+
+// compare registered models with the live schema of alias and create the sql to migrate it.
+// renamed columns can't be detected, they show up as dropped and added columns.
+func getDbDiffSQL(al *alias) ([]string, error) {
+	db := al.DB
+	sqls, indexes := getDbCreateSQL(al)
+
+	tables, err := al.DbBaser.GetTables(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var queries []string
+	for i, mi := range modelCache.allOrderedOf(al) {
+		if !tables[mi.table] {
+			queries = append(queries, sqls[i])
+			for _, idx := range indexes[mi.table] {
+				queries = append(queries, idx.SQL)
+			}
+			continue
+		}
+
+		columns, err := al.DbBaser.GetColumns(db, mi.table)
+		if err != nil {
+			return nil, err
+		}
+
+		var (
+			alters  []string
+			rebuild bool
+		)
+		for _, fi := range mi.fields.fieldsDB {
+			col, ok := columns[fi.column]
+			if !ok {
+				alters = append(alters, getColumnAddQuery(al, fi)+";")
+				continue
+			}
+			if fi.pk {
+				continue
+			}
+			if normalizeColumnTyp(al.Driver, col[1]) == normalizeColumnTyp(al.Driver, getColumnTyp(al, fi)) &&
+				columnNullable(al.Driver, col[2]) == fi.null {
+				continue
+			}
+			if al.Driver == DRSqlite {
+				rebuild = true
+				continue
+			}
+			for _, query := range getColumnAlterQueries(al, fi) {
+				alters = append(alters, query+";")
+			}
+		}
+
+		var drops []string
+		for name := range columns {
+			if _, ok := mi.fields.columns[name]; !ok {
+				drops = append(drops, name)
+			}
+		}
+		sort.Strings(drops)
+		for _, name := range drops {
+			alters = append(alters, getColumnDropQuery(al, mi.table, name)+";")
+		}
+
+		if rebuild {
+			queries = append(queries, getTableRebuildQueries(al, mi, sqls[i], columns)...)
+			for _, idx := range indexes[mi.table] {
+				queries = append(queries, idx.SQL)
+			}
+			continue
+		}
+
+		queries = append(queries, alters...)
+		for _, idx := range indexes[mi.table] {
+			if !al.DbBaser.IndexExists(db, idx.Table, idx.Name) {
+				queries = append(queries, idx.SQL)
+			}
+		}
+	}
+
+	return queries, nil
+}
+
+// MigrationDiff compare the registered models with the live schema of the database alias,
+// and return the statements migrating the schema to the models.
+func MigrationDiff(aliasName string) ([]string, error) {
+	m, err := newMigrator(aliasName)
+	if err != nil {
+		return nil, err
+	}
+	return getDbDiffSQL(m.al)
+}
@@ -61,6 +61,24 @@ func (mc *_modelCache) allOrdered() []*modelInfo {
 	return m
 }
 
+// get ordered model info of the tables on alias al,
+// a sharded model is replaced by its shard tables on al.
+func (mc *_modelCache) allOrderedOf(al *alias) []*modelInfo {
+	m := make([]*modelInfo, 0, len(mc.orders))
+	for _, mi := range mc.allOrdered() {
+		if mi.shard == nil {
+			m = append(m, mi)
+			continue
+		}
+		for i, shard := range mi.shard.Shards {
+			if shard.Alias == al.Name {
+				m = append(m, mi.shard.tables[i])
+			}
+		}
+	}
+	return m
+}
+
 // get model info by table name
 func (mc *_modelCache) get(table string) (mi *modelInfo, ok bool) {
 	mi, ok = mc.cache[table]
@@ -97,3 +115,43 @@ func (mc *_modelCache) clean() {
 func ResetModelCache() {
 	modelCache.clean()
 }
+
+// SortTablesByRelation sort the tables so that the tables referenced by the
+// foreign key and one to one fields of a model come before it, like the order of inserting rows.
+// the tables not registered are put at the end, circular references keep the given order.
+func SortTablesByRelation(tables []string) []string {
+	BootStrap()
+	wanted := make(map[string]bool, len(tables))
+	for _, table := range tables {
+		wanted[table] = true
+	}
+	var (
+		sorted  = make([]string, 0, len(tables))
+		unknown []string
+		// 1 is visiting, 2 is visited
+		state = make(map[string]int, len(tables))
+		visit func(table string)
+	)
+	visit = func(table string) {
+		if state[table] != 0 {
+			return
+		}
+		state[table] = 1
+		mi, _ := modelCache.get(table)
+		for _, fi := range mi.fields.fieldsDB {
+			if fi.rel && wanted[fi.relModelInfo.table] {
+				visit(fi.relModelInfo.table)
+			}
+		}
+		state[table] = 2
+		sorted = append(sorted, table)
+	}
+	for _, table := range tables {
+		if _, ok := modelCache.get(table); !ok {
+			unknown = append(unknown, table)
+			continue
+		}
+		visit(table)
+	}
+	return append(sorted, unknown...)
+}
@@ -60,6 +60,49 @@ func registerModel(PrefixOrSuffix string, model interface{}, isPrefix bool) {
 	}
 
 	mi := newModelInfo(val)
+	for _, fi := range mi.fields.fieldsDB {
+		if fi.blindIndexName == "" {
+			continue
+		}
+		bfi, ok := mi.fields.GetByAny(fi.blindIndexName)
+		if !ok || !bfi.dbcol || bfi.encrypt || bfi.blindIndexOf != nil || (bfi.fieldType != TypeVarCharField && bfi.fieldType != TypeCharField) {
+			fmt.Printf("<orm.RegisterModel> `%s` blind_index of field `%s` must be another string field\n", name, fi.name)
+			os.Exit(2)
+		}
+		fi.blindIndex = bfi
+		bfi.blindIndexOf = fi
+	}
+	if names := getTablePrimaryKey(val); len(names) > 0 {
+		for _, fi := range mi.fields.pks {
+			fi.pk = false
+			fi.auto = false
+		}
+		mi.fields.pk = nil
+		mi.fields.pks = nil
+		for _, n := range names {
+			fi, ok := mi.fields.GetByAny(n)
+			if !ok || !fi.dbcol {
+				fmt.Printf("<orm.RegisterModel> cannot found column `%s` when parse `%s.TablePrimaryKey`\n", n, name)
+				os.Exit(2)
+			}
+			fi.pk = true
+			fi.null = false
+			fi.index = false
+			fi.unique = false
+			if mi.fields.pk == nil {
+				mi.fields.pk = fi
+			}
+			mi.fields.pks = append(mi.fields.pks, fi)
+		}
+	}
+	if len(mi.fields.pks) > 1 {
+		for _, fi := range mi.fields.pks {
+			if fi.auto {
+				fmt.Printf("<orm.RegisterModel> `%s` composite primary key cannot contain auto field `%s`\n", name, fi.name)
+				os.Exit(2)
+			}
+		}
+	}
 	if mi.fields.pk == nil {
 	outFor:
 		for _, fi := range mi.fields.fieldsDB {
@@ -69,6 +112,7 @@ func registerModel(PrefixOrSuffix string, model interface{}, isPrefix bool) {
 					fi.auto = true
 					fi.pk = true
 					mi.fields.pk = fi
+					mi.fields.pks = []*fieldInfo{fi}
 					break outFor
 				}
 			}
@@ -86,6 +130,22 @@ func registerModel(PrefixOrSuffix string, model interface{}, isPrefix bool) {
 	mi.model = model
 	mi.manual = true
 
+	if rule := getTableShard(val); rule != nil {
+		mi.shard = rule
+		if err := rule.init(mi); err != nil {
+			fmt.Printf("<orm.RegisterModel> `%s.TableShard` %s\n", name, err.Error())
+			os.Exit(2)
+		}
+	}
+
+	if resolver := getTableTenant(val); resolver != nil {
+		if mi.shard != nil {
+			fmt.Printf("<orm.RegisterModel> `%s` cannot be both sharded and tenant model\n", name)
+			os.Exit(2)
+		}
+		mi.tenant = resolver
+	}
+
 	modelCache.set(table, mi)
 }
 
@@ -122,6 +182,16 @@ func bootStrap() {
 				}
 				fi.relModelInfo = mii
 
+				// relations are joined by the single pk column
+				if fi.rel && len(mii.fields.pks) > 1 {
+					err = fmt.Errorf("field `%s` cannot rel to model `%s` with composite primary key", fi.fullName, mii.fullName)
+					goto end
+				}
+				if fi.fieldType == RelManyToMany && len(mi.fields.pks) > 1 {
+					err = fmt.Errorf("field `%s` m2m is not supported by model with composite primary key", fi.fullName)
+					goto end
+				}
+
 				switch fi.fieldType {
 				case RelManyToMany:
 					if fi.relThrough != "" {
@@ -302,7 +372,14 @@ end:
 	}
 }
 
-// RegisterModel register models
+// RegisterModel register models.
+// a model has a composite primary key by several fields with orm:"pk" or by method:
+// 	func (v *PostVote) TablePrimaryKey() []string {
+// 		return []string{"Post", "User"}
+// 	}
+// the model with composite primary key works with CRUD, InsertOrUpdate and RelatedSel of its own relations,
+// but it cannot have m2m fields and no relation can point to it, so QueryM2M, LoadRelated, Prefetch and
+// RelatedSel reaching it are not supported. its model struct cannot be used as filter args either.
 func RegisterModel(models ...interface{}) {
 	if modelCache.done {
 		panic(fmt.Errorf("RegisterModel must be run before BootStrap"))
@@ -26,6 +26,9 @@ var errSkipField = errors.New("skip field")
 // field info collection
 type fields struct {
 	pk            *fieldInfo
+	pks           []*fieldInfo
+	version       *fieldInfo
+	softDelete    *fieldInfo
 	columns       map[string]*fieldInfo
 	fields        map[string]*fieldInfo
 	fieldsLow     map[string]*fieldInfo
@@ -122,6 +125,8 @@ type fieldInfo struct {
 	toText              bool
 	autoNow             bool
 	autoNowAdd          bool
+	version             bool // optimistic lock version column
+	softDelete          bool // soft delete flag or time column
 	rel                 bool // if type equal to RelForeignKey, RelOneToOne, RelManyToMany then true
 	reverse             bool
 	reverseField        string
@@ -135,6 +140,11 @@ type fieldInfo struct {
 	digits              int
 	decimals            int
 	isFielder           bool // implement Fielder interface
+	isJSON              bool // struct, map or slice marshaled as json document
+	encrypt             bool // encrypted by the key provider
+	blindIndexName      string
+	blindIndex          *fieldInfo // blind index field of encrypted field
+	blindIndexOf        *fieldInfo // encrypted field of blind index field
 	onDelete            string
 	description         string
 }
@@ -241,6 +251,15 @@ checkType:
 			}
 		}
 
+		if t := tags["type"]; (t == "json" || t == "jsonb") && isJSONType(sf.Type) {
+			fi.isJSON = true
+			fieldType = TypeJSONField
+			if t == "jsonb" {
+				fieldType = TypeJsonbField
+			}
+			break checkType
+		}
+
 		fieldType, err = getFieldType(addrField)
 		if err != nil {
 			goto end
@@ -307,6 +326,10 @@ checkType:
 	fi.auto = attrs["auto"]
 	fi.pk = attrs["pk"]
 	fi.unique = attrs["unique"]
+	fi.version = attrs["version"]
+	fi.softDelete = attrs["soft_delete"]
+	fi.encrypt = attrs["encrypt"]
+	fi.blindIndexName = tags["blind_index"]
 
 	// Mark object property if there is attribute "default" in the orm configuration
 	if _, ok := tags["default"]; ok {
@@ -406,6 +429,43 @@ checkType:
 			err = fmt.Errorf("non-integer type cannot set auto")
 			goto end
 		}
+		if fi.version {
+			err = fmt.Errorf("non-integer type cannot set version")
+			goto end
+		}
+	}
+
+	if fi.version && (fi.pk || fi.auto) {
+		err = fmt.Errorf("pk field cannot set version")
+		goto end
+	}
+
+	if fi.softDelete {
+		switch fieldType {
+		case TypeBooleanField:
+		case TypeDateField, TypeDateTimeField:
+			// not deleted rows are null
+			fi.null = true
+		default:
+			err = fmt.Errorf("soft_delete only support bool, date and datetime field")
+			goto end
+		}
+	}
+
+	if fi.encrypt {
+		switch fieldType {
+		case TypeVarCharField, TypeCharField, TypeTextField:
+		default:
+			err = fmt.Errorf("encrypt only support string field")
+			goto end
+		}
+		if fi.pk || fi.index || fi.unique {
+			err = fmt.Errorf("encrypt field cannot set pk, index or unique, set them on the blind_index field")
+			goto end
+		}
+	} else if fi.blindIndexName != "" {
+		err = fmt.Errorf("blind_index need encrypt field")
+		goto end
 	}
 
 	if fi.auto || fi.pk {
@@ -32,6 +32,10 @@ type modelInfo struct {
 	addrField reflect.Value //store the original struct value
 	uniques   []string
 	isThrough bool
+	shard     *ShardRule
+	tenant    TenantResolver
+	tenantOf  *modelInfo // registered model info of the tenant table
+	tenantID  string     // tenant of the tenant table
 }
 
 // new model info
@@ -84,12 +88,24 @@ func addModelFields(mi *modelInfo, ind reflect.Value, mName string, index []int)
 			break
 		}
 		if fi.pk {
-			if mi.fields.pk != nil {
-				err = fmt.Errorf("one model must have one pk field only")
-				break
-			} else {
+			if mi.fields.pk == nil {
 				mi.fields.pk = fi
 			}
+			mi.fields.pks = append(mi.fields.pks, fi)
+		}
+		if fi.version {
+			if mi.fields.version != nil {
+				err = fmt.Errorf("one model must have one version field only")
+				break
+			}
+			mi.fields.version = fi
+		}
+		if fi.softDelete {
+			if mi.fields.softDelete != nil {
+				err = fmt.Errorf("one model must have one soft_delete field only")
+				break
+			}
+			mi.fields.softDelete = fi
 		}
 	}
 
@@ -142,6 +158,7 @@ func newM2MModelInfo(m1, m2 *modelInfo) (mi *modelInfo) {
 	mi.fields.Add(f1)
 	mi.fields.Add(f2)
 	mi.fields.pk = fa
+	mi.fields.pks = []*fieldInfo{fa}
 
 	mi.uniques = []string{f1.column, f2.column}
 	return
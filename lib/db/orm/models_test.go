@@ -15,10 +15,14 @@
 package orm
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -293,7 +297,7 @@ type Post struct {
 	Content string    `orm:"type(text)"`
 	Created time.Time `orm:"auto_now_add"`
 	Updated time.Time `orm:"auto_now"`
-	Tags    []*Tag    `orm:"rel(m2m);rel_through(github.com/astaxie/beego/orm.PostTags)"`
+	Tags    []*Tag    `orm:"rel(m2m);rel_through(github.com/gopherchai/contrib/lib/db/orm.PostTags)"`
 }
 
 func (u *Post) TableIndex() [][]string {
@@ -351,7 +355,7 @@ type Group struct {
 type Permission struct {
 	ID     int `orm:"column(id)"`
 	Name   string
-	Groups []*Group `orm:"rel(m2m);rel_through(github.com/astaxie/beego/orm.GroupPermissions)"`
+	Groups []*Group `orm:"rel(m2m);rel_through(github.com/gopherchai/contrib/lib/db/orm.GroupPermissions)"`
 }
 
 type GroupPermissions struct {
@@ -411,16 +415,163 @@ type PtrPk struct {
 	Positive bool
 }
 
+var errHookProtected = errors.New("hook model is protected")
+
+type HookModel struct {
+	ID    int    `orm:"column(id)"`
+	Name  string `orm:"size(30)"`
+	Audit string `orm:"size(30)"`
+	Reads int    `orm:"-"`
+}
+
+func (m *HookModel) BeforeInsert(ctx context.Context) error {
+	if m.Name == "" {
+		return errHookProtected
+	}
+	m.Audit = "inserted"
+	return nil
+}
+
+func (m *HookModel) BeforeUpdate(ctx context.Context) error {
+	m.Audit = "updated"
+	return nil
+}
+
+func (m *HookModel) BeforeDelete(ctx context.Context) error {
+	if m.Name == "protected" {
+		return errHookProtected
+	}
+	return nil
+}
+
+func (m *HookModel) AfterRead(ctx context.Context) error {
+	m.Reads++
+	return nil
+}
+
+type VersionModel struct {
+	ID      int    `orm:"column(id)"`
+	Name    string `orm:"size(30)"`
+	Version int    `orm:"version"`
+}
+
+type UpsertModel struct {
+	ID      int    `orm:"column(id)"`
+	Code    string `orm:"size(30);unique"`
+	Name    string `orm:"size(30)"`
+	Nums    int
+	Created time.Time `orm:"auto_now_add;type(datetime)"`
+	Updated time.Time `orm:"auto_now;type(datetime)"`
+	Version int       `orm:"version"`
+}
+
+type PostVote struct {
+	Post  *Post `orm:"rel(fk);pk"`
+	User  *User `orm:"rel(fk);pk"`
+	Score int
+}
+
+type Metric struct {
+	Device string `orm:"size(20)"`
+	Ts     int64
+	Value  float64
+}
+
+func (m *Metric) TablePrimaryKey() []string {
+	return []string{"Device", "Ts"}
+}
+
+type JSONAttrs struct {
+	Color string `json:"color"`
+	Size  struct {
+		Width int `json:"width"`
+	} `json:"size"`
+}
+
+type JSONDoc struct {
+	ID    int                    `orm:"column(id)"`
+	Attrs JSONAttrs              `orm:"type(json)"`
+	Tags  []string               `orm:"type(jsonb)"`
+	Meta  map[string]interface{} `orm:"type(json);null"`
+}
+
+type ShardOrder struct {
+	ID     int `orm:"column(id)"`
+	Owner  int64
+	Amount int
+}
+
+func (o *ShardOrder) TableShard() *ShardRule {
+	return &ShardRule{Key: "Owner", Strategy: ShardByHash(), Shards: []Shard{{"default", "_0"}, {"shard_test", "_1"}}}
+}
+
+func (o *ShardOrder) BeforeInsert(ctx context.Context) error {
+	if o.Owner == 0 {
+		o.Owner = 3
+	}
+	return nil
+}
+
+type SecretUser struct {
+	ID         int     `orm:"column(id)"`
+	Phone      string  `orm:"encrypt;blind_index(PhoneIndex)"`
+	PhoneIndex string  `orm:"size(64);index"`
+	Card       *string `orm:"encrypt;null"`
+}
+
+type TenantDoc struct {
+	ID    int    `orm:"column(id)"`
+	Title string `orm:"size(100)"`
+}
+
+func (d *TenantDoc) TableTenant() TenantResolver {
+	return TenantSuffix("_")
+}
+
+type TenantNote struct {
+	ID   int        `orm:"column(id)"`
+	Doc  *TenantDoc `orm:"rel(fk);null;on_delete(do_nothing)"`
+	Body string     `orm:"size(100)"`
+}
+
+type SoftTag struct {
+	ID      int    `orm:"column(id)"`
+	Name    string `orm:"size(30)"`
+	Deleted bool   `orm:"soft_delete"`
+}
+
+type SoftArticle struct {
+	ID        int        `orm:"column(id)"`
+	Title     string     `orm:"size(30)"`
+	Tags      []*SoftTag `orm:"rel(m2m)"`
+	DeletedAt time.Time  `orm:"soft_delete;type(datetime)"`
+}
+
 var DBARGS = struct {
 	Driver string
 	Source string
 	Debug  string
 }{
-	os.Getenv("ORM_DRIVER"),
-	os.Getenv("ORM_SOURCE"),
+	ormTestEnv("ORM_DRIVER", "sqlite3"),
+	ormTestEnv("ORM_SOURCE", ""),
 	os.Getenv("ORM_DEBUG"),
 }
 
+// get the env of test database, the tests run offline on a new sqlite database without ORM_DRIVER.
+func ormTestEnv(key, sqlite string) string {
+	if os.Getenv("ORM_DRIVER") != "" {
+		return os.Getenv(key)
+	}
+	if sqlite != "" {
+		return sqlite
+	}
+	dir, err := ioutil.TempDir("", "orm_test")
+	if err != nil {
+		panic(err)
+	}
+	return filepath.Join(dir, "orm_test.db")
+}
+
 var (
 	IsMysql    = DBARGS.Driver == "mysql"
 	IsSqlite   = DBARGS.Driver == "sqlite3"
@@ -461,8 +612,10 @@ var (
 	
 	#### Sqlite3
 	export ORM_DRIVER=sqlite3
-	export ORM_SOURCE='file:memory_test?mode=memory'
+	export ORM_SOURCE=/tmp/orm_test.db
 	go test -v github.com/astaxie/beego/orm
+
+	without ORM_DRIVER the tests run on a new sqlite database in the temp directory.
 	
 	
 	#### PostgreSQL
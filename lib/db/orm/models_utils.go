@@ -33,6 +33,9 @@ var supportTag = map[string]int{
 	"auto":         1,
 	"auto_now":     1,
 	"auto_now_add": 1,
+	"version":      1,
+	"soft_delete":  1,
+	"encrypt":      1,
 	"size":         2,
 	"column":       2,
 	"default":      2,
@@ -44,6 +47,7 @@ var supportTag = map[string]int{
 	"decimals":     2,
 	"on_delete":    2,
 	"type":         2,
+	"blind_index":  2,
 }
 
 // get reflect.Type name with package path.
@@ -105,6 +109,34 @@ func getTableUnique(val reflect.Value) [][]string {
 	return nil
 }
 
+// get table composite primary key from method, see RegisterModel.
+func getTablePrimaryKey(val reflect.Value) []string {
+	fun := val.MethodByName("TablePrimaryKey")
+	if fun.IsValid() {
+		vals := fun.Call([]reflect.Value{})
+		if len(vals) > 0 && vals[0].CanInterface() {
+			if d, ok := vals[0].Interface().([]string); ok {
+				return d
+			}
+		}
+	}
+	return nil
+}
+
+// get table shard rule from method.
+func getTableShard(val reflect.Value) *ShardRule {
+	fun := val.MethodByName("TableShard")
+	if fun.IsValid() {
+		vals := fun.Call([]reflect.Value{})
+		if len(vals) > 0 && vals[0].CanInterface() {
+			if d, ok := vals[0].Interface().(*ShardRule); ok {
+				return d
+			}
+		}
+	}
+	return nil
+}
+
 // get snaked column name
 func getColumnName(ft int, addrField reflect.Value, sf reflect.StructField, col string) string {
 	column := col
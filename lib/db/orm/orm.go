@@ -70,18 +70,26 @@ const (
 
 // Define common vars
 var (
-	Debug            = false
-	DebugLog         = NewLog(os.Stdout)
-	DefaultRowsLimit = 1000
-	DefaultRelsDepth = 2
-	DefaultTimeLoc   = time.Local
-	ErrTxHasBegan    = errors.New("<Ormer.Begin> transaction already begin")
-	ErrTxDone        = errors.New("<Ormer.Commit/Rollback> transaction not begin")
-	ErrMultiRows     = errors.New("<QuerySeter> return multi rows")
-	ErrNoRows        = errors.New("<QuerySeter> no row found")
-	ErrStmtClosed    = errors.New("<QuerySeter> stmt already closed")
-	ErrArgs          = errors.New("<Ormer> args error may be empty")
-	ErrNotImplement  = errors.New("have not implement")
+	Debug             = false
+	DebugLog          = NewLog(os.Stdout)
+	DefaultRowsLimit  = 1000
+	DefaultRelsDepth  = 2
+	DefaultTimeLoc    = time.Local
+	ErrTxHasBegan     = errors.New("<Ormer.Begin> transaction already begin")
+	ErrTxDone         = errors.New("<Ormer.Commit/Rollback> transaction not begin")
+	ErrMultiRows      = errors.New("<QuerySeter> return multi rows")
+	ErrNoRows         = errors.New("<QuerySeter> no row found")
+	ErrStmtClosed     = errors.New("<QuerySeter> stmt already closed")
+	ErrArgs           = errors.New("<Ormer> args error may be empty")
+	ErrNotImplement   = errors.New("have not implement")
+	ErrStaleObject    = errors.New("<Ormer.Update> stale object, version has been changed")
+	ErrNoSoftDelete   = errors.New("<QuerySeter.Restore> model has no soft_delete field")
+	ErrInvalidCursor  = errors.New("<QuerySeter.After> invalid cursor")
+	ErrNoShardKey     = errors.New("<QuerySeter> sharded model needs the exact condition of shard key, use AllShards to query all shards")
+	ErrShardFanOut    = errors.New("<QuerySeter> cannot run on more than one shard")
+	ErrShardKeyUpdate = errors.New("<Ormer> shard key cannot be updated, move the row to another shard by Delete and Insert")
+	ErrNoTenant       = errors.New("<Ormer> tenant model needs the tenant of context, use WithTenant or the context with tenant metadata")
+	ErrCrossTenant    = errors.New("<Ormer> tenant of context does not match the tenant of Ormer or transaction")
 )
 
 // Params stores the Params
@@ -91,9 +99,13 @@ type Params map[string]interface{}
 type ParamsList []interface{}
 
 type orm struct {
-	alias *alias
-	db    dbQuerier
-	isTx  bool
+	alias        *alias
+	db           dbQuerier
+	isTx         bool
+	forcePrimary bool
+	savepoint    int    // depth of savepoints in transaction
+	tenant       string // tenant bound by WithTenant
+	txTenant     string // tenant the transaction is pinned to
 }
 
 var _ Ormer = new(orm)
@@ -122,34 +134,93 @@ func (o *orm) getFieldInfo(mi *modelInfo, name string) *fieldInfo {
 	return fi
 }
 
+// get db querier bound to the given context
+func (o *orm) dbQuerier(ctx context.Context) dbQuerier {
+	return newDbQueryCtx(ctx, o.db)
+}
+
+// get db querier for read only statements bound to the given context.
+// outside of a transaction it may be served by a replica of the alias.
+func (o *orm) readQuerier(ctx context.Context) dbQuerier {
+	if o.isTx || o.forcePrimary {
+		return o.dbQuerier(ctx)
+	}
+	db := o.alias.readDB()
+	if db == dbQuerier(o.alias.DB) {
+		return o.dbQuerier(ctx)
+	}
+	return newDbQueryCtx(ctx, wrapDB(o.alias, db))
+}
+
 // read data to model
 func (o *orm) Read(md interface{}, cols ...string) error {
+	return o.ReadWithCtx(context.Background(), md, cols...)
+}
+
+// read data to model with context
+func (o *orm) ReadWithCtx(ctx context.Context, md interface{}, cols ...string) error {
 	mi, ind := o.getMiInd(md, true)
-	return o.alias.DbBaser.Read(o.db, mi, ind, o.alias.TZ, cols, false)
+	o, mi, err := o.routeOf(ctx, mi, ind)
+	if err != nil {
+		return err
+	}
+	if err := o.alias.DbBaser.Read(o.readQuerier(ctx), mi, ind, o.alias.TZ, cols, false); err != nil {
+		return err
+	}
+	return callHook(ctx, hookAfterRead, md)
 }
 
 // read data to model, like Read(), but use "SELECT FOR UPDATE" form
 func (o *orm) ReadForUpdate(md interface{}, cols ...string) error {
+	return o.ReadForUpdateWithCtx(context.Background(), md, cols...)
+}
+
+// read data to model with context, like ReadWithCtx(), but use "SELECT FOR UPDATE" form
+func (o *orm) ReadForUpdateWithCtx(ctx context.Context, md interface{}, cols ...string) error {
 	mi, ind := o.getMiInd(md, true)
-	return o.alias.DbBaser.Read(o.db, mi, ind, o.alias.TZ, cols, true)
+	o, mi, err := o.routeOf(ctx, mi, ind)
+	if err != nil {
+		return err
+	}
+	if err := o.alias.DbBaser.Read(o.dbQuerier(ctx), mi, ind, o.alias.TZ, cols, true); err != nil {
+		return err
+	}
+	return callHook(ctx, hookAfterRead, md)
 }
 
 // Try to read a row from the database, or insert one if it doesn't exist
 func (o *orm) ReadOrCreate(md interface{}, col1 string, cols ...string) (bool, int64, error) {
+	return o.ReadOrCreateWithCtx(context.Background(), md, col1, cols...)
+}
+
+// Try to read a row from the database with context, or insert one if it doesn't exist
+func (o *orm) ReadOrCreateWithCtx(ctx context.Context, md interface{}, col1 string, cols ...string) (bool, int64, error) {
 	cols = append([]string{col1}, cols...)
 	mi, ind := o.getMiInd(md, true)
-	err := o.alias.DbBaser.Read(o.db, mi, ind, o.alias.TZ, cols, false)
+	so, smi, err := o.routeOf(ctx, mi, ind)
+	if err != nil {
+		return false, 0, err
+	}
+	err = so.alias.DbBaser.Read(so.dbQuerier(ctx), smi, ind, so.alias.TZ, cols, false)
 	if err == ErrNoRows {
 		// Create
-		id, err := o.Insert(md)
+		id, err := o.InsertWithCtx(ctx, md)
 		return (err == nil), id, err
 	}
+	if err == nil {
+		err = callHook(ctx, hookAfterRead, md)
+	}
+
+	// composite primary key has no id
+	if len(mi.fields.pks) > 1 {
+		return false, 0, err
+	}
 
 	id, vid := int64(0), ind.FieldByIndex(mi.fields.pk.fieldIndex)
 	if mi.fields.pk.fieldType&IsPositiveIntegerField > 0 {
 		id = int64(vid.Uint())
 	} else if mi.fields.pk.rel {
-		return o.ReadOrCreate(vid.Interface(), mi.fields.pk.relModelInfo.fields.pk.name)
+		return o.ReadOrCreateWithCtx(ctx, vid.Interface(), mi.fields.pk.relModelInfo.fields.pk.name)
 	} else {
 		id = vid.Int()
 	}
@@ -159,15 +230,27 @@ func (o *orm) ReadOrCreate(md interface{}, col1 string, cols ...string) (bool, i
 
 // insert model data to database
 func (o *orm) Insert(md interface{}) (int64, error) {
+	return o.InsertWithCtx(context.Background(), md)
+}
+
+// insert model data to database with context
+func (o *orm) InsertWithCtx(ctx context.Context, md interface{}) (int64, error) {
 	mi, ind := o.getMiInd(md, true)
-	id, err := o.alias.DbBaser.Insert(o.db, mi, ind, o.alias.TZ)
+	if err := callHook(ctx, hookBeforeInsert, md); err != nil {
+		return 0, err
+	}
+	o, mi, err := o.routeOf(ctx, mi, ind)
+	if err != nil {
+		return 0, err
+	}
+	id, err := o.alias.DbBaser.Insert(o.dbQuerier(ctx), mi, ind, o.alias.TZ)
 	if err != nil {
 		return id, err
 	}
 
 	o.setPk(mi, ind, id)
 
-	return id, nil
+	return id, callHook(ctx, hookAfterInsert, md)
 }
 
 // set auto pk field
@@ -183,6 +266,11 @@ func (o *orm) setPk(mi *modelInfo, ind reflect.Value, id int64) {
 
 // insert some models to database
 func (o *orm) InsertMulti(bulk int, mds interface{}) (int64, error) {
+	return o.InsertMultiWithCtx(context.Background(), bulk, mds)
+}
+
+// insert some models to database with context
+func (o *orm) InsertMultiWithCtx(ctx context.Context, bulk int, mds interface{}) (int64, error) {
 	var cnt int64
 
 	sind := reflect.Indirect(reflect.ValueOf(mds))
@@ -198,9 +286,18 @@ func (o *orm) InsertMulti(bulk int, mds interface{}) (int64, error) {
 
 	if bulk <= 1 {
 		for i := 0; i < sind.Len(); i++ {
+			md := hookModel(sind.Index(i))
+			if err := callHook(ctx, hookBeforeInsert, md); err != nil {
+				return cnt, err
+			}
+
 			ind := reflect.Indirect(sind.Index(i))
 			mi, _ := o.getMiInd(ind.Interface(), false)
-			id, err := o.alias.DbBaser.Insert(o.db, mi, ind, o.alias.TZ)
+			so, mi, err := o.routeOf(ctx, mi, ind)
+			if err != nil {
+				return cnt, err
+			}
+			id, err := so.alias.DbBaser.Insert(so.dbQuerier(ctx), mi, ind, so.alias.TZ)
 			if err != nil {
 				return cnt, err
 			}
@@ -208,50 +305,204 @@ func (o *orm) InsertMulti(bulk int, mds interface{}) (int64, error) {
 			o.setPk(mi, ind, id)
 
 			cnt++
+
+			if err := callHook(ctx, hookAfterInsert, md); err != nil {
+				return cnt, err
+			}
 		}
 	} else {
+		// all models must pass the hooks before the first bulk is sent
+		if err := callHooks(ctx, hookBeforeInsert, sind); err != nil {
+			return cnt, err
+		}
+
 		mi, _ := o.getMiInd(sind.Index(0).Interface(), false)
-		return o.alias.DbBaser.InsertMulti(o.db, mi, sind, bulk, o.alias.TZ)
+		if mi.tenant != nil {
+			so, tmi, err := o.routeOf(ctx, mi, sind.Index(0))
+			if err != nil {
+				return cnt, err
+			}
+			num, err := so.alias.DbBaser.InsertMulti(so.dbQuerier(ctx), tmi, sind, bulk, so.alias.TZ)
+			if err != nil {
+				return num, err
+			}
+			return num, callHooks(ctx, hookAfterInsert, sind)
+		}
+		if mi.shard != nil {
+			groups, err := o.shardGroups(mi, sind)
+			if err != nil {
+				return cnt, err
+			}
+			for _, g := range groups {
+				num, err := g.orm.alias.DbBaser.InsertMulti(g.orm.dbQuerier(ctx), g.mi, g.rows, bulk, g.orm.alias.TZ)
+				cnt += num
+				if err != nil {
+					return cnt, err
+				}
+			}
+			return cnt, callHooks(ctx, hookAfterInsert, sind)
+		}
+		num, err := o.alias.DbBaser.InsertMulti(o.dbQuerier(ctx), mi, sind, bulk, o.alias.TZ)
+		if err != nil {
+			return num, err
+		}
+		return num, callHooks(ctx, hookAfterInsert, sind)
 	}
 	return cnt, nil
 }
 
+// insert some models to database, the models conflicting on conflictCols are updated with updateCols
+func (o *orm) InsertMultiOrUpdate(bulk int, mds interface{}, conflictCols, updateCols []string) ([]int64, error) {
+	return o.InsertMultiOrUpdateWithCtx(context.Background(), bulk, mds, conflictCols, updateCols)
+}
+
+// insert some models to database with context, the models conflicting on conflictCols are updated with updateCols
+func (o *orm) InsertMultiOrUpdateWithCtx(ctx context.Context, bulk int, mds interface{}, conflictCols, updateCols []string) ([]int64, error) {
+	sind := reflect.Indirect(reflect.ValueOf(mds))
+
+	switch sind.Kind() {
+	case reflect.Array, reflect.Slice:
+		if sind.Len() == 0 {
+			return nil, ErrArgs
+		}
+	default:
+		return nil, ErrArgs
+	}
+
+	// all models must pass the hooks before the first bulk is sent
+	if err := callHooks(ctx, hookBeforeInsert, sind); err != nil {
+		return nil, err
+	}
+
+	mi, _ := o.getMiInd(sind.Index(0).Interface(), false)
+	if mi.tenant != nil {
+		so, tmi, err := o.routeOf(ctx, mi, sind.Index(0))
+		if err != nil {
+			return nil, err
+		}
+		nums, err := so.alias.DbBaser.InsertMultiOrUpdate(so.dbQuerier(ctx), tmi, sind, bulk, so.alias, conflictCols, updateCols)
+		if err != nil {
+			return nums, err
+		}
+		return nums, callHooks(ctx, hookAfterInsert, sind)
+	}
+	if mi.shard != nil {
+		groups, err := o.shardGroups(mi, sind)
+		if err != nil {
+			return nil, err
+		}
+		var nums []int64
+		for _, g := range groups {
+			gnums, err := g.orm.alias.DbBaser.InsertMultiOrUpdate(g.orm.dbQuerier(ctx), g.mi, g.rows, bulk, g.orm.alias, conflictCols, updateCols)
+			nums = append(nums, gnums...)
+			if err != nil {
+				return nums, err
+			}
+		}
+		return nums, callHooks(ctx, hookAfterInsert, sind)
+	}
+	nums, err := o.alias.DbBaser.InsertMultiOrUpdate(o.dbQuerier(ctx), mi, sind, bulk, o.alias, conflictCols, updateCols)
+	if err != nil {
+		return nums, err
+	}
+	return nums, callHooks(ctx, hookAfterInsert, sind)
+}
+
 // InsertOrUpdate data to database
 func (o *orm) InsertOrUpdate(md interface{}, colConflitAndArgs ...string) (int64, error) {
+	return o.InsertOrUpdateWithCtx(context.Background(), md, colConflitAndArgs...)
+}
+
+// InsertOrUpdateWithCtx data to database with context
+func (o *orm) InsertOrUpdateWithCtx(ctx context.Context, md interface{}, colConflitAndArgs ...string) (int64, error) {
 	mi, ind := o.getMiInd(md, true)
-	id, err := o.alias.DbBaser.InsertOrUpdate(o.db, mi, ind, o.alias, colConflitAndArgs...)
+	if err := callHook(ctx, hookBeforeInsert, md); err != nil {
+		return 0, err
+	}
+	o, mi, err := o.routeOf(ctx, mi, ind)
+	if err != nil {
+		return 0, err
+	}
+	id, err := o.alias.DbBaser.InsertOrUpdate(o.dbQuerier(ctx), mi, ind, o.alias, colConflitAndArgs...)
 	if err != nil {
 		return id, err
 	}
 
 	o.setPk(mi, ind, id)
 
-	return id, nil
+	return id, callHook(ctx, hookAfterInsert, md)
 }
 
 // update model to database.
 // cols set the columns those want to update.
 func (o *orm) Update(md interface{}, cols ...string) (int64, error) {
+	return o.UpdateWithCtx(context.Background(), md, cols...)
+}
+
+// update model to database with context.
+// cols set the columns those want to update.
+func (o *orm) UpdateWithCtx(ctx context.Context, md interface{}, cols ...string) (int64, error) {
 	mi, ind := o.getMiInd(md, true)
-	return o.alias.DbBaser.Update(o.db, mi, ind, o.alias.TZ, cols)
+	if mi.shard != nil {
+		if err := mi.shard.checkUpdate(mi, cols); err != nil {
+			return 0, err
+		}
+	}
+	if err := callHook(ctx, hookBeforeUpdate, md); err != nil {
+		return 0, err
+	}
+	o, mi, err := o.routeOf(ctx, mi, ind)
+	if err != nil {
+		return 0, err
+	}
+	num, err := o.alias.DbBaser.Update(o.dbQuerier(ctx), mi, ind, o.alias.TZ, cols)
+	if err != nil {
+		return num, err
+	}
+	return num, callHook(ctx, hookAfterUpdate, md)
 }
 
 // delete model in database
 // cols shows the delete conditions values read from. default is pk
 func (o *orm) Delete(md interface{}, cols ...string) (int64, error) {
+	return o.DeleteWithCtx(context.Background(), md, cols...)
+}
+
+// delete model in database with context
+// cols shows the delete conditions values read from. default is pk
+func (o *orm) DeleteWithCtx(ctx context.Context, md interface{}, cols ...string) (int64, error) {
 	mi, ind := o.getMiInd(md, true)
-	num, err := o.alias.DbBaser.Delete(o.db, mi, ind, o.alias.TZ, cols)
+	if err := callHook(ctx, hookBeforeDelete, md); err != nil {
+		return 0, err
+	}
+	o, mi, err := o.routeOf(ctx, mi, ind)
+	if err != nil {
+		return 0, err
+	}
+	if mi.fields.softDelete != nil {
+		num, err := o.softDelete(ctx, mi, ind, cols)
+		if err != nil {
+			return num, err
+		}
+		return num, callHook(ctx, hookAfterDelete, md)
+	}
+	num, err := o.alias.DbBaser.Delete(o.dbQuerier(ctx), mi, ind, o.alias.TZ, cols)
 	if err != nil {
 		return num, err
 	}
 	if num > 0 {
 		o.setPk(mi, ind, 0)
 	}
-	return num, nil
+	return num, callHook(ctx, hookAfterDelete, md)
 }
 
 // create a models to models queryer
 func (o *orm) QueryM2M(md interface{}, name string) QueryM2Mer {
+	return o.QueryM2MWithCtx(context.Background(), md, name)
+}
+
+// create a models to models queryer with context
+func (o *orm) QueryM2MWithCtx(ctx context.Context, md interface{}, name string) QueryM2Mer {
 	mi, ind := o.getMiInd(md, true)
 	fi := o.getFieldInfo(mi, name)
 
@@ -262,11 +513,12 @@ func (o *orm) QueryM2M(md interface{}, name string) QueryM2Mer {
 		panic(fmt.Errorf("<Ormer.QueryM2M> model `%s` . name `%s` is not a m2m field", fi.name, mi.fullName))
 	}
 
-	return newQueryM2M(md, o, mi, fi, ind)
+	return newQueryM2M(ctx, md, o, mi, fi, ind)
 }
 
 // load related models to md model.
 // args are limit, offset int and order string.
+// a SoftDeleteMode arg loads the deleted related models too.
 //
 // example:
 // 	orm.LoadRelated(post,"Tags")
@@ -274,14 +526,31 @@ func (o *orm) QueryM2M(md interface{}, name string) QueryM2Mer {
 //
 // make sure the relation is defined in model struct tags.
 func (o *orm) LoadRelated(md interface{}, name string, args ...interface{}) (int64, error) {
+	return o.LoadRelatedWithCtx(context.Background(), md, name, args...)
+}
+
+// load related models to md model with context.
+// args are the same as LoadRelated.
+func (o *orm) LoadRelatedWithCtx(ctx context.Context, md interface{}, name string, args ...interface{}) (int64, error) {
+	o, err := o.ctxTenant(ctx)
+	if err != nil {
+		return 0, err
+	}
 	_, fi, ind, qseter := o.queryRelated(md, name)
 
 	qs := qseter.(*querySet)
+	qs.ctx = ctx
+	qs.forContext = true
 
 	var relDepth int
 	var limit, offset int64
 	var order string
-	for i, arg := range args {
+	var i int
+	for _, arg := range args {
+		if v, ok := arg.(SoftDeleteMode); ok {
+			qs.softDelete = v
+			continue
+		}
 		switch i {
 		case 0:
 			if v, ok := arg.(bool); ok {
@@ -298,6 +567,7 @@ func (o *orm) LoadRelated(md interface{}, name string, args ...interface{}) (int
 		case 3:
 			order, _ = arg.(string)
 		}
+		i++
 	}
 
 	switch fi.fieldType {
@@ -317,7 +587,6 @@ func (o *orm) LoadRelated(md interface{}, name string, args ...interface{}) (int
 	find := ind.FieldByIndex(fi.fieldIndex)
 
 	var nums int64
-	var err error
 	switch fi.fieldType {
 	case RelOneToOne, RelForeignKey, RelReverseOne:
 		val := reflect.New(find.Type().Elem())
@@ -351,7 +620,7 @@ func (o *orm) queryRelated(md interface{}, name string) (*modelInfo, *fieldInfo,
 	mi, ind := o.getMiInd(md, true)
 	fi := o.getFieldInfo(mi, name)
 
-	_, _, exist := getExistPk(mi, ind)
+	_, _, exist := getExistPks(mi, ind)
 	if !exist {
 		panic(ErrMissPK)
 	}
@@ -391,6 +660,7 @@ func (o *orm) getReverseQs(md interface{}, mi *modelInfo, fi *fieldInfo) *queryS
 	if fi.fieldType == RelReverseMany && fi.reverseFieldInfo.mi.isThrough {
 		q = newQuerySet(o, fi.relModelInfo).(*querySet)
 		q.cond = NewCondition().And(fi.reverseFieldInfoM2M.column+ExprSep+fi.reverseFieldInfo.column, md)
+		q.addThroughSoftDelete(fi)
 	} else {
 		q = newQuerySet(o, fi.reverseFieldInfo.mi).(*querySet)
 		q.cond = NewCondition().And(fi.reverseFieldInfo.column, md)
@@ -412,6 +682,7 @@ func (o *orm) getRelQs(md interface{}, mi *modelInfo, fi *fieldInfo) *querySet {
 
 	if fi.fieldType == RelManyToMany {
 		q.cond = q.cond.And(fi.reverseFieldInfoM2M.column+ExprSep+fi.reverseFieldInfo.column, md)
+		q.addThroughSoftDelete(fi)
 	} else {
 		q.cond = q.cond.And(fi.reverseFieldInfo.column, md)
 	}
@@ -441,6 +712,13 @@ func (o *orm) QueryTable(ptrStructOrTableName interface{}) (qs QuerySeter) {
 	return
 }
 
+// return an Ormer sharing the database of o whose reads are always served by the primary.
+func (o *orm) ForcePrimary() Ormer {
+	n := *o
+	n.forcePrimary = true
+	return &n
+}
+
 // switch to another registered database driver by given name.
 func (o *orm) Using(name string) error {
 	if o.isTx {
@@ -448,11 +726,7 @@ func (o *orm) Using(name string) error {
 	}
 	if al, ok := dataBaseCache.get(name); ok {
 		o.alias = al
-		if Debug {
-			o.db = newDbQueryLog(al, al.DB)
-		} else {
-			o.db = al.DB
-		}
+		o.db = wrapDB(al, al.DB)
 	} else {
 		return fmt.Errorf("<Ormer.Using> unknown db alias name `%s`", name)
 	}
@@ -474,11 +748,7 @@ func (o *orm) BeginTx(ctx context.Context, opts *sql.TxOptions) error {
 		return err
 	}
 	o.isTx = true
-	if Debug {
-		o.db.(*dbQueryLog).SetDB(tx)
-	} else {
-		o.db = tx
-	}
+	o.db = wrapDB(o.alias, tx)
 	return nil
 }
 
@@ -490,6 +760,7 @@ func (o *orm) Commit() error {
 	err := o.db.(txEnder).Commit()
 	if err == nil {
 		o.isTx = false
+		o.txTenant = ""
 		o.Using(o.alias.Name)
 	} else if err == sql.ErrTxDone {
 		return ErrTxDone
@@ -505,6 +776,7 @@ func (o *orm) Rollback() error {
 	err := o.db.(txEnder).Rollback()
 	if err == nil {
 		o.isTx = false
+		o.txTenant = ""
 		o.Using(o.alias.Name)
 	} else if err == sql.ErrTxDone {
 		return ErrTxDone
@@ -513,6 +785,7 @@ func (o *orm) Rollback() error {
 }
 
 // return a raw query seter for raw sql string.
+// use RawSeter.WithCtx to bind a context to it.
 func (o *orm) Raw(query string, args ...interface{}) RawSeter {
 	return newRawSet(o, query, args)
 }
@@ -555,11 +828,7 @@ func NewOrmWithDB(driverName, aliasName string, db *sql.DB) (Ormer, error) {
 	o := new(orm)
 	o.alias = al
 
-	if Debug {
-		o.db = newDbQueryLog(o.alias, db)
-	} else {
-		o.db = db
-	}
+	o.db = wrapDB(o.alias, db)
 
 	return o, nil
 }
@@ -0,0 +1,272 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// aggregate functions of Annotate and Aggregate.
+var aggregateFuncs = map[string]string{
+	"count": "COUNT",
+	"sum":   "SUM",
+	"avg":   "AVG",
+	"min":   "MIN",
+	"max":   "MAX",
+}
+
+// Func(expr) or Func(expr) AS alias
+var aggregateRegexp = regexp.MustCompile(`^\s*(\w+)\(\s*(\*|\w+)\s*\)(?:\s+(?i:as)\s+(\w+))?\s*$`)
+
+// aggregate expression, fn is the lower function name, expr is * or a field expression.
+type aggregateExpr struct {
+	fn    string
+	expr  string
+	alias string
+}
+
+// parse aggregate expression like Sum(Amount) or Count(Posts__ID) AS posts.
+// the default alias is the snake name of function and expression, like sum_amount or max_user__age.
+func parseAggregate(s string) (aggregateExpr, error) {
+	m := aggregateRegexp.FindStringSubmatch(s)
+	if m == nil {
+		return aggregateExpr{}, fmt.Errorf("<QuerySeter.Annotate> wrong aggregate expression `%s`", s)
+	}
+	agg := aggregateExpr{fn: strings.ToLower(m[1]), expr: m[2], alias: m[3]}
+	if _, ok := aggregateFuncs[agg.fn]; !ok {
+		return aggregateExpr{}, fmt.Errorf("<QuerySeter.Annotate> unsupported aggregate function `%s`", m[1])
+	}
+	if agg.expr == "*" && agg.fn != "count" {
+		return aggregateExpr{}, fmt.Errorf("<QuerySeter.Annotate> only Count support `*`, but found `%s`", s)
+	}
+	if agg.alias == "" {
+		agg.alias = agg.fn
+		if agg.expr != "*" {
+			names := strings.Split(agg.expr, ExprSep)
+			for i, name := range names {
+				names[i] = snakeString(name)
+			}
+			agg.alias += "_" + strings.Join(names, ExprSep)
+		}
+	}
+	return agg, nil
+}
+
+// add aggregates to the selected columns of Values, ValuesList and ValuesFlat.
+// the rows are grouped by GroupBy.
+func (o querySet) Annotate(exprs ...string) QuerySeter {
+	aggs := o.aggregates[:len(o.aggregates):len(o.aggregates)]
+	for _, expr := range exprs {
+		agg, err := parseAggregate(expr)
+		if err != nil {
+			o.err = err
+			return &o
+		}
+		aggs = append(aggs, agg)
+	}
+	o.aggregates = aggs
+	return &o
+}
+
+// query the aggregates and map to container.
+// container can be ptr of Params, struct, []Params or struct slice,
+// Params keys and struct columns are the aliases of aggregates and the names of group fields.
+func (o *querySet) Aggregate(container interface{}, exprs ...string) (int64, error) {
+	qs := o.Annotate(exprs...).(*querySet)
+	if qs.err != nil {
+		return 0, qs.err
+	}
+	if len(qs.aggregates) == 0 {
+		return 0, ErrArgs
+	}
+
+	if qs.mi.shard != nil {
+		sqs, err := qs.oneShardQs()
+		if err != nil {
+			return 0, err
+		}
+		qs = sqs
+	}
+
+	var maps []Params
+	num, err := qs.orm.alias.DbBaser.ReadValues(qs.readQuerier(), qs, qs.mi, qs.scopedCond(), nil, &maps, qs.orm.alias.TZ)
+	if err != nil {
+		return num, err
+	}
+
+	val := reflect.ValueOf(container)
+	ind := reflect.Indirect(val)
+	if val.Kind() != reflect.Ptr {
+		panic(fmt.Errorf("<QuerySeter.Aggregate> cannot use non-ptr container `%T`", container))
+	}
+
+	switch v := container.(type) {
+	case *[]Params:
+		*v = maps
+		return num, nil
+	case *Params:
+		if num == 0 {
+			return 0, ErrNoRows
+		}
+		if num > 1 {
+			return num, ErrMultiRows
+		}
+		*v = maps[0]
+		return num, nil
+	}
+
+	switch {
+	case ind.Kind() == reflect.Struct:
+		if num == 0 {
+			return 0, ErrNoRows
+		}
+		if num > 1 {
+			return num, ErrMultiRows
+		}
+		return num, setAggregateStruct(ind, maps[0])
+	case ind.Kind() == reflect.Slice:
+		typ := ind.Type().Elem()
+		isPtr := typ.Kind() == reflect.Ptr
+		if isPtr {
+			typ = typ.Elem()
+		}
+		if typ.Kind() == reflect.Struct {
+			slice := reflect.MakeSlice(ind.Type(), 0, len(maps))
+			for _, params := range maps {
+				elem := reflect.New(typ)
+				if err := setAggregateStruct(elem.Elem(), params); err != nil {
+					return num, err
+				}
+				if !isPtr {
+					elem = elem.Elem()
+				}
+				slice = reflect.Append(slice, elem)
+			}
+			ind.Set(slice)
+			return num, nil
+		}
+	}
+	panic(fmt.Errorf("<QuerySeter.Aggregate> unsupported container type `%T`", container))
+}
+
+// set the aggregate result params to struct ind.
+// the column of field is the column tag, the field name or the snake field name.
+func setAggregateStruct(ind reflect.Value, params Params) error {
+	for i := 0; i < ind.NumField(); i++ {
+		field := ind.Field(i)
+		sf := ind.Type().Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			if err := setAggregateStruct(field, params); err != nil {
+				return err
+			}
+			continue
+		}
+
+		_, tags := parseStructTag(sf.Tag.Get(defaultStructTagName))
+		names := []string{sf.Name, nameStrategyMap[nameStrategy](sf.Name)}
+		if col := tags["column"]; col != "" {
+			names = []string{col}
+		}
+		for _, name := range names {
+			if value, ok := params[name]; ok {
+				if err := setAggregateField(field, value); err != nil {
+					return fmt.Errorf("<QuerySeter.Aggregate> field `%s` %s", sf.Name, err.Error())
+				}
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// set the aggregate value to field, nil value set field to zero.
+func setAggregateField(field reflect.Value, value interface{}) error {
+	if value == nil {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+	if field.Kind() == reflect.Ptr {
+		ptr := reflect.New(field.Type().Elem())
+		if err := setAggregateField(ptr.Elem(), value); err != nil {
+			return err
+		}
+		field.Set(ptr)
+		return nil
+	}
+
+	val := reflect.ValueOf(value)
+	switch {
+	case field.Kind() == reflect.String:
+		field.SetString(ToStr(value))
+	case val.Type().ConvertibleTo(field.Type()) && val.Kind() != reflect.String:
+		field.Set(val.Convert(field.Type()))
+	default:
+		return fmt.Errorf("cannot set `%T` to `%s`", value, field.Type())
+	}
+	return nil
+}
+
+// get the select sql of aggregate and the aggregated field, field is nil for Count(*).
+func (t *dbTables) getAggregateSQL(agg aggregateExpr) (string, *fieldInfo) {
+	Q := t.base.TableQuote()
+	fn := aggregateFuncs[agg.fn]
+	if agg.expr == "*" {
+		return fmt.Sprintf("%s(*) %s%s%s", fn, Q, agg.alias, Q), nil
+	}
+
+	index, _, fi, suc := t.parseExprs(t.mi, strings.Split(agg.expr, ExprSep))
+	if !suc || !fi.dbcol {
+		panic(fmt.Errorf("unknown field/column name `%s`", agg.expr))
+	}
+	return fmt.Sprintf("%s(%s.%s%s%s) %s%s%s", fn, index, Q, fi.column, Q, Q, agg.alias, Q), fi
+}
+
+// convert the db value of aggregate.
+// Count is int64, Avg is float64, Sum is int64 or float64 by the field type,
+// Min and Max are the value of the field type.
+func (d *dbBase) convertAggregateValue(agg aggregateExpr, fi *fieldInfo, val interface{}, tz *time.Location) (interface{}, error) {
+	if val == nil {
+		return nil, nil
+	}
+	switch agg.fn {
+	case "count":
+		return StrTo(ToStr(val)).Int64()
+	case "avg":
+		return StrTo(ToStr(val)).Float64()
+	case "sum":
+		if fi.fieldType&IsIntegerField > 0 {
+			if v, err := StrTo(ToStr(val)).Int64(); err == nil {
+				return v, nil
+			}
+		}
+		return StrTo(ToStr(val)).Float64()
+	}
+	return d.convertValueFromDB(fi, val, tz)
+}
+
+// convert the db value of column i of ReadValues, the last columns are aggregates.
+func (d *dbBase) convertValuesColumn(infos []*fieldInfo, aggs []aggregateExpr, i int, val interface{}, tz *time.Location) (interface{}, error) {
+	if n := len(infos) - len(aggs); i >= n {
+		return d.convertAggregateValue(aggs[i-n], infos[i], val, tz)
+	}
+	return d.convertValueFromDB(infos[i], val, tz)
+}
@@ -0,0 +1,74 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"context"
+	"database/sql"
+)
+
+// database querier bound to a context.
+// every statement is sent through the *Context methods of the wrapped querier,
+// so cancellation and deadlines of ctx apply to the whole dbBaser call.
+type dbQueryCtx struct {
+	ctx context.Context
+	db  dbQuerier
+}
+
+var _ dbQuerier = new(dbQueryCtx)
+
+func (d *dbQueryCtx) Prepare(query string) (*sql.Stmt, error) {
+	return d.db.PrepareContext(d.ctx, query)
+}
+
+func (d *dbQueryCtx) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return d.db.PrepareContext(ctx, query)
+}
+
+func (d *dbQueryCtx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return d.db.ExecContext(d.ctx, query, args...)
+}
+
+func (d *dbQueryCtx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return d.db.ExecContext(ctx, query, args...)
+}
+
+func (d *dbQueryCtx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return d.db.QueryContext(d.ctx, query, args...)
+}
+
+func (d *dbQueryCtx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return d.db.QueryContext(ctx, query, args...)
+}
+
+func (d *dbQueryCtx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return d.db.QueryRowContext(d.ctx, query, args...)
+}
+
+func (d *dbQueryCtx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return d.db.QueryRowContext(ctx, query, args...)
+}
+
+// bind db querier to ctx.
+// a nil ctx returns db untouched.
+func newDbQueryCtx(ctx context.Context, db dbQuerier) dbQuerier {
+	if ctx == nil {
+		return db
+	}
+	d := new(dbQueryCtx)
+	d.ctx = ctx
+	d.db = db
+	return d
+}
@@ -0,0 +1,167 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+)
+
+// QueryPlan is the plan of a query reported by the EXPLAIN of database.
+type QueryPlan struct {
+	Query string        // the explained query
+	Args  []interface{} // args of the query
+	Steps []PlanStep    // steps of the plan in the order of EXPLAIN output
+	Raw   []Params      // rows of EXPLAIN output, the values are string or nil
+}
+
+// PlanStep is a step of QueryPlan, like scanning a table or sorting the rows.
+type PlanStep struct {
+	Table    string // table name, empty if the step accesses no table
+	Access   string // access method, like ALL, ref of mysql, Seq Scan of postgres, SCAN, SEARCH of sqlite
+	Index    string // the used index, empty if no index is used
+	Rows     int64  // estimated rows, sqlite does not estimate and it is 0
+	FullScan bool   // the table is fully scanned without index
+	Detail   string // description of the step
+}
+
+// FullScans return the steps scanning a full table.
+func (p *QueryPlan) FullScans() []PlanStep {
+	var steps []PlanStep
+	for _, step := range p.Steps {
+		if step.FullScan {
+			steps = append(steps, step)
+		}
+	}
+	return steps
+}
+
+// return the SELECT statement and args of All without executing it.
+func (o *querySet) ToSQL(cols ...string) (string, []interface{}, error) {
+	if o.err != nil {
+		return "", nil, o.err
+	}
+	if o.mi.shard != nil {
+		qs, err := o.oneShardQs()
+		if err != nil {
+			return "", nil, err
+		}
+		return qs.ToSQL(cols...)
+	}
+	query, args, _, _, _, err := o.orm.alias.DbBaser.readBatchQuery(o, o.mi, o.scopedCond(), o.orm.alias.TZ, cols)
+	return query, args, err
+}
+
+// run the EXPLAIN of the SELECT statement of All and return the plan.
+// the table aliases of query like T0 are resolved to table names in the steps.
+func (o *querySet) Explain(ctx context.Context, cols ...string) (*QueryPlan, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+	if o.mi.shard != nil {
+		qs, err := o.oneShardQs()
+		if err != nil {
+			return nil, err
+		}
+		return qs.Explain(ctx, cols...)
+	}
+	d := o.orm.alias.DbBaser
+	query, args, _, tables, _, err := d.readBatchQuery(o, o.mi, o.scopedCond(), o.orm.alias.TZ, cols)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := d.explain(ctx, o.readQuerier(), query, args)
+	if err != nil {
+		return nil, err
+	}
+	plan.Query, plan.Args = query, args
+
+	aliases := map[string]string{"T0": o.mi.table}
+	for _, tbl := range tables.tables {
+		aliases[tbl.index] = tbl.mi.table
+	}
+	for i, step := range plan.Steps {
+		if table, ok := aliases[step.Table]; ok {
+			plan.Steps[i].Table = table
+		}
+	}
+	return plan, nil
+}
+
+// explain query by mysql, every row of the output is a table access.
+func (d *dbBase) explain(ctx context.Context, q dbQuerier, query string, args []interface{}) (*QueryPlan, error) {
+	raw, err := queryExplainRows(ctx, q, "EXPLAIN "+query, args)
+	if err != nil {
+		return nil, err
+	}
+	plan := &QueryPlan{Raw: raw}
+	for _, row := range raw {
+		step := PlanStep{
+			Table:  explainString(row["table"]),
+			Access: explainString(row["type"]),
+			Index:  explainString(row["key"]),
+			Detail: explainString(row["Extra"]),
+		}
+		step.Rows, _ = strconv.ParseInt(explainString(row["rows"]), 10, 64)
+		step.FullScan = step.Access == "ALL"
+		plan.Steps = append(plan.Steps, step)
+	}
+	return plan, nil
+}
+
+// query the rows of EXPLAIN output, the values are converted to string.
+func queryExplainRows(ctx context.Context, q dbQuerier, query string, args []interface{}) ([]Params, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	rs, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+
+	columns, err := rs.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var rows []Params
+	for rs.Next() {
+		values := make([]sql.NullString, len(columns))
+		refs := make([]interface{}, len(columns))
+		for i := range values {
+			refs[i] = &values[i]
+		}
+		if err := rs.Scan(refs...); err != nil {
+			return nil, err
+		}
+		row := make(Params, len(columns))
+		for i, col := range columns {
+			row[col] = nil
+			if values[i].Valid {
+				row[col] = values[i].String
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, rs.Err()
+}
+
+// get the string value of EXPLAIN output, NULL is empty.
+func explainString(value interface{}) string {
+	s, _ := value.(string)
+	return strings.TrimSpace(s)
+}
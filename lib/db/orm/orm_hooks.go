@@ -0,0 +1,99 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"context"
+	"reflect"
+)
+
+// model lifecycle hook kinds.
+type hookType int
+
+const (
+	hookBeforeInsert hookType = iota
+	hookAfterInsert
+	hookBeforeUpdate
+	hookAfterUpdate
+	hookBeforeDelete
+	hookAfterDelete
+	hookAfterRead
+)
+
+// call the hook implemented by model md, nothing is done if md doesn't implement it.
+func callHook(ctx context.Context, hook hookType, md interface{}) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	switch hook {
+	case hookBeforeInsert:
+		if h, ok := md.(BeforeInserter); ok {
+			return h.BeforeInsert(ctx)
+		}
+	case hookAfterInsert:
+		if h, ok := md.(AfterInserter); ok {
+			return h.AfterInsert(ctx)
+		}
+	case hookBeforeUpdate:
+		if h, ok := md.(BeforeUpdater); ok {
+			return h.BeforeUpdate(ctx)
+		}
+	case hookAfterUpdate:
+		if h, ok := md.(AfterUpdater); ok {
+			return h.AfterUpdate(ctx)
+		}
+	case hookBeforeDelete:
+		if h, ok := md.(BeforeDeleter); ok {
+			return h.BeforeDelete(ctx)
+		}
+	case hookAfterDelete:
+		if h, ok := md.(AfterDeleter); ok {
+			return h.AfterDelete(ctx)
+		}
+	case hookAfterRead:
+		if h, ok := md.(AfterReader); ok {
+			return h.AfterRead(ctx)
+		}
+	}
+	return nil
+}
+
+// get model pointer from reflect value of a model or a model pointer.
+func hookModel(val reflect.Value) interface{} {
+	if val.Kind() != reflect.Ptr && val.CanAddr() {
+		return val.Addr().Interface()
+	}
+	return val.Interface()
+}
+
+// call the hook for every model in slice value.
+func callHooks(ctx context.Context, hook hookType, sind reflect.Value) error {
+	for i := 0; i < sind.Len(); i++ {
+		if err := callHook(ctx, hook, hookModel(sind.Index(i))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// call the hook for the model or models filled in container.
+// container can be *Model, *[]Model or *[]*Model.
+func callContainerHooks(ctx context.Context, hook hookType, container interface{}) error {
+	ind := reflect.Indirect(reflect.ValueOf(container))
+	if ind.Kind() == reflect.Slice {
+		return callHooks(ctx, hook, ind)
+	}
+	return callHook(ctx, hook, container)
+}
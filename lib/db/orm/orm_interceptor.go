@@ -0,0 +1,299 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"go.uber.org/zap"
+
+	"github.com/gopherchai/contrib/lib/log"
+	"github.com/gopherchai/contrib/lib/metrics"
+)
+
+// QueryInfo describe a statement sent to the database.
+type QueryInfo struct {
+	Alias     string
+	Operation string
+	Query     string
+	Args      []interface{}
+}
+
+// QueryHandler send the statement to the database.
+type QueryHandler func(ctx context.Context, info *QueryInfo) error
+
+// Interceptor is called around every statement of a database alias.
+// it must call next to execute the statement and return the error of next.
+type Interceptor func(ctx context.Context, info *QueryInfo, next QueryHandler) error
+
+// AddInterceptor append interceptors to the chain of a registered database alias.
+// interceptors run in the order they are added,
+// the chain is read by every statement, ormers created before the call are intercepted too.
+func AddInterceptor(aliasName string, interceptors ...Interceptor) error {
+	al, ok := dataBaseCache.get(aliasName)
+	if !ok {
+		return fmt.Errorf("DataBase alias name `%s` not registered", aliasName)
+	}
+	al.interceptMux.Lock()
+	defer al.interceptMux.Unlock()
+	// copy on write, the running statements keep the old chain
+	chain := make([]Interceptor, 0, len(al.Interceptors)+len(interceptors))
+	chain = append(chain, al.Interceptors...)
+	al.Interceptors = append(chain, interceptors...)
+	return nil
+}
+
+// get the interceptor chain of alias.
+func (al *alias) interceptors() []Interceptor {
+	al.interceptMux.RLock()
+	defer al.interceptMux.RUnlock()
+	return al.Interceptors
+}
+
+// run handler through the interceptor chain of alias.
+func intercept(al *alias, ctx context.Context, operation, query string, args []interface{}, handler QueryHandler) error {
+	info := &QueryInfo{
+		Alias:     al.Name,
+		Operation: operation,
+		Query:     query,
+		Args:      args,
+	}
+	chain := al.interceptors()
+	for i := len(chain) - 1; i >= 0; i-- {
+		ic, next := chain[i], handler
+		handler = func(ctx context.Context, info *QueryInfo) error {
+			return ic(ctx, info, next)
+		}
+	}
+	return handler(ctx, info)
+}
+
+// statement querier running the interceptor chain of alias.
+type stmtQueryIntercept struct {
+	alias *alias
+	ctx   context.Context // context the statement is prepared with
+	query string
+	stmt  stmtQuerier
+}
+
+var _ stmtQuerier = new(stmtQueryIntercept)
+
+func (d *stmtQueryIntercept) Close() error {
+	return intercept(d.alias, d.ctx, "st.Close", d.query, nil, func(context.Context, *QueryInfo) error {
+		return d.stmt.Close()
+	})
+}
+
+func (d *stmtQueryIntercept) Exec(args ...interface{}) (res sql.Result, err error) {
+	err = intercept(d.alias, d.ctx, "st.Exec", d.query, args, func(context.Context, *QueryInfo) error {
+		res, err = d.stmt.Exec(args...)
+		return err
+	})
+	return
+}
+
+func (d *stmtQueryIntercept) Query(args ...interface{}) (res *sql.Rows, err error) {
+	err = intercept(d.alias, d.ctx, "st.Query", d.query, args, func(context.Context, *QueryInfo) error {
+		res, err = d.stmt.Query(args...)
+		return err
+	})
+	return
+}
+
+func (d *stmtQueryIntercept) QueryRow(args ...interface{}) (res *sql.Row) {
+	intercept(d.alias, d.ctx, "st.QueryRow", d.query, args, func(context.Context, *QueryInfo) error {
+		res = d.stmt.QueryRow(args...)
+		return res.Err()
+	})
+	return
+}
+
+// database querier running the interceptor chain of alias.
+type dbQueryIntercept struct {
+	alias *alias
+	db    dbQuerier
+}
+
+var _ dbQuerier = new(dbQueryIntercept)
+var _ txer = new(dbQueryIntercept)
+var _ txEnder = new(dbQueryIntercept)
+
+func (d *dbQueryIntercept) Prepare(query string) (*sql.Stmt, error) {
+	return d.PrepareContext(context.Background(), query)
+}
+
+func (d *dbQueryIntercept) PrepareContext(ctx context.Context, query string) (stmt *sql.Stmt, err error) {
+	err = intercept(d.alias, ctx, "db.Prepare", query, nil, func(ctx context.Context, _ *QueryInfo) error {
+		stmt, err = d.db.PrepareContext(ctx, query)
+		return err
+	})
+	return
+}
+
+func (d *dbQueryIntercept) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return d.ExecContext(context.Background(), query, args...)
+}
+
+func (d *dbQueryIntercept) ExecContext(ctx context.Context, query string, args ...interface{}) (res sql.Result, err error) {
+	err = intercept(d.alias, ctx, "db.Exec", query, args, func(ctx context.Context, _ *QueryInfo) error {
+		res, err = d.db.ExecContext(ctx, query, args...)
+		return err
+	})
+	return
+}
+
+func (d *dbQueryIntercept) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return d.QueryContext(context.Background(), query, args...)
+}
+
+func (d *dbQueryIntercept) QueryContext(ctx context.Context, query string, args ...interface{}) (res *sql.Rows, err error) {
+	err = intercept(d.alias, ctx, "db.Query", query, args, func(ctx context.Context, _ *QueryInfo) error {
+		res, err = d.db.QueryContext(ctx, query, args...)
+		return err
+	})
+	return
+}
+
+func (d *dbQueryIntercept) QueryRow(query string, args ...interface{}) *sql.Row {
+	return d.QueryRowContext(context.Background(), query, args...)
+}
+
+func (d *dbQueryIntercept) QueryRowContext(ctx context.Context, query string, args ...interface{}) (res *sql.Row) {
+	intercept(d.alias, ctx, "db.QueryRow", query, args, func(ctx context.Context, _ *QueryInfo) error {
+		res = d.db.QueryRowContext(ctx, query, args...)
+		return res.Err()
+	})
+	return
+}
+
+func (d *dbQueryIntercept) Begin() (*sql.Tx, error) {
+	return d.BeginTx(context.Background(), nil)
+}
+
+func (d *dbQueryIntercept) BeginTx(ctx context.Context, opts *sql.TxOptions) (tx *sql.Tx, err error) {
+	err = intercept(d.alias, ctx, "db.BeginTx", "START TRANSACTION", nil, func(ctx context.Context, _ *QueryInfo) error {
+		tx, err = d.db.(txer).BeginTx(ctx, opts)
+		return err
+	})
+	return
+}
+
+func (d *dbQueryIntercept) Commit() error {
+	return intercept(d.alias, context.Background(), "tx.Commit", "COMMIT", nil, func(context.Context, *QueryInfo) error {
+		return d.db.(txEnder).Commit()
+	})
+}
+
+func (d *dbQueryIntercept) Rollback() error {
+	return intercept(d.alias, context.Background(), "tx.Rollback", "ROLLBACK", nil, func(context.Context, *QueryInfo) error {
+		return d.db.(txEnder).Rollback()
+	})
+}
+
+// wrap db querier with the debug logger and the interceptor chain of alias.
+func wrapDB(al *alias, db dbQuerier) dbQuerier {
+	if Debug {
+		db = newDbQueryLog(al, db)
+	}
+	return &dbQueryIntercept{alias: al, db: db}
+}
+
+// wrap prepared statement with the debug logger and the interceptor chain of alias.
+// the statement calls are intercepted with ctx the statement is prepared with.
+func wrapStmt(al *alias, ctx context.Context, stmt stmtQuerier, query string) stmtQuerier {
+	if Debug {
+		stmt = newStmtQueryLog(al, stmt, query)
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return &stmtQueryIntercept{alias: al, ctx: ctx, query: query, stmt: stmt}
+}
+
+// TraceInterceptor start an opentracing span for every statement.
+// the span is a child of the span in ctx and reported by the global tracer set up by lib/trace.
+func TraceInterceptor() Interceptor {
+	return func(ctx context.Context, info *QueryInfo, next QueryHandler) error {
+		span, ctx := opentracing.StartSpanFromContext(ctx, "orm "+info.Operation)
+		defer span.Finish()
+		ext.SpanKindRPCClient.Set(span)
+		ext.DBType.Set(span, "sql")
+		ext.DBInstance.Set(span, info.Alias)
+		ext.DBStatement.Set(span, info.Query)
+
+		err := next(ctx, info)
+		if err != nil {
+			ext.Error.Set(span, true)
+			span.LogKV("error", err.Error())
+		}
+		return err
+	}
+}
+
+// MetricsInterceptorLabels is the label names MetricsInterceptor observe timer with.
+var MetricsInterceptorLabels = []string{"alias", "operation", "status"}
+
+// MetricsInterceptor observe the duration of every statement in timer.
+// timer must be created with MetricsInterceptorLabels.
+func MetricsInterceptor(timer *metrics.Timer) Interceptor {
+	return func(ctx context.Context, info *QueryInfo, next QueryHandler) error {
+		a := time.Now()
+		err := next(ctx, info)
+		status := "ok"
+		if err != nil {
+			status = "fail"
+		}
+		timer.Observe(time.Since(a), info.Alias, info.Operation, status)
+		return err
+	}
+}
+
+// SlowQueryInterceptor log statements taking longer than threshold with logger.
+// nil logger means the default logger of lib/log, the args are masked like debug log by LogMask.
+func SlowQueryInterceptor(logger *log.Logger, threshold time.Duration) Interceptor {
+	return func(ctx context.Context, info *QueryInfo, next QueryHandler) error {
+		a := time.Now()
+		err := next(ctx, info)
+		elsp := time.Since(a)
+		if elsp < threshold {
+			return err
+		}
+
+		l := logger
+		if l == nil {
+			l = log.GetDefaultLogger()
+		}
+		if l == nil {
+			return err
+		}
+		fields := []zap.Field{
+			zap.String("alias", info.Alias),
+			zap.String("operation", info.Operation),
+			zap.String("query", info.Query),
+			zap.Any("args", maskLogArgs(info.Args)),
+			zap.Duration("duration", elsp),
+		}
+		if err != nil {
+			fields = append(fields, zap.Error(err))
+		}
+		l.WarnX(ctx, "orm slow query", fields...)
+		return err
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// cursor content, o is the order of the cursor, v are the values of order fields.
+type keysetCursor struct {
+	Orders string   `json:"o"`
+	Values []string `json:"v"`
+}
+
+// order field of keyset paging.
+type keysetOrder struct {
+	fi   *fieldInfo
+	desc bool
+}
+
+// get the keyset orders of querySet.
+// pk fields are added as the last orders so that the rows are in a total order.
+func (o *querySet) keysetOrders() ([]string, []keysetOrder, error) {
+	pks := o.mi.fields.pks
+	if len(pks) == 0 {
+		return nil, nil, fmt.Errorf("<QuerySeter.After> model `%s` need a primary key", o.mi.fullName)
+	}
+
+	exprs := make([]string, 0, len(o.orders)+len(pks))
+	orders := make([]keysetOrder, 0, len(o.orders)+len(pks))
+	ordered := make(map[*fieldInfo]bool, len(o.orders))
+	for _, expr := range o.orders {
+		name := strings.TrimPrefix(expr, "-")
+		fi, ok := o.mi.fields.GetByAny(name)
+		if !ok || !fi.dbcol || strings.Contains(name, ExprSep) {
+			return nil, nil, fmt.Errorf("<QuerySeter.After> order `%s` must be a column of model `%s`", expr, o.mi.fullName)
+		}
+		ordered[fi] = true
+		exprs = append(exprs, expr)
+		orders = append(orders, keysetOrder{fi: fi, desc: expr != name})
+	}
+	for _, pk := range pks {
+		if !ordered[pk] {
+			exprs = append(exprs, pk.name)
+			orders = append(orders, keysetOrder{fi: pk})
+		}
+	}
+	return exprs, orders, nil
+}
+
+// time arg passed to the driver as time.Time, the fractional seconds are kept
+// instead of formatting it to the datetime string.
+type fullTime time.Time
+
+// convert the cursor value to the type of field.
+func keysetValue(fi *fieldInfo, v string) (interface{}, error) {
+	switch {
+	case fi.fieldType == TypeDateTimeField:
+		t, err := time.Parse(time.RFC3339Nano, v)
+		return fullTime(t), err
+	case fi.fieldType == TypeBooleanField:
+		return StrTo(v).Bool()
+	case fi.fieldType&IsPositiveIntegerField > 0:
+		return StrTo(v).Uint64()
+	case fi.fieldType&IsIntegerField > 0:
+		return StrTo(v).Int64()
+	case fi.fieldType == TypeFloatField || fi.fieldType == TypeDecimalField:
+		return StrTo(v).Float64()
+	}
+	return v, nil
+}
+
+// query the rows after cursor returned by Cursor.
+// empty cursor means the first page.
+func (o querySet) After(cursor string) QuerySeter {
+	exprs, orders, err := o.keysetOrders()
+	if err != nil {
+		o.err = err
+		return &o
+	}
+	o.orders = exprs
+	if cursor == "" {
+		return &o
+	}
+
+	var c keysetCursor
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err == nil {
+		err = json.Unmarshal(data, &c)
+	}
+	if err != nil || c.Orders != strings.Join(exprs, ",") || len(c.Values) != len(orders) {
+		o.err = ErrInvalidCursor
+		return &o
+	}
+
+	values := make([]interface{}, len(orders))
+	for i, order := range orders {
+		if values[i], err = keysetValue(order.fi, c.Values[i]); err != nil {
+			o.err = ErrInvalidCursor
+			return &o
+		}
+	}
+
+	// (a > ?) OR (a = ? AND b > ?) OR (a = ? AND b = ? AND pk > ?)
+	after := NewCondition()
+	for i, order := range orders {
+		cond := NewCondition()
+		for j := 0; j < i; j++ {
+			cond = cond.And(orders[j].fi.name, values[j])
+		}
+		op := "gt"
+		if order.desc {
+			op = "lt"
+		}
+		after = after.OrCond(cond.And(order.fi.name+ExprSep+op, values[i]))
+	}
+
+	if o.cond == nil || o.cond.IsEmpty() {
+		o.cond = NewCondition()
+	} else {
+		o.cond = NewCondition().AndCond(o.cond)
+	}
+	o.cond = o.cond.AndCond(after)
+	return &o
+}
+
+// return the cursor of md, which is the last row of current page.
+func (o *querySet) Cursor(md interface{}) (string, error) {
+	exprs, orders, err := o.keysetOrders()
+	if err != nil {
+		return "", err
+	}
+
+	ind := reflect.Indirect(reflect.ValueOf(md))
+	if ind.Kind() != reflect.Struct || getFullName(ind.Type()) != o.mi.fullName {
+		return "", fmt.Errorf("<QuerySeter.Cursor> need a `%s` struct but found `%T`", o.mi.fullName, md)
+	}
+
+	c := keysetCursor{Orders: strings.Join(exprs, ",")}
+	for _, order := range orders {
+		field := ind.FieldByIndex(order.fi.fieldIndex)
+		var value interface{}
+		if order.fi.isFielder {
+			value = field.Addr().Interface().(Fielder).RawValue()
+		} else {
+			value = field.Interface()
+		}
+		// datetime is kept in full precision, or the rows in the same second are skipped or repeated
+		if rv := reflect.Indirect(reflect.ValueOf(value)); rv.IsValid() && order.fi.fieldType == TypeDateTimeField {
+			if t, ok := rv.Interface().(time.Time); ok && !t.IsZero() {
+				c.Values = append(c.Values, t.Format(time.RFC3339Nano))
+				continue
+			}
+		}
+		params := getFlatParams(order.fi, []interface{}{value}, o.orm.alias.TZ)
+		if len(params) != 1 || params[0] == nil {
+			return "", fmt.Errorf("<QuerySeter.Cursor> order field `%s` cannot be null", order.fi.name)
+		}
+		c.Values = append(c.Values, ToStr(params[0]))
+	}
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
@@ -36,6 +36,54 @@ func NewLog(out io.Writer) *Log {
 	return d
 }
 
+// LogMask masks the query args in debug log, like phone numbers, it's nil by default.
+// the encrypted values are always masked.
+// for example:
+//	orm.LogMask = func(arg interface{}) interface{} {
+//		if s, ok := arg.(string); ok && len(s) == 11 {
+//			return orm.MaskString(s, 3, 4)
+//		}
+//		return arg
+//	}
+var LogMask func(arg interface{}) interface{}
+
+// MaskString keeps the first head and the last tail runes of s and replaces the others with *.
+func MaskString(s string, head, tail int) string {
+	rs := []rune(s)
+	if head < 0 {
+		head = 0
+	}
+	if tail < 0 {
+		tail = 0
+	}
+	if head+tail >= len(rs) {
+		return strings.Repeat("*", len(rs))
+	}
+	return string(rs[:head]) + strings.Repeat("*", len(rs)-head-tail) + string(rs[len(rs)-tail:])
+}
+
+// mask the query arg for debug log.
+func maskLogArg(arg interface{}) interface{} {
+	if s, ok := arg.(string); ok && strings.HasPrefix(s, encryptPrefix) {
+		if i := strings.Index(s[len(encryptPrefix):], ":"); i >= 0 {
+			return s[:len(encryptPrefix)+i+1] + "***"
+		}
+	}
+	if LogMask != nil {
+		return LogMask(arg)
+	}
+	return arg
+}
+
+// mask the query args for log.
+func maskLogArgs(args []interface{}) []interface{} {
+	masked := make([]interface{}, len(args))
+	for i, arg := range args {
+		masked[i] = maskLogArg(arg)
+	}
+	return masked
+}
+
 func debugLogQueies(alias *alias, operaton, query string, t time.Time, err error, args ...interface{}) {
 	sub := time.Now().Sub(t) / 1e5
 	elsp := float64(int(sub)) / 10.0
@@ -46,7 +94,7 @@ func debugLogQueies(alias *alias, operaton, query string, t time.Time, err error
 	con := fmt.Sprintf(" -[Queries/%s] - [%s / %11s / %7.1fms] - [%s]", alias.Name, flag, operaton, elsp, query)
 	cons := make([]string, 0, len(args))
 	for _, arg := range args {
-		cons = append(cons, fmt.Sprintf("%v", arg))
+		cons = append(cons, fmt.Sprintf("%v", maskLogArg(arg)))
 	}
 	if len(cons) > 0 {
 		con += fmt.Sprintf(" - `%s`", strings.Join(cons, "`, `"))
@@ -15,6 +15,7 @@
 package orm
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 )
@@ -70,18 +71,14 @@ func (o *insertSet) Close() error {
 }
 
 // create new insert queryer.
-func newInsertSet(orm *orm, mi *modelInfo) (Inserter, error) {
+func newInsertSet(ctx context.Context, orm *orm, db dbQuerier, mi *modelInfo) (Inserter, error) {
 	bi := new(insertSet)
 	bi.orm = orm
 	bi.mi = mi
-	st, query, err := orm.alias.DbBaser.PrepareInsert(orm.db, mi)
+	st, query, err := orm.alias.DbBaser.PrepareInsert(db, mi)
 	if err != nil {
 		return nil, err
 	}
-	if Debug {
-		bi.stmt = newStmtQueryLog(orm.alias, st, query)
-	} else {
-		bi.stmt = st
-	}
+	bi.stmt = wrapStmt(orm.alias, ctx, st, query)
 	return bi, nil
 }
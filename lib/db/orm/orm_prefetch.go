@@ -0,0 +1,276 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// max number of values in one IN (...) of prefetch query.
+const prefetchBatchSize = 500
+
+// models loaded by prefetch, inds are the addressable model structs.
+type prefetchLevel struct {
+	mi   *modelInfo
+	inds []reflect.Value
+}
+
+// set relation names loaded after All and One, cascaded names are split by __.
+func (o querySet) Prefetch(names ...string) QuerySeter {
+	o.prefetch = append(o.prefetch[:len(o.prefetch):len(o.prefetch)], names...)
+	return &o
+}
+
+// load the prefetch relations of models in container.
+func (o *querySet) prefetchRelated(container interface{}) error {
+	ind := reflect.Indirect(reflect.ValueOf(container))
+	var inds []reflect.Value
+	if ind.Kind() == reflect.Slice {
+		inds = make([]reflect.Value, 0, ind.Len())
+		for i := 0; i < ind.Len(); i++ {
+			inds = append(inds, reflect.Indirect(ind.Index(i)))
+		}
+	} else {
+		inds = append(inds, ind)
+	}
+	if len(inds) == 0 {
+		return nil
+	}
+
+	// loaded levels by the path, shared by names with the same prefix
+	levels := map[string]*prefetchLevel{"": {mi: o.mi, inds: inds}}
+	for _, name := range o.prefetch {
+		path := ""
+		parent := levels[path]
+		for _, n := range strings.Split(name, ExprSep) {
+			path += ExprSep + n
+			level, ok := levels[path]
+			if !ok {
+				var err error
+				level, err = o.prefetchField(parent, n)
+				if err != nil {
+					return err
+				}
+				levels[path] = level
+			}
+			parent = level
+		}
+	}
+	return nil
+}
+
+// load the relation name of parent models, return the loaded models.
+func (o *querySet) prefetchField(parent *prefetchLevel, name string) (*prefetchLevel, error) {
+	mi := parent.mi
+	fi, ok := mi.fields.GetByAny(name)
+	if !ok || !fi.inModel || !fi.rel && !fi.reverse {
+		return nil, fmt.Errorf("<QuerySeter.Prefetch> name `%s` is not a relation field of model `%s`", name, mi.fullName)
+	}
+	rmi := fi.relModelInfo
+	level := &prefetchLevel{mi: rmi}
+	if len(parent.inds) == 0 {
+		return level, nil
+	}
+
+	switch {
+	case fi.fieldType == RelForeignKey || fi.fieldType == RelOneToOne:
+		var keys []interface{}
+		for _, ind := range parent.inds {
+			if rel := ind.FieldByIndex(fi.fieldIndex); !rel.IsNil() {
+				keys = append(keys, prefetchPk(rmi, rel))
+			}
+		}
+		children, err := o.prefetchRows(rmi, rmi.fields.pk.name, keys)
+		if err != nil {
+			return nil, err
+		}
+		byPk := make(map[string]reflect.Value, len(children))
+		for _, child := range children {
+			byPk[ToStr(prefetchPk(rmi, child))] = child
+			level.inds = append(level.inds, child.Elem())
+		}
+		for _, ind := range parent.inds {
+			field := ind.FieldByIndex(fi.fieldIndex)
+			if field.IsNil() {
+				continue
+			}
+			if child, ok := byPk[ToStr(prefetchPk(rmi, field))]; ok {
+				field.Set(child)
+			}
+		}
+
+	case fi.fieldType == RelManyToMany || fi.reverseFieldInfo.mi.isThrough:
+		mfi, rfi := fi.reverseFieldInfo, fi.reverseFieldInfoTwo
+		pairs, err := o.prefetchThrough(fi.relThroughModelInfo, mfi, rfi, parentPks(parent))
+		if err != nil {
+			return nil, err
+		}
+		keys := make([]interface{}, 0, len(pairs))
+		for _, pair := range pairs {
+			keys = append(keys, pair[1])
+		}
+		children, err := o.prefetchRows(rmi, rmi.fields.pk.name, keys)
+		if err != nil {
+			return nil, err
+		}
+		byPk := make(map[string]reflect.Value, len(children))
+		for _, child := range children {
+			byPk[ToStr(prefetchPk(rmi, child))] = child
+			level.inds = append(level.inds, child.Elem())
+		}
+		groups := make(map[string][]reflect.Value)
+		for _, pair := range pairs {
+			if child, ok := byPk[ToStr(pair[1])]; ok {
+				key := ToStr(pair[0])
+				groups[key] = append(groups[key], child)
+			}
+		}
+		setPrefetchSlices(mi, fi, parent.inds, groups)
+
+	default:
+		// reverse relations, the fk field is in the related model
+		rfi := fi.reverseFieldInfo
+		children, err := o.prefetchRows(rmi, rfi.name, parentPks(parent))
+		if err != nil {
+			return nil, err
+		}
+		groups := make(map[string][]reflect.Value)
+		for _, child := range children {
+			level.inds = append(level.inds, child.Elem())
+			if rel := child.Elem().FieldByIndex(rfi.fieldIndex); !rel.IsNil() {
+				key := ToStr(prefetchPk(mi, rel))
+				groups[key] = append(groups[key], child)
+			}
+		}
+		if fi.fieldType == RelReverseOne {
+			for _, ind := range parent.inds {
+				field := ind.FieldByIndex(fi.fieldIndex)
+				if children := groups[ToStr(prefetchPk(mi, ind.Addr()))]; len(children) > 0 {
+					field.Set(children[0])
+				} else {
+					field.Set(reflect.Zero(field.Type()))
+				}
+			}
+		} else {
+			setPrefetchSlices(mi, fi, parent.inds, groups)
+		}
+	}
+	return level, nil
+}
+
+// query the models of mi whose field name is in values, batch by prefetchBatchSize.
+// it returns the ptrs of models.
+func (o *querySet) prefetchRows(mi *modelInfo, name string, values []interface{}) ([]reflect.Value, error) {
+	values = uniqueValues(values)
+	var rows []reflect.Value
+	for len(values) > 0 {
+		n := len(values)
+		if n > prefetchBatchSize {
+			n = prefetchBatchSize
+		}
+		qs := o.prefetchQs(mi).Filter(name+ExprSep+"in", values[:n])
+		values = values[n:]
+
+		container := reflect.New(reflect.SliceOf(mi.addrField.Type()))
+		if _, err := qs.All(container.Interface()); err != nil {
+			return nil, err
+		}
+		slice := container.Elem()
+		for i := 0; i < slice.Len(); i++ {
+			rows = append(rows, slice.Index(i))
+		}
+	}
+	return rows, nil
+}
+
+// query the pairs of origin and related pk of through model mi whose mfi is in values.
+func (o *querySet) prefetchThrough(mi *modelInfo, mfi, rfi *fieldInfo, values []interface{}) ([][2]interface{}, error) {
+	values = uniqueValues(values)
+	var pairs [][2]interface{}
+	for len(values) > 0 {
+		n := len(values)
+		if n > prefetchBatchSize {
+			n = prefetchBatchSize
+		}
+		qs := o.prefetchQs(mi).Filter(mfi.name+ExprSep+"in", values[:n]).OrderBy(mi.fields.pk.name)
+		values = values[n:]
+
+		var lists []ParamsList
+		if _, err := qs.ValuesList(&lists, mfi.column, rfi.column); err != nil {
+			return nil, err
+		}
+		for _, list := range lists {
+			pairs = append(pairs, [2]interface{}{list[0], list[1]})
+		}
+	}
+	return pairs, nil
+}
+
+// create the querySet of prefetch query, it shares the context and primary of o.
+func (o *querySet) prefetchQs(mi *modelInfo) QuerySeter {
+	qs := newQuerySet(o.orm, mi).(*querySet)
+	qs.ctx = o.ctx
+	qs.forContext = o.forContext
+	qs.primary = o.primary
+	qs.limit = -1
+	return qs
+}
+
+// get the pk values of parent models.
+func parentPks(parent *prefetchLevel) []interface{} {
+	pks := make([]interface{}, 0, len(parent.inds))
+	for _, ind := range parent.inds {
+		pks = append(pks, ind.FieldByIndex(parent.mi.fields.pk.fieldIndex).Interface())
+	}
+	return pks
+}
+
+// get the pk value of model ptr.
+func prefetchPk(mi *modelInfo, ptr reflect.Value) interface{} {
+	return reflect.Indirect(ptr).FieldByIndex(mi.fields.pk.fieldIndex).Interface()
+}
+
+// remove the duplicated values.
+func uniqueValues(values []interface{}) []interface{} {
+	seen := make(map[string]bool, len(values))
+	res := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		key := ToStr(v)
+		if !seen[key] {
+			seen[key] = true
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// set the grouped related model ptrs to the slice field fi of parent models.
+func setPrefetchSlices(mi *modelInfo, fi *fieldInfo, inds []reflect.Value, groups map[string][]reflect.Value) {
+	for _, ind := range inds {
+		field := ind.FieldByIndex(fi.fieldIndex)
+		children := groups[ToStr(ind.FieldByIndex(mi.fields.pk.fieldIndex).Interface())]
+		slice := reflect.MakeSlice(field.Type(), 0, len(children))
+		for _, child := range children {
+			if field.Type().Elem().Kind() == reflect.Ptr {
+				slice = reflect.Append(slice, child)
+			} else {
+				slice = reflect.Append(slice, child.Elem())
+			}
+		}
+		field.Set(slice)
+	}
+}
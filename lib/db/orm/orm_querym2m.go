@@ -14,7 +14,10 @@
 
 package orm
 
-import "reflect"
+import (
+	"context"
+	"reflect"
+)
 
 // model to model struct
 type queryM2M struct {
@@ -96,7 +99,7 @@ func (o *queryM2M) Add(mds ...interface{}) (int64, error) {
 	}
 	names = append(names, otherNames...)
 	values = append(values, otherValues...)
-	return dbase.InsertValue(orm.db, mi, true, names, values)
+	return dbase.InsertValue(o.qs.dbQuerier(), mi, true, names, values)
 }
 
 // remove models following the origin model relationship
@@ -110,7 +113,7 @@ func (o *queryM2M) Remove(mds ...interface{}) (int64, error) {
 // check model is existed in relationship of origin model
 func (o *queryM2M) Exist(md interface{}) bool {
 	fi := o.fi
-	return o.qs.Filter(fi.reverseFieldInfo.name, o.md).
+	return o.relatedQs().Filter(fi.reverseFieldInfo.name, o.md).
 		Filter(fi.reverseFieldInfoTwo.name, md).Exist()
 }
 
@@ -123,18 +126,38 @@ func (o *queryM2M) Clear() (int64, error) {
 // count all related models of origin model
 func (o *queryM2M) Count() (int64, error) {
 	fi := o.fi
-	return o.qs.Filter(fi.reverseFieldInfo.name, o.md).Count()
+	return o.relatedQs().Filter(fi.reverseFieldInfo.name, o.md).Count()
+}
+
+// include the deleted rows of the m2m table and the related model.
+// Remove and Clear really delete the rows of m2m table with soft_delete field.
+func (o queryM2M) Unscoped() QueryM2Mer {
+	o.qs = o.qs.Unscoped().(*querySet)
+	return &o
+}
+
+// get the querySet of m2m table excluding the deleted related models.
+func (o *queryM2M) relatedQs() *querySet {
+	qs := *o.qs
+	rfi := o.fi.reverseFieldInfoTwo
+	if sfi := rfi.relModelInfo.fields.softDelete; sfi != nil {
+		qs.softDeleteRels = append(qs.softDeleteRels[:len(qs.softDeleteRels):len(qs.softDeleteRels)],
+			softDeleteRel{sfi, rfi.name + ExprSep + sfi.name})
+	}
+	return &qs
 }
 
 var _ QueryM2Mer = new(queryM2M)
 
 // create new M2M queryer.
-func newQueryM2M(md interface{}, o *orm, mi *modelInfo, fi *fieldInfo, ind reflect.Value) QueryM2Mer {
+func newQueryM2M(ctx context.Context, md interface{}, o *orm, mi *modelInfo, fi *fieldInfo, ind reflect.Value) QueryM2Mer {
 	qm2m := new(queryM2M)
 	qm2m.md = md
 	qm2m.mi = mi
 	qm2m.fi = fi
 	qm2m.ind = ind
 	qm2m.qs = newQuerySet(o, fi.relThroughModelInfo).(*querySet)
+	qm2m.qs.ctx = ctx
+	qm2m.qs.forContext = true
 	return qm2m
 }
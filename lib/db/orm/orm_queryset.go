@@ -66,9 +66,20 @@ type querySet struct {
 	orders     []string
 	distinct   bool
 	forupdate  bool
+	primary    bool
 	orm        *orm
 	ctx        context.Context
 	forContext bool
+
+	softDelete     SoftDeleteMode
+	softDeleteRels []softDeleteRel
+
+	prefetch   []string
+	aggregates []aggregateExpr
+
+	allShards bool
+
+	err error // error of building querySet, returned when executed
 }
 
 var _ QuerySeter = new(querySet)
@@ -146,6 +157,12 @@ func (o querySet) ForUpdate() QuerySeter {
 	return &o
 }
 
+// query the primary even if the alias has read replicas
+func (o querySet) ForcePrimary() QuerySeter {
+	o.primary = true
+	return &o
+}
+
 // set relation model to query together.
 // it will query relation models and assign to parent model.
 func (o querySet) RelatedSel(params ...interface{}) QuerySeter {
@@ -179,23 +196,55 @@ func (o querySet) GetCond() *Condition {
 
 // return QuerySeter execution result number
 func (o *querySet) Count() (int64, error) {
-	return o.orm.alias.DbBaser.Count(o.orm.db, o, o.mi, o.cond, o.orm.alias.TZ)
+	if o.err != nil {
+		return 0, o.err
+	}
+	if o.mi.shard != nil {
+		return o.shardSum(func(qs *querySet) (int64, error) { return qs.Count() })
+	}
+	return o.orm.alias.DbBaser.Count(o.readQuerier(), o, o.mi, o.scopedCond(), o.orm.alias.TZ)
 }
 
 // check result empty or not after QuerySeter executed
 func (o *querySet) Exist() bool {
-	cnt, _ := o.orm.alias.DbBaser.Count(o.orm.db, o, o.mi, o.cond, o.orm.alias.TZ)
+	if o.err != nil {
+		return false
+	}
+	cnt, _ := o.Count()
 	return cnt > 0
 }
 
 // execute update with parameters
 func (o *querySet) Update(values Params) (int64, error) {
-	return o.orm.alias.DbBaser.UpdateBatch(o.orm.db, o, o.mi, o.cond, values, o.orm.alias.TZ)
+	if o.err != nil {
+		return 0, o.err
+	}
+	if o.mi.shard != nil {
+		names := make([]string, 0, len(values))
+		for name := range values {
+			names = append(names, name)
+		}
+		if err := o.mi.shard.checkUpdate(o.mi, names); err != nil {
+			return 0, err
+		}
+		return o.shardSum(func(qs *querySet) (int64, error) { return qs.Update(values) })
+	}
+	return o.orm.alias.DbBaser.UpdateBatch(o.dbQuerier(), o, o.mi, o.scopedCond(), values, o.orm.alias.TZ)
 }
 
-// execute delete
+// execute delete.
+// rows of model with soft_delete field are marked as deleted unless Unscoped or OnlyDeleted.
 func (o *querySet) Delete() (int64, error) {
-	return o.orm.alias.DbBaser.DeleteBatch(o.orm.db, o, o.mi, o.cond, o.orm.alias.TZ)
+	if o.err != nil {
+		return 0, o.err
+	}
+	if o.mi.shard != nil {
+		return o.shardSum(func(qs *querySet) (int64, error) { return qs.Delete() })
+	}
+	if fi := o.mi.fields.softDelete; fi != nil && o.softDelete == SoftDeleteScoped {
+		return o.orm.alias.DbBaser.UpdateBatch(o.dbQuerier(), o, o.mi, o.scopedCond(), Params{fi.column: o.softDeleteValue(true)}, o.orm.alias.TZ)
+	}
+	return o.orm.alias.DbBaser.DeleteBatch(o.dbQuerier(), o, o.mi, o.scopedCond(), o.orm.alias.TZ)
 }
 
 // return a insert queryer.
@@ -204,20 +253,48 @@ func (o *querySet) Delete() (int64, error) {
 // 	i,err := sq.PrepareInsert()
 // 	i.Add(&user1{},&user2{})
 func (o *querySet) PrepareInsert() (Inserter, error) {
-	return newInsertSet(o.orm, o.mi)
+	if o.mi.shard != nil {
+		qs, err := o.oneShardQs()
+		if err != nil {
+			return nil, err
+		}
+		return qs.PrepareInsert()
+	}
+	return newInsertSet(o.ctx, o.orm, o.dbQuerier(), o.mi)
 }
 
 // query all data and map to containers.
 // cols means the columns when querying.
 func (o *querySet) All(container interface{}, cols ...string) (int64, error) {
-	return o.orm.alias.DbBaser.ReadBatch(o.orm.db, o, o.mi, o.cond, container, o.orm.alias.TZ, cols)
+	if o.err != nil {
+		return 0, o.err
+	}
+	if o.mi.shard != nil {
+		return o.shardAll(container, cols...)
+	}
+	num, err := o.orm.alias.DbBaser.ReadBatch(o.readQuerier(), o, o.mi, o.scopedCond(), container, o.orm.alias.TZ, cols)
+	if err != nil || num == 0 {
+		return num, err
+	}
+	if len(o.prefetch) > 0 {
+		if err := o.prefetchRelated(container); err != nil {
+			return num, err
+		}
+	}
+	return num, callContainerHooks(o.ctx, hookAfterRead, container)
 }
 
 // query one row data and map to containers.
 // cols means the columns when querying.
 func (o *querySet) One(container interface{}, cols ...string) error {
+	if o.err != nil {
+		return o.err
+	}
+	if o.mi.shard != nil {
+		return o.shardOne(container, cols...)
+	}
 	o.limit = 1
-	num, err := o.orm.alias.DbBaser.ReadBatch(o.orm.db, o, o.mi, o.cond, container, o.orm.alias.TZ, cols)
+	num, err := o.orm.alias.DbBaser.ReadBatch(o.readQuerier(), o, o.mi, o.scopedCond(), container, o.orm.alias.TZ, cols)
 	if err != nil {
 		return err
 	}
@@ -228,26 +305,55 @@ func (o *querySet) One(container interface{}, cols ...string) error {
 	if num > 1 {
 		return ErrMultiRows
 	}
-	return nil
+	if len(o.prefetch) > 0 {
+		if err := o.prefetchRelated(container); err != nil {
+			return err
+		}
+	}
+	return callContainerHooks(o.ctx, hookAfterRead, container)
 }
 
 // query all data and map to []map[string]interface.
 // expres means condition expression.
 // it converts data to []map[column]value.
 func (o *querySet) Values(results *[]Params, exprs ...string) (int64, error) {
-	return o.orm.alias.DbBaser.ReadValues(o.orm.db, o, o.mi, o.cond, exprs, results, o.orm.alias.TZ)
+	if o.err != nil {
+		return 0, o.err
+	}
+	if o.mi.shard != nil {
+		return o.shardValues(results, func(qs *querySet, results interface{}) (int64, error) {
+			return qs.Values(results.(*[]Params), exprs...)
+		})
+	}
+	return o.orm.alias.DbBaser.ReadValues(o.readQuerier(), o, o.mi, o.scopedCond(), exprs, results, o.orm.alias.TZ)
 }
 
 // query all data and map to [][]interface
 // it converts data to [][column_index]value
 func (o *querySet) ValuesList(results *[]ParamsList, exprs ...string) (int64, error) {
-	return o.orm.alias.DbBaser.ReadValues(o.orm.db, o, o.mi, o.cond, exprs, results, o.orm.alias.TZ)
+	if o.err != nil {
+		return 0, o.err
+	}
+	if o.mi.shard != nil {
+		return o.shardValues(results, func(qs *querySet, results interface{}) (int64, error) {
+			return qs.ValuesList(results.(*[]ParamsList), exprs...)
+		})
+	}
+	return o.orm.alias.DbBaser.ReadValues(o.readQuerier(), o, o.mi, o.scopedCond(), exprs, results, o.orm.alias.TZ)
 }
 
 // query all data and map to []interface.
 // it's designed for one row record set, auto change to []value, not [][column]value.
 func (o *querySet) ValuesFlat(result *ParamsList, expr string) (int64, error) {
-	return o.orm.alias.DbBaser.ReadValues(o.orm.db, o, o.mi, o.cond, []string{expr}, result, o.orm.alias.TZ)
+	if o.err != nil {
+		return 0, o.err
+	}
+	if o.mi.shard != nil {
+		return o.shardValues(result, func(qs *querySet, result interface{}) (int64, error) {
+			return qs.ValuesFlat(result.(*ParamsList), expr)
+		})
+	}
+	return o.orm.alias.DbBaser.ReadValues(o.readQuerier(), o, o.mi, o.scopedCond(), []string{expr}, result, o.orm.alias.TZ)
 }
 
 // query all rows into map[string]interface with specify key and value column name.
@@ -279,16 +385,44 @@ func (o *querySet) RowsToStruct(ptrStruct interface{}, keyCol, valueCol string)
 }
 
 // set context to QuerySeter.
-func (o querySet) WithContext(ctx context.Context) QuerySeter {
+func (o querySet) WithCtx(ctx context.Context) QuerySeter {
 	o.ctx = ctx
 	o.forContext = true
+	o.bindTenant(ctx)
 	return &o
 }
 
+// get db querier bound to the context of QuerySeter.
+func (o *querySet) dbQuerier() dbQuerier {
+	if o.forContext {
+		return newDbQueryCtx(o.ctx, o.orm.db)
+	}
+	return o.orm.db
+}
+
+// get db querier for SELECT statements, it may be served by a replica.
+func (o *querySet) readQuerier() dbQuerier {
+	if o.forupdate || o.primary {
+		return o.dbQuerier()
+	}
+	var ctx context.Context
+	if o.forContext {
+		ctx = o.ctx
+	}
+	return o.orm.readQuerier(ctx)
+}
+
 // create new QuerySeter.
 func newQuerySet(orm *orm, mi *modelInfo) QuerySeter {
 	o := new(querySet)
 	o.mi = mi
 	o.orm = orm
+	if mi.tenant != nil {
+		if tmi, err := orm.tenantModel(mi); err != nil {
+			o.err = err
+		} else {
+			o.mi = tmi
+		}
+	}
 	return o
 }
@@ -15,6 +15,7 @@
 package orm
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"reflect"
@@ -47,15 +48,11 @@ func newRawPreparer(rs *rawSet) (RawPreparer, error) {
 	query := rs.query
 	rs.orm.alias.DbBaser.ReplaceMarks(&query)
 
-	st, err := rs.orm.db.Prepare(query)
+	st, err := rs.dbQuerier().Prepare(query)
 	if err != nil {
 		return nil, err
 	}
-	if Debug {
-		o.stmt = newStmtQueryLog(rs.orm.alias, st, query)
-	} else {
-		o.stmt = st
-	}
+	o.stmt = wrapStmt(rs.orm.alias, rs.ctx, st, query)
 	return o, nil
 }
 
@@ -63,7 +60,10 @@ func newRawPreparer(rs *rawSet) (RawPreparer, error) {
 type rawSet struct {
 	query string
 	args  []interface{}
+	named Params // named params bound by BindStruct or BindMap
+	err   error  // error of binding the named params
 	orm   *orm
+	ctx   context.Context
 }
 
 var _ RawSeter = new(rawSet)
@@ -74,13 +74,24 @@ func (o rawSet) SetArgs(args ...interface{}) RawSeter {
 	return &o
 }
 
+// set context for every query
+func (o rawSet) WithCtx(ctx context.Context) RawSeter {
+	o.ctx = ctx
+	return &o
+}
+
+// get db querier bound to the context of RawSeter.
+func (o *rawSet) dbQuerier() dbQuerier {
+	return newDbQueryCtx(o.ctx, o.orm.db)
+}
+
 // execute raw sql and return sql.Result
 func (o *rawSet) Exec() (sql.Result, error) {
-	query := o.query
-	o.orm.alias.DbBaser.ReplaceMarks(&query)
-
-	args := getFlatParams(nil, o.args, o.orm.alias.TZ)
-	return o.orm.db.Exec(query, args...)
+	query, args, err := o.getQueryArgs()
+	if err != nil {
+		return nil, err
+	}
+	return o.dbQuerier().Exec(query, args...)
 }
 
 // set field value to row container
@@ -295,11 +306,11 @@ func (o *rawSet) QueryRow(containers ...interface{}) error {
 		}
 	}
 
-	query := o.query
-	o.orm.alias.DbBaser.ReplaceMarks(&query)
-
-	args := getFlatParams(nil, o.args, o.orm.alias.TZ)
-	rows, err := o.orm.db.Query(query, args...)
+	query, args, err := o.getQueryArgs()
+	if err != nil {
+		return err
+	}
+	rows, err := o.dbQuerier().Query(query, args...)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return ErrNoRows
@@ -387,6 +398,53 @@ func (o *rawSet) QueryRow(containers ...interface{}) error {
 	return nil
 }
 
+// set the scanned column values to the fields of struct ind.
+// mi is the model info of struct, or nil if it is not a registered model.
+func (o *rawSet) setStructFields(ind reflect.Value, mi *modelInfo, columns []string, columnsMp map[string]interface{}) {
+	if mi != nil {
+		for _, col := range columns {
+			if fi := mi.fields.GetByColumn(col); fi != nil {
+				value := reflect.ValueOf(columnsMp[col]).Elem().Interface()
+				field := ind.FieldByIndex(fi.fieldIndex)
+				if fi.fieldType&IsRelField > 0 {
+					mf := reflect.New(fi.relModelInfo.addrField.Elem().Type())
+					field.Set(mf)
+					field = mf.Elem().FieldByIndex(fi.relModelInfo.fields.pk.fieldIndex)
+				}
+				o.setFieldValue(field, value)
+			}
+		}
+	} else {
+		// define recursive function
+		var recursiveSetField func(rv reflect.Value)
+		recursiveSetField = func(rv reflect.Value) {
+			for i := 0; i < rv.NumField(); i++ {
+				f := rv.Field(i)
+				fe := rv.Type().Field(i)
+
+				// check if the field is a Struct
+				// recursive the Struct type
+				if fe.Type.Kind() == reflect.Struct {
+					recursiveSetField(f)
+				}
+
+				_, tags := parseStructTag(fe.Tag.Get(defaultStructTagName))
+				var col string
+				if col = tags["column"]; col == "" {
+					col = nameStrategyMap[nameStrategy](fe.Name)
+				}
+				if v, ok := columnsMp[col]; ok {
+					value := reflect.ValueOf(v).Elem().Interface()
+					o.setFieldValue(f, value)
+				}
+			}
+		}
+
+		// init call the recursive function
+		recursiveSetField(ind)
+	}
+}
+
 // query data rows and map to container
 func (o *rawSet) QueryRows(containers ...interface{}) (int64, error) {
 	var (
@@ -428,11 +486,11 @@ func (o *rawSet) QueryRows(containers ...interface{}) (int64, error) {
 		}
 	}
 
-	query := o.query
-	o.orm.alias.DbBaser.ReplaceMarks(&query)
-
-	args := getFlatParams(nil, o.args, o.orm.alias.TZ)
-	rows, err := o.orm.db.Query(query, args...)
+	query, args, err := o.getQueryArgs()
+	if err != nil {
+		return 0, err
+	}
+	rows, err := o.dbQuerier().Query(query, args...)
 	if err != nil {
 		return 0, err
 	}
@@ -479,48 +537,7 @@ func (o *rawSet) QueryRows(containers ...interface{}) (int64, error) {
 				ind = ind.Elem()
 			}
 
-			if sMi != nil {
-				for _, col := range columns {
-					if fi := sMi.fields.GetByColumn(col); fi != nil {
-						value := reflect.ValueOf(columnsMp[col]).Elem().Interface()
-						field := ind.FieldByIndex(fi.fieldIndex)
-						if fi.fieldType&IsRelField > 0 {
-							mf := reflect.New(fi.relModelInfo.addrField.Elem().Type())
-							field.Set(mf)
-							field = mf.Elem().FieldByIndex(fi.relModelInfo.fields.pk.fieldIndex)
-						}
-						o.setFieldValue(field, value)
-					}
-				}
-			} else {
-				// define recursive function
-				var recursiveSetField func(rv reflect.Value)
-				recursiveSetField = func(rv reflect.Value) {
-					for i := 0; i < rv.NumField(); i++ {
-						f := rv.Field(i)
-						fe := rv.Type().Field(i)
-
-						// check if the field is a Struct
-						// recursive the Struct type
-						if fe.Type.Kind() == reflect.Struct {
-							recursiveSetField(f)
-						}
-
-						_, tags := parseStructTag(fe.Tag.Get(defaultStructTagName))
-						var col string
-						if col = tags["column"]; col == "" {
-							col = nameStrategyMap[nameStrategy](fe.Name)
-						}
-						if v, ok := columnsMp[col]; ok {
-							value := reflect.ValueOf(v).Elem().Interface()
-							o.setFieldValue(f, value)
-						}
-					}
-				}
-
-				// init call the recursive function
-				recursiveSetField(ind)
-			}
+			o.setStructFields(ind, sMi, columns, columnsMp)
 
 			if eTyps[0].Kind() == reflect.Ptr {
 				ind = ind.Addr()
@@ -554,6 +571,55 @@ func (o *rawSet) QueryRows(containers ...interface{}) (int64, error) {
 	return cnt, nil
 }
 
+// query data rows one at a time into the struct container and call fn with it.
+// the container is reused for every row, fn should copy the values it keeps.
+// rows are closed when fn returns error, which stops the iteration and is returned.
+func (o *rawSet) Iterate(ctx context.Context, container interface{}, fn func(row interface{}) error) (int64, error) {
+	val := reflect.ValueOf(container)
+	ind := reflect.Indirect(val)
+	if val.Kind() != reflect.Ptr || ind.Kind() != reflect.Struct {
+		panic(fmt.Errorf("<RawSeter.Iterate> container must be a struct ptr"))
+	}
+	mi, _ := modelCache.getByFullName(getFullName(ind.Type()))
+
+	query, args, err := o.getQueryArgs()
+	if err != nil {
+		return 0, err
+	}
+	rows, err := newDbQueryCtx(ctx, o.orm.db).Query(query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	columnsMp := make(map[string]interface{}, len(columns))
+	refs := make([]interface{}, 0, len(columns))
+	for _, col := range columns {
+		var ref interface{}
+		columnsMp[col] = &ref
+		refs = append(refs, &ref)
+	}
+
+	var cnt int64
+	for rows.Next() {
+		if err := rows.Scan(refs...); err != nil {
+			return cnt, err
+		}
+		ind.Set(reflect.Zero(ind.Type()))
+		o.setStructFields(ind, mi, columns, columnsMp)
+		cnt++
+		if err := fn(container); err != nil {
+			return cnt, err
+		}
+	}
+	return cnt, rows.Err()
+}
+
 func (o *rawSet) readValues(container interface{}, needCols []string) (int64, error) {
 	var (
 		maps  []Params
@@ -573,13 +639,13 @@ func (o *rawSet) readValues(container interface{}, needCols []string) (int64, er
 		panic(fmt.Errorf("<RawSeter> unsupport read values type `%T`", container))
 	}
 
-	query := o.query
-	o.orm.alias.DbBaser.ReplaceMarks(&query)
-
-	args := getFlatParams(nil, o.args, o.orm.alias.TZ)
+	query, args, err := o.getQueryArgs()
+	if err != nil {
+		return 0, err
+	}
 
 	var rs *sql.Rows
-	rs, err := o.orm.db.Query(query, args...)
+	rs, err = o.dbQuerier().Query(query, args...)
 	if err != nil {
 		return 0, err
 	}
@@ -700,12 +766,12 @@ func (o *rawSet) queryRowsTo(container interface{}, keyCol, valueCol string) (in
 		ind = &id
 	}
 
-	query := o.query
-	o.orm.alias.DbBaser.ReplaceMarks(&query)
-
-	args := getFlatParams(nil, o.args, o.orm.alias.TZ)
+	query, args, err := o.getQueryArgs()
+	if err != nil {
+		return 0, err
+	}
 
-	rs, err := o.orm.db.Query(query, args...)
+	rs, err := o.dbQuerier().Query(query, args...)
 	if err != nil {
 		return 0, err
 	}
@@ -834,7 +900,11 @@ func (o *rawSet) RowsToStruct(ptrStruct interface{}, keyCol, valueCol string) (i
 }
 
 // return prepared raw statement for used in times.
+// the named params of BindStruct and BindMap are not supported, use the args of SetArgs.
 func (o *rawSet) Prepare() (RawPreparer, error) {
+	if o.named != nil {
+		return nil, fmt.Errorf("<RawSeter.Prepare> named params are not supported by prepared statement")
+	}
 	return newRawPreparer(o)
 }
 
@@ -0,0 +1,207 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// bind the named params of raw sql to the fields of struct ptr.
+// the param name is the column name of field, like QueryRow maps the columns to struct.
+// the fields of model are converted like Insert, so the encrypted field is bound to the ciphertext,
+// its blind index field to the blind index and the json field to the json document.
+func (o rawSet) BindStruct(ptrStruct interface{}) RawSeter {
+	val := reflect.ValueOf(ptrStruct)
+	ind := reflect.Indirect(val)
+	if val.Kind() != reflect.Ptr || ind.Kind() != reflect.Struct {
+		panic(fmt.Errorf("<RawSeter.BindStruct> need a struct ptr, but get `%T`", ptrStruct))
+	}
+	named := make(Params)
+	if mi, ok := modelCache.getByFullName(getFullName(ind.Type())); ok {
+		d := &dbBase{ins: o.orm.alias.DbBaser}
+		for _, fi := range mi.fields.fieldsDB {
+			field := ind.FieldByIndex(fi.fieldIndex)
+			if fi.rel {
+				var value interface{}
+				if !field.IsNil() {
+					value, _ = getFieldPk(fi.relModelInfo.fields.pk, reflect.Indirect(field))
+				}
+				named[fi.column] = value
+				continue
+			}
+			if fi.autoNow || fi.autoNowAdd {
+				// the time is bound as it is, not the time of update
+				named[fi.column] = field.Interface()
+				continue
+			}
+			value, err := d.collectFieldValue(mi, fi, ind, false, o.orm.alias.TZ)
+			if err != nil {
+				o.err = err
+				return &o
+			}
+			named[fi.column] = value
+		}
+	} else {
+		bindStructFields(ind, named)
+	}
+	o.named = named
+	return &o
+}
+
+// add the fields of struct ind to named params, fields of embedded struct are added too.
+func bindStructFields(ind reflect.Value, named Params) {
+	for i := 0; i < ind.NumField(); i++ {
+		fe := ind.Type().Field(i)
+		if fe.PkgPath != "" && !fe.Anonymous {
+			continue
+		}
+		attrs, tags := parseStructTag(fe.Tag.Get(defaultStructTagName))
+		if attrs["-"] {
+			continue
+		}
+		field := ind.Field(i)
+		if fe.Anonymous && field.Kind() == reflect.Struct {
+			bindStructFields(field, named)
+			continue
+		}
+		col := tags["column"]
+		if col == "" {
+			col = nameStrategyMap[nameStrategy](fe.Name)
+		}
+		named[col] = field.Interface()
+	}
+}
+
+// bind the named params of raw sql to the values of params.
+func (o rawSet) BindMap(params Params) RawSeter {
+	o.named = params
+	return &o
+}
+
+// get the query and args of raw sql, the table placeholders are replaced by the tables of tenant,
+// and the named params are replaced by the marks of driver.
+func (o *rawSet) getQueryArgs() (string, []interface{}, error) {
+	if o.err != nil {
+		return "", nil, o.err
+	}
+	var err error
+	query, args := o.query, o.args
+	if strings.Contains(query, "{{") {
+		ro := o.orm
+		if o.ctx != nil {
+			if ro, err = ro.ctxTenant(o.ctx); err != nil {
+				return "", nil, err
+			}
+		}
+		if query, err = ro.replaceTenantTables(query); err != nil {
+			return "", nil, err
+		}
+	}
+	if o.named != nil {
+		if query, args, err = replaceNamedParams(query, o.named); err != nil {
+			return "", nil, err
+		}
+	}
+	o.orm.alias.DbBaser.ReplaceMarks(&query)
+	return query, getFlatParams(nil, args, o.orm.alias.TZ), nil
+}
+
+// replace the named params like :name in query by ?, a slice param is expanded to ?, ?, ?.
+// the :name in quoted strings and identifiers, and the :: cast of postgres are kept.
+func replaceNamedParams(query string, named Params) (string, []interface{}, error) {
+	var (
+		buf   strings.Builder
+		args  []interface{}
+		quote byte
+	)
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		if quote != 0 {
+			if c == quote {
+				quote = 0
+			}
+			buf.WriteByte(c)
+			continue
+		}
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == ':' && i+1 < len(query) && query[i+1] == ':':
+			buf.WriteString("::")
+			i++
+			continue
+		case c == ':' && i+1 < len(query) && isNameStart(query[i+1]):
+			j := i + 1
+			for j < len(query) && isNamePart(query[j]) {
+				j++
+			}
+			name := query[i+1 : j]
+			value, ok := named[name]
+			if !ok {
+				return "", nil, fmt.Errorf("<RawSeter> missing named param `%s`", name)
+			}
+			marks, values, err := expandNamedParam(name, value)
+			if err != nil {
+				return "", nil, err
+			}
+			buf.WriteString(marks)
+			args = append(args, values...)
+			i = j - 1
+			continue
+		}
+		buf.WriteByte(c)
+	}
+	return buf.String(), args, nil
+}
+
+// get the marks and args of named param, slice and array except []byte are expanded.
+func expandNamedParam(name string, value interface{}) (string, []interface{}, error) {
+	val := reflect.ValueOf(value)
+	switch val.Kind() {
+	case reflect.Slice:
+		if val.Type().Elem().Kind() == reflect.Uint8 {
+			break
+		}
+		fallthrough
+	case reflect.Array:
+		if val.Len() == 0 {
+			return "", nil, fmt.Errorf("<RawSeter> named param `%s` is empty", name)
+		}
+		marks := make([]string, val.Len())
+		args := make([]interface{}, val.Len())
+		for i := range marks {
+			marks[i] = "?"
+			args[i] = val.Index(i).Interface()
+		}
+		return strings.Join(marks, ", "), args, nil
+	case reflect.Ptr:
+		// nil ptr field is NULL
+		value = nil
+		if !val.IsNil() {
+			value = val.Elem().Interface()
+		}
+	}
+	return "?", []interface{}{value}, nil
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isNamePart(c byte) bool {
+	return isNameStart(c) || '0' <= c && c <= '9'
+}
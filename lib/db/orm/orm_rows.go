@@ -0,0 +1,150 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// rows cursor of QuerySeter, scan one row at a time.
+type modelRows struct {
+	d      *dbBase
+	rs     *sql.Rows
+	mi     *modelInfo
+	tCols  []string
+	tables *dbTables
+	refs   []interface{}
+	tz     *time.Location
+	ctx    context.Context
+}
+
+var _ Rows = new(modelRows)
+
+// prepare the next row for Scan.
+func (r *modelRows) Next() bool {
+	return r.rs.Next()
+}
+
+// scan current row into the model struct md, related models are set if RelatedSel.
+// md is reset before scanning, so it can be reused for every row.
+func (r *modelRows) Scan(md interface{}) error {
+	val := reflect.ValueOf(md)
+	ind := reflect.Indirect(val)
+	if val.Kind() != reflect.Ptr || getFullName(ind.Type()) != r.mi.fullName {
+		panic(fmt.Errorf("wrong object type `%s` for rows scan, need *%s", val.Type(), r.mi.fullName))
+	}
+
+	if err := r.rs.Scan(r.refs...); err != nil {
+		return err
+	}
+	ind.Set(reflect.Zero(ind.Type()))
+	r.d.setRowValues(r.mi, &ind, r.tCols, r.tables, r.refs, r.tz)
+	return callHook(r.ctx, hookAfterRead, md)
+}
+
+// return the error met during iteration.
+func (r *modelRows) Err() error {
+	return r.rs.Err()
+}
+
+// close the rows, it's safe to close more than once.
+func (r *modelRows) Close() error {
+	return r.rs.Close()
+}
+
+// query rows and return a cursor over them.
+func (d *dbBase) ReadRows(q dbQuerier, qs *querySet, mi *modelInfo, cond *Condition, tz *time.Location, cols []string) (Rows, error) {
+	query, args, tCols, tables, colsNum, err := d.readBatchQuery(qs, mi, cond, tz, cols)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := qs.ctx
+	if !qs.forContext || ctx == nil {
+		ctx = context.Background()
+	}
+	rs, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make([]interface{}, colsNum)
+	for i := range refs {
+		var ref interface{}
+		refs[i] = &ref
+	}
+
+	return &modelRows{
+		d:      d,
+		rs:     rs,
+		mi:     mi,
+		tCols:  tCols,
+		tables: tables,
+		refs:   refs,
+		tz:     tz,
+		ctx:    ctx,
+	}, nil
+}
+
+// query rows with context and return a cursor over them.
+// the rows are not limited by DefaultRowsLimit, only by Limit.
+// close the Rows after use.
+func (o querySet) Rows(ctx context.Context, cols ...string) (Rows, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+	if o.mi.shard != nil {
+		qs, err := o.oneShardQs()
+		if err != nil {
+			return nil, err
+		}
+		return qs.Rows(ctx, cols...)
+	}
+	o.ctx = ctx
+	o.forContext = true
+	if o.limit == 0 {
+		// rows are streamed without DefaultRowsLimit
+		o.limit = -1
+	}
+	return o.orm.alias.DbBaser.ReadRows(o.readQuerier(), &o, o.mi, o.scopedCond(), o.orm.alias.TZ, cols)
+}
+
+// query rows one at a time and call fn with the row, which is a ptr of model struct.
+// the model struct is reused for every row, fn should copy the values it keeps.
+// rows are closed when fn returns error, which stops the iteration and is returned.
+func (o *querySet) Iterate(ctx context.Context, fn func(row interface{}) error, cols ...string) (int64, error) {
+	rows, err := o.Rows(ctx, cols...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	md := reflect.New(o.mi.addrField.Elem().Type()).Interface()
+	var cnt int64
+	for rows.Next() {
+		if err := rows.Scan(md); err != nil {
+			return cnt, err
+		}
+		cnt++
+		if err := fn(md); err != nil {
+			return cnt, err
+		}
+	}
+	return cnt, rows.Err()
+}
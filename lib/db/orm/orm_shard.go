@@ -0,0 +1,493 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ShardStrategy choose the shard of a shard key value, n is the number of shards.
+type ShardStrategy interface {
+	Shard(value interface{}, n int) (int, error)
+}
+
+// ShardFunc is a ShardStrategy of lookup function.
+type ShardFunc func(value interface{}, n int) (int, error)
+
+// Shard call f(value, n).
+func (f ShardFunc) Shard(value interface{}, n int) (int, error) {
+	return f(value, n)
+}
+
+// ShardByHash return the strategy of hash mod n.
+// integer values are used as the hash, others are hashed by fnv of the string value.
+func ShardByHash() ShardStrategy {
+	return ShardFunc(func(value interface{}, n int) (int, error) {
+		val := reflect.Indirect(reflect.ValueOf(value))
+		switch val.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			v := val.Int() % int64(n)
+			if v < 0 {
+				v = -v
+			}
+			return int(v), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return int(val.Uint() % uint64(n)), nil
+		case reflect.Invalid:
+			return 0, fmt.Errorf("<ShardByHash> shard key value cannot be nil")
+		}
+		h := fnv.New32a()
+		h.Write([]byte(ToStr(val.Interface())))
+		return int(h.Sum32() % uint32(n)), nil
+	})
+}
+
+// ShardByRange return the strategy of integer ranges.
+// bounds are the ascending exclusive upper bounds of the shards except the last one,
+// ShardByRange(1000, 2000) puts [min, 1000) to shard 0, [1000, 2000) to shard 1 and the others to shard 2.
+func ShardByRange(bounds ...int64) ShardStrategy {
+	return ShardFunc(func(value interface{}, n int) (int, error) {
+		if len(bounds) >= n {
+			return 0, fmt.Errorf("<ShardByRange> %d shards need at most %d bounds, got %d", n, n-1, len(bounds))
+		}
+		v, err := StrTo(ToStr(reflect.Indirect(reflect.ValueOf(value)).Interface())).Int64()
+		if err != nil {
+			return 0, fmt.Errorf("<ShardByRange> wrong shard key value `%v`, %s", value, err.Error())
+		}
+		return sort.Search(len(bounds), func(i int) bool { return v < bounds[i] }), nil
+	})
+}
+
+// Shard is a physical table of sharded model, the table name is model table name with suffix.
+type Shard struct {
+	Alias  string // registered database alias name
+	Suffix string // suffix of table name, like _0
+}
+
+// ShardRule split the rows of model into shards by the value of Key field.
+// Ormer Read, Insert, Update and Delete use the shard of the model Key field,
+// Update cannot change the Key, move the row to another shard by Delete and Insert.
+// QuerySeter uses the shard of the exact condition of Key, see QuerySeter.AllShards.
+// syncdb creates the shard tables on their aliases.
+// model declares it by method:
+// 	func (o *Order) TableShard() *orm.ShardRule {
+// 		return &orm.ShardRule{Key: "UserID", Strategy: orm.ShardByHash(), Shards: []orm.Shard{{"db0", "_0"}, {"db1", "_1"}}}
+// 	}
+type ShardRule struct {
+	Key      string
+	Strategy ShardStrategy
+	Shards   []Shard
+
+	fi     *fieldInfo
+	tables []*modelInfo // model info of every shard table
+}
+
+// check the rule of model mi and create the model info of shard tables.
+func (r *ShardRule) init(mi *modelInfo) error {
+	if r.Strategy == nil || len(r.Shards) == 0 {
+		return fmt.Errorf("shard rule needs Strategy and Shards")
+	}
+	fi, ok := mi.fields.GetByAny(r.Key)
+	if !ok || !fi.dbcol || fi.rel {
+		return fmt.Errorf("wrong shard key `%s`, it must be a column field", r.Key)
+	}
+	r.fi = fi
+	r.tables = make([]*modelInfo, 0, len(r.Shards))
+	for _, shard := range r.Shards {
+		smi := *mi
+		smi.table = mi.table + shard.Suffix
+		smi.shard = nil
+		r.tables = append(r.tables, &smi)
+	}
+	return nil
+}
+
+// get the shard index of shard key value.
+func (r *ShardRule) index(value interface{}) (int, error) {
+	i, err := r.Strategy.Shard(value, len(r.Shards))
+	if err != nil {
+		return 0, err
+	}
+	if i < 0 || i >= len(r.Shards) {
+		return 0, fmt.Errorf("<Ormer> shard index %d of value `%v` out of range", i, value)
+	}
+	return i, nil
+}
+
+// get the Ormer and model info of shard i of sharded model mi.
+// the Ormer of transaction can only use the shards on the same alias.
+func (o *orm) onShard(mi *modelInfo, i int) (*orm, *modelInfo, error) {
+	shard := mi.shard.Shards[i]
+	smi := mi.shard.tables[i]
+	if o.alias.Name == shard.Alias {
+		return o, smi, nil
+	}
+	if o.isTx {
+		return nil, nil, fmt.Errorf("<Ormer> transaction of alias `%s` cannot use shard on alias `%s`", o.alias.Name, shard.Alias)
+	}
+	al, ok := dataBaseCache.get(shard.Alias)
+	if !ok {
+		return nil, nil, fmt.Errorf("<Ormer> unknown db alias name `%s` of shard", shard.Alias)
+	}
+	n := *o
+	n.alias = al
+	n.db = wrapDB(al, al.DB)
+	return &n, smi, nil
+}
+
+// route model ind to its shard by the shard key field.
+// it returns o and mi for not sharded model.
+func (o *orm) shardOf(mi *modelInfo, ind reflect.Value) (*orm, *modelInfo, error) {
+	if mi.shard == nil {
+		return o, mi, nil
+	}
+	i, err := mi.shard.index(ind.FieldByIndex(mi.shard.fi.fieldIndex).Interface())
+	if err != nil {
+		return nil, nil, err
+	}
+	return o.onShard(mi, i)
+}
+
+// models of sind on the same shard.
+type shardGroup struct {
+	orm  *orm
+	mi   *modelInfo
+	rows reflect.Value
+}
+
+// group the models of sind by shard, groups are in the order of shards.
+func (o *orm) shardGroups(mi *modelInfo, sind reflect.Value) ([]*shardGroup, error) {
+	groups := make([]*shardGroup, len(mi.shard.Shards))
+	for i := 0; i < sind.Len(); i++ {
+		ind := reflect.Indirect(sind.Index(i))
+		n, err := mi.shard.index(ind.FieldByIndex(mi.shard.fi.fieldIndex).Interface())
+		if err != nil {
+			return nil, err
+		}
+		if groups[n] == nil {
+			so, smi, err := o.onShard(mi, n)
+			if err != nil {
+				return nil, err
+			}
+			groups[n] = &shardGroup{orm: so, mi: smi, rows: reflect.MakeSlice(reflect.SliceOf(sind.Type().Elem()), 0, 0)}
+		}
+		groups[n].rows = reflect.Append(groups[n].rows, sind.Index(i))
+	}
+	res := make([]*shardGroup, 0, len(groups))
+	for _, g := range groups {
+		if g != nil {
+			res = append(res, g)
+		}
+	}
+	return res, nil
+}
+
+// query all shards of sharded model.
+// by default a query of sharded model needs the exact condition of shard key, like Filter("UserID", 1).
+// with AllShards the query fans out to every shard: counts and affected rows are summed,
+// All merges the rows by OrderBy and applies Limit and Offset on the merged rows,
+// Values, ValuesList and ValuesFlat concatenate the rows in the order of shards.
+func (o querySet) AllShards() QuerySeter {
+	o.allShards = true
+	return &o
+}
+
+// check the updated fields or columns of sharded model mi don't contain the shard key,
+// the row would stay in the shard of the old key value.
+func (r *ShardRule) checkUpdate(mi *modelInfo, names []string) error {
+	for _, name := range names {
+		if fi, ok := mi.fields.GetByAny(name); ok && fi == r.fi {
+			return ErrShardKeyUpdate
+		}
+	}
+	return nil
+}
+
+// convert the condition arg of shard key to the value in field type,
+// so that it is routed like the field value of model.
+func (r *ShardRule) keyValue(arg interface{}, base dbBaser, tz *time.Location) (interface{}, error) {
+	params := getFlatParams(r.fi, []interface{}{arg}, tz)
+	if len(params) != 1 {
+		return nil, fmt.Errorf("<QuerySeter> wrong shard key value `%v`", arg)
+	}
+	d := &dbBase{ins: base}
+	return d.convertValueFromDB(r.fi, params[0], tz)
+}
+
+// get the shard key value from the exact condition of shard key.
+func (o *querySet) shardKeyValue() (interface{}, bool, error) {
+	if o.cond == nil {
+		return nil, false, nil
+	}
+	var value interface{}
+	var found bool
+	for _, p := range o.cond.params {
+		if p.isOr {
+			return nil, false, nil
+		}
+		if p.isCond || p.isNot || p.isRaw || len(p.args) != 1 {
+			continue
+		}
+		exprs := p.exprs
+		if n := len(exprs) - 1; n > 0 && (exprs[n] == "exact" || exprs[n] == "eq") {
+			exprs = exprs[:n]
+		}
+		if len(exprs) != 1 {
+			continue
+		}
+		if fi, ok := o.mi.fields.GetByAny(exprs[0]); ok && fi == o.mi.shard.fi {
+			v, err := o.mi.shard.keyValue(p.args[0], o.orm.alias.DbBaser, o.orm.alias.TZ)
+			if err != nil {
+				return nil, false, err
+			}
+			value, found = v, true
+		}
+	}
+	return value, found, nil
+}
+
+// get the querySets of the shards which the query runs on.
+func (o *querySet) shardQs() ([]*querySet, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+	value, ok, err := o.shardKeyValue()
+	if err != nil {
+		return nil, err
+	}
+	var indexes []int
+	if ok {
+		i, err := o.mi.shard.index(value)
+		if err != nil {
+			return nil, err
+		}
+		indexes = []int{i}
+	} else if o.allShards {
+		for i := range o.mi.shard.Shards {
+			indexes = append(indexes, i)
+		}
+	} else {
+		return nil, ErrNoShardKey
+	}
+
+	qss := make([]*querySet, 0, len(indexes))
+	for _, i := range indexes {
+		so, smi, err := o.orm.onShard(o.mi, i)
+		if err != nil {
+			return nil, err
+		}
+		qs := *o
+		qs.orm = so
+		qs.mi = smi
+		qss = append(qss, &qs)
+	}
+	return qss, nil
+}
+
+// get the querySet of the only shard which the query runs on.
+func (o *querySet) oneShardQs() (*querySet, error) {
+	qss, err := o.shardQs()
+	if err != nil {
+		return nil, err
+	}
+	if len(qss) > 1 {
+		return nil, ErrShardFanOut
+	}
+	return qss[0], nil
+}
+
+// run fn on the shards and sum the numbers.
+func (o *querySet) shardSum(fn func(qs *querySet) (int64, error)) (int64, error) {
+	qss, err := o.shardQs()
+	if err != nil {
+		return 0, err
+	}
+	var cnt int64
+	for _, qs := range qss {
+		num, err := fn(qs)
+		cnt += num
+		if err != nil {
+			return cnt, err
+		}
+	}
+	return cnt, nil
+}
+
+// read the results of the shards by fn and concatenate them to the slice results.
+func (o *querySet) shardValues(results interface{}, fn func(qs *querySet, results interface{}) (int64, error)) (int64, error) {
+	qss, err := o.shardQs()
+	if err != nil {
+		return 0, err
+	}
+	if len(qss) > 1 && len(o.aggregates) > 0 {
+		return 0, ErrShardFanOut
+	}
+	ind := reflect.Indirect(reflect.ValueOf(results))
+	merged := reflect.MakeSlice(ind.Type(), 0, 0)
+	var cnt int64
+	for _, qs := range qss {
+		part := reflect.New(ind.Type())
+		num, err := fn(qs, part.Interface())
+		if err != nil {
+			return cnt, err
+		}
+		cnt += num
+		merged = reflect.AppendSlice(merged, part.Elem())
+	}
+	ind.Set(merged)
+	return cnt, nil
+}
+
+// query the rows of the shards, the rows are merged by the orders.
+func (o *querySet) shardAll(container interface{}, cols ...string) (int64, error) {
+	qss, err := o.shardQs()
+	if err != nil {
+		return 0, err
+	}
+	if len(qss) == 1 {
+		return qss[0].All(container, cols...)
+	}
+
+	ind := reflect.Indirect(reflect.ValueOf(container))
+	if ind.Kind() != reflect.Slice {
+		return 0, ErrShardFanOut
+	}
+	var orders []keysetOrder
+	for _, expr := range o.orders {
+		name := strings.TrimPrefix(expr, "-")
+		fi, ok := o.mi.fields.GetByAny(name)
+		if !ok || !fi.dbcol || strings.Contains(name, ExprSep) {
+			return 0, fmt.Errorf("<QuerySeter.AllShards> order `%s` must be a column of model `%s`", expr, o.mi.fullName)
+		}
+		orders = append(orders, keysetOrder{fi: fi, desc: expr != name})
+	}
+
+	// every shard returns the first offset + limit rows
+	limit := o.limit
+	if limit == 0 {
+		limit = int64(DefaultRowsLimit)
+	}
+	_, err = o.shardValues(container, func(qs *querySet, part interface{}) (int64, error) {
+		qs.offset = 0
+		qs.limit = -1
+		if limit > 0 {
+			qs.limit = o.offset + limit
+		}
+		return qs.All(part, cols...)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if len(orders) > 0 {
+		sort.SliceStable(ind.Interface(), func(i, j int) bool {
+			a, b := reflect.Indirect(ind.Index(i)), reflect.Indirect(ind.Index(j))
+			for _, order := range orders {
+				c := compareShardValue(a.FieldByIndex(order.fi.fieldIndex), b.FieldByIndex(order.fi.fieldIndex))
+				if c != 0 {
+					return c < 0 != order.desc
+				}
+			}
+			return false
+		})
+	}
+	start, end := o.offset, int64(ind.Len())
+	if start > end {
+		start = end
+	}
+	if limit > 0 && start+limit < end {
+		end = start + limit
+	}
+	ind.Set(ind.Slice(int(start), int(end)))
+	return end - start, nil
+}
+
+// query one row of the shards, it fails with ErrMultiRows if more than one shard has the row.
+func (o *querySet) shardOne(container interface{}, cols ...string) error {
+	qss, err := o.shardQs()
+	if err != nil {
+		return err
+	}
+	ind := reflect.Indirect(reflect.ValueOf(container))
+	found := false
+	for _, qs := range qss {
+		row := reflect.New(ind.Type())
+		err := qs.One(row.Interface(), cols...)
+		if err == ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if found {
+			return ErrMultiRows
+		}
+		found = true
+		ind.Set(row.Elem())
+	}
+	if !found {
+		return ErrNoRows
+	}
+	return nil
+}
+
+// compare the values of order field, it returns -1, 0 or 1.
+func compareShardValue(a, b reflect.Value) int {
+	if a.Kind() == reflect.Ptr {
+		if a.IsNil() || b.IsNil() {
+			switch {
+			case a.IsNil() && b.IsNil():
+				return 0
+			case a.IsNil():
+				return -1
+			}
+			return 1
+		}
+		a, b = a.Elem(), b.Elem()
+	}
+	var less, greater bool
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		less, greater = a.Int() < b.Int(), a.Int() > b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		less, greater = a.Uint() < b.Uint(), a.Uint() > b.Uint()
+	case reflect.Float32, reflect.Float64:
+		less, greater = a.Float() < b.Float(), a.Float() > b.Float()
+	case reflect.Bool:
+		less, greater = !a.Bool() && b.Bool(), a.Bool() && !b.Bool()
+	case reflect.String:
+		less, greater = a.String() < b.String(), a.String() > b.String()
+	default:
+		if ta, ok := a.Interface().(time.Time); ok {
+			tb := b.Interface().(time.Time)
+			less, greater = ta.Before(tb), ta.After(tb)
+		} else {
+			sa, sb := ToStr(a.Interface()), ToStr(b.Interface())
+			less, greater = sa < sb, sa > sb
+		}
+	}
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}
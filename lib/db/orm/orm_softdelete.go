@@ -0,0 +1,170 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"context"
+	"reflect"
+	"time"
+)
+
+// SoftDeleteMode choose which rows of the models with soft_delete field are queried.
+// it can be passed to LoadRelated as an extra arg.
+type SoftDeleteMode int
+
+// define soft delete modes
+const (
+	// exclude the deleted rows, the default mode
+	SoftDeleteScoped SoftDeleteMode = iota
+	// include the deleted rows, delete really removes the rows
+	SoftDeleteUnscoped
+	// only the deleted rows
+	SoftDeleteOnly
+)
+
+// soft delete field of a related model, joined by expr.
+type softDeleteRel struct {
+	fi   *fieldInfo
+	expr string
+}
+
+// add the soft delete condition of field fi to cond.
+func softDeleteCond(cond *Condition, fi *fieldInfo, expr string, deleted bool) *Condition {
+	if fi.fieldType == TypeBooleanField {
+		return cond.And(expr, deleted)
+	}
+	return cond.And(expr+ExprSep+"isnull", !deleted)
+}
+
+// include the deleted rows.
+func (o querySet) Unscoped() QuerySeter {
+	o.softDelete = SoftDeleteUnscoped
+	return &o
+}
+
+// only the deleted rows.
+func (o querySet) OnlyDeleted() QuerySeter {
+	o.softDelete = SoftDeleteOnly
+	return &o
+}
+
+// restore the deleted rows matching the condition.
+func (o querySet) Restore() (int64, error) {
+	fi := o.mi.fields.softDelete
+	if fi == nil {
+		return 0, ErrNoSoftDelete
+	}
+	o.softDelete = SoftDeleteOnly
+	if o.mi.shard != nil {
+		return o.shardSum(func(qs *querySet) (int64, error) { return qs.Restore() })
+	}
+	return o.orm.alias.DbBaser.UpdateBatch(o.dbQuerier(), &o, o.mi, o.scopedCond(), Params{fi.column: o.softDeleteValue(false)}, o.orm.alias.TZ)
+}
+
+// exclude the deleted rows of the through model of m2m field fi.
+func (o *querySet) addThroughSoftDelete(fi *fieldInfo) {
+	if sfi := fi.relThroughModelInfo.fields.softDelete; sfi != nil {
+		o.softDeleteRels = append(o.softDeleteRels, softDeleteRel{sfi, fi.reverseFieldInfoM2M.column + ExprSep + sfi.name})
+	}
+}
+
+// get the condition of querySet limited by the soft delete mode.
+func (o *querySet) scopedCond() *Condition {
+	fi := o.mi.fields.softDelete
+	if o.softDelete == SoftDeleteUnscoped || fi == nil && len(o.softDeleteRels) == 0 {
+		return o.cond
+	}
+
+	cond := NewCondition()
+	if o.cond != nil && !o.cond.IsEmpty() {
+		cond = cond.AndCond(o.cond)
+	}
+	if fi != nil {
+		cond = softDeleteCond(cond, fi, fi.name, o.softDelete == SoftDeleteOnly)
+	}
+	// deleted related rows are always excluded
+	for _, rel := range o.softDeleteRels {
+		cond = softDeleteCond(cond, rel.fi, rel.expr, false)
+	}
+	return cond
+}
+
+// get the value of soft delete field for deleted or restored rows.
+func (o *querySet) softDeleteValue(deleted bool) interface{} {
+	if o.mi.fields.softDelete.fieldType == TypeBooleanField {
+		return deleted
+	}
+	if !deleted {
+		return nil
+	}
+	t := time.Now()
+	o.orm.alias.DbBaser.TimeToDB(&t, o.orm.alias.TZ)
+	return t
+}
+
+// soft delete model whose conditions are read from cols, default is pk.
+// the soft delete field of model is set after deleted.
+func (o *orm) softDelete(ctx context.Context, mi *modelInfo, ind reflect.Value, cols []string) (int64, error) {
+	cond := NewCondition()
+	if len(cols) == 0 {
+		pkColumns, pkValues, ok := getExistPks(mi, ind)
+		if !ok {
+			return 0, ErrMissPK
+		}
+		for i, column := range pkColumns {
+			cond = cond.And(column, pkValues[i])
+		}
+	}
+	for _, col := range cols {
+		fi, ok := mi.fields.GetByAny(col)
+		if !ok || !fi.dbcol {
+			return 0, ErrArgs
+		}
+		cond = cond.And(fi.column, ind.FieldByIndex(fi.fieldIndex).Interface())
+	}
+
+	qs := newQuerySet(o, mi).(*querySet)
+	qs.cond = cond
+	qs.ctx = ctx
+	qs.forContext = true
+
+	fi := mi.fields.softDelete
+	value := qs.softDeleteValue(true)
+	num, err := o.alias.DbBaser.UpdateBatch(qs.dbQuerier(), qs, mi, qs.scopedCond(), Params{fi.column: value}, o.alias.TZ)
+	if err != nil || num == 0 {
+		return num, err
+	}
+
+	field := ind.FieldByIndex(fi.fieldIndex)
+	switch v := value.(type) {
+	case bool:
+		if fi.isFielder {
+			field.Addr().Interface().(Fielder).SetRaw(v)
+		} else {
+			field.SetBool(v)
+		}
+	case time.Time:
+		v = v.In(DefaultTimeLoc)
+		switch {
+		case fi.isFielder:
+			field.Addr().Interface().(Fielder).SetRaw(v)
+		case field.Kind() == reflect.Ptr:
+			field.Set(reflect.ValueOf(&v))
+		default:
+			field.Set(reflect.ValueOf(v))
+		}
+	}
+	return num, nil
+}
@@ -0,0 +1,255 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/gopherchai/contrib/lib/metadata"
+)
+
+// TenantResolver resolves the physical table of a tenant model.
+// a model is a tenant model if it has the method TableTenant returning the resolver:
+//	func (o *Order) TableTenant() orm.TenantResolver {
+//		return orm.TenantSuffix("_")
+//	}
+// the tenant is read from the metadata.Tenant of context, by the WithCtx methods of Ormer,
+// QuerySeter.WithCtx, RawSeter.WithCtx, or bound to Ormer by WithTenant.
+// the tenant models without tenant return ErrNoTenant instead of using the registered table,
+// and an Ormer or transaction used by one tenant returns ErrCrossTenant for another tenant.
+// the queries of other models joining tenant models by Filter or RelatedSel use the tables of the tenant too,
+// they panic with ErrNoTenant without tenant.
+// the tables of tenants are not created by syncdb.
+type TenantResolver interface {
+	// physical table of table for tenant, schema.table is quoted as a schema qualified name.
+	TenantTable(tenant, table string) string
+}
+
+// TenantFunc is an adapter to use a function as TenantResolver.
+type TenantFunc func(tenant, table string) string
+
+// TenantTable calls f(tenant, table).
+func (f TenantFunc) TenantTable(tenant, table string) string {
+	return f(tenant, table)
+}
+
+// TenantSuffix resolves the table of tenant to table + sep + tenant, like order_t1.
+func TenantSuffix(sep string) TenantResolver {
+	return TenantFunc(func(tenant, table string) string {
+		return table + sep + tenant
+	})
+}
+
+// TenantPrefix resolves the table of tenant to tenant + sep + table, like t1_order.
+func TenantPrefix(sep string) TenantResolver {
+	return TenantFunc(func(tenant, table string) string {
+		return tenant + sep + table
+	})
+}
+
+// TenantSchema resolves the table of tenant to the table in schema of tenant, like "t1"."order",
+// it's the postgres schema or mysql database of tenant, which must be created before.
+// the table names are qualified instead of setting search_path,
+// which is kept by the pooled connection and would leak to the other tenants.
+func TenantSchema() TenantResolver {
+	return TenantFunc(func(tenant, table string) string {
+		return tenant + "." + table
+	})
+}
+
+// the tenant is a part of table name, only letters, digits and underscore are allowed.
+var tenantRegexp = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// TenantFromContext return the tenant of metadata.Tenant in ctx.
+// the tenant must be set by the auth middleware from the authenticated identity,
+// it is not propagated or extracted from the rpc metadata.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	v := metadata.Value(ctx, metadata.Tenant)
+	if v == nil {
+		return "", false
+	}
+	tenant := ToStr(v)
+	return tenant, tenant != ""
+}
+
+// get tenant resolver from method.
+func getTableTenant(val reflect.Value) TenantResolver {
+	fun := val.MethodByName("TableTenant")
+	if fun.IsValid() {
+		vals := fun.Call([]reflect.Value{})
+		if len(vals) > 0 && vals[0].CanInterface() {
+			if d, ok := vals[0].Interface().(TenantResolver); ok {
+				return d
+			}
+		}
+	}
+	return nil
+}
+
+// model info of tenant tables, by the registered model info and the physical table.
+var tenantModels sync.Map
+
+type tenantModelKey struct {
+	mi    *modelInfo
+	table string
+}
+
+// get the model info of tenant table of tenant model mi, Q is the table quote of database.
+// the model info is a copy of mi with the physical table, which is cached.
+func getTenantModel(mi *modelInfo, tenant, Q string) (*modelInfo, error) {
+	if tenant == "" {
+		return nil, ErrNoTenant
+	}
+	if !tenantRegexp.MatchString(tenant) {
+		return nil, fmt.Errorf("<Ormer> wrong tenant `%s`, only letters, digits and underscore are allowed", tenant)
+	}
+	// quote schema.table like "schema"."table", the table is quoted again when used
+	table := strings.Replace(mi.tenant.TenantTable(tenant, mi.table), ".", Q+"."+Q, 1)
+	key := tenantModelKey{mi, table}
+	if tmi, ok := tenantModels.Load(key); ok {
+		return tmi.(*modelInfo), nil
+	}
+	tmi := *mi
+	tmi.table = table
+	tmi.tenant = nil
+	tmi.tenantOf = mi
+	tmi.tenantID = tenant
+	v, _ := tenantModels.LoadOrStore(key, &tmi)
+	return v.(*modelInfo), nil
+}
+
+// WithTenant return an Ormer bound to the tenant of ctx, its QueryTable, Read, Insert and Raw
+// use the tables of the tenant.
+func (o *orm) WithTenant(ctx context.Context) Ormer {
+	n := *o
+	n.tenant, _ = TenantFromContext(ctx)
+	return &n
+}
+
+// get the tenant used by o.
+func (o *orm) currentTenant() string {
+	if o.tenant != "" {
+		return o.tenant
+	}
+	return o.txTenant
+}
+
+// return o using the tenant of ctx.
+// the tenant of ctx must be the same as the tenant of o or its transaction,
+// a transaction is pinned to the first tenant used in it.
+func (o *orm) ctxTenant(ctx context.Context) (*orm, error) {
+	tenant, ok := TenantFromContext(ctx)
+	if !ok {
+		return o, nil
+	}
+	if current := o.currentTenant(); current != "" {
+		if current != tenant {
+			return nil, ErrCrossTenant
+		}
+		return o, nil
+	}
+	if o.isTx {
+		o.txTenant = tenant
+		return o, nil
+	}
+	n := *o
+	n.tenant = tenant
+	return &n, nil
+}
+
+// get the tenant model info of mi for the tenant of o.
+func (o *orm) tenantModel(mi *modelInfo) (*modelInfo, error) {
+	return getTenantModel(mi, o.currentTenant(), o.alias.DbBaser.TableQuote())
+}
+
+// route model ind to the table of tenant in ctx or its shard.
+// it returns o and mi for the model neither tenant nor sharded.
+func (o *orm) routeOf(ctx context.Context, mi *modelInfo, ind reflect.Value) (*orm, *modelInfo, error) {
+	if mi.tenant == nil {
+		return o.shardOf(mi, ind)
+	}
+	o, err := o.ctxTenant(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	tmi, err := o.tenantModel(mi)
+	if err != nil {
+		return nil, nil, err
+	}
+	return o, tmi, nil
+}
+
+// bind querySet to the tenant of ctx, the tenant is used by the tenant model and the joined tenant models.
+func (o *querySet) bindTenant(ctx context.Context) {
+	mi := o.mi
+	if mi.tenantOf != nil {
+		mi = mi.tenantOf
+	}
+	if o.err != nil && o.err != ErrNoTenant {
+		return
+	}
+	so, err := o.orm.ctxTenant(ctx)
+	if err != nil {
+		o.err = err
+		return
+	}
+	if mi.tenant == nil {
+		// the tenant is used by the joined tenant models
+		o.orm = so
+		return
+	}
+	tmi, err := so.tenantModel(mi)
+	if err != nil {
+		o.err = err
+		return
+	}
+	o.orm, o.mi, o.err = so, tmi, nil
+}
+
+// table placeholders of raw query, like {{user}}.
+var tenantTableRegexp = regexp.MustCompile(`\{\{(\w+)\}\}`)
+
+// replace the table placeholders of raw query by the quoted tables,
+// the tables of tenant models are the tables of the tenant of o.
+func (o *orm) replaceTenantTables(query string) (string, error) {
+	var err error
+	Q := o.alias.DbBaser.TableQuote()
+	query = tenantTableRegexp.ReplaceAllStringFunc(query, func(s string) string {
+		table := s[2 : len(s)-2]
+		mi, ok := modelCache.get(table)
+		if !ok {
+			err = fmt.Errorf("<RawSeter> unknown table `%s`", table)
+			return s
+		}
+		if mi.tenant != nil {
+			tmi, terr := o.tenantModel(mi)
+			if terr != nil {
+				err = terr
+				return s
+			}
+			table = tmi.table
+		}
+		return Q + table + Q
+	})
+	return query, err
+}
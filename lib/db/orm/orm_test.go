@@ -20,9 +20,12 @@ import (
 	"bytes"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"math"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -30,6 +33,9 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/gopherchai/contrib/lib/metadata"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 var _ = os.PathSeparator
@@ -197,6 +203,10 @@ func TestSyncDb(t *testing.T) {
 	RegisterModel(new(IntegerPk))
 	RegisterModel(new(UintPk))
 	RegisterModel(new(PtrPk))
+	RegisterModel(new(HookModel))
+	RegisterModel(new(VersionModel), new(UpsertModel))
+	RegisterModel(new(PostVote), new(Metric), new(JSONDoc), new(ShardOrder), new(SecretUser), new(TenantDoc), new(TenantNote))
+	RegisterModel(new(SoftTag), new(SoftArticle))
 
 	err := RunSyncdb("default", true, Debug)
 	throwFail(t, err)
@@ -221,6 +231,10 @@ func TestRegisterModels(t *testing.T) {
 	RegisterModel(new(IntegerPk))
 	RegisterModel(new(UintPk))
 	RegisterModel(new(PtrPk))
+	RegisterModel(new(HookModel))
+	RegisterModel(new(VersionModel), new(UpsertModel))
+	RegisterModel(new(PostVote), new(Metric), new(JSONDoc), new(ShardOrder), new(SecretUser), new(TenantDoc), new(TenantNote))
+	RegisterModel(new(SoftTag), new(SoftArticle))
 
 	BootStrap()
 
@@ -1805,6 +1819,44 @@ func TestRawValues(t *testing.T) {
 	}
 }
 
+func TestRawNamedParams(t *testing.T) {
+	Q := dDbBaser.TableQuote()
+
+	query := fmt.Sprintf("SELECT %suser_name%s FROM %suser%s WHERE %suser_name%s IN (:names) ORDER BY %sid%s", Q, Q, Q, Q, Q, Q, Q, Q)
+	var names []string
+	num, err := dORM.Raw(query).BindMap(Params{"names": []string{"slene", "astaxie"}}).QueryRows(&names)
+	throwFail(t, err)
+	throwFail(t, AssertIs(num, 2))
+	throwFail(t, AssertIs(names[0], "slene"))
+
+	filter := struct {
+		Status int
+		Name   string `orm:"column(name)"`
+	}{Status: 1, Name: "slene"}
+	query = fmt.Sprintf("SELECT %suser_name%s FROM %suser%s WHERE %sstatus%s = :status AND %suser_name%s = :name", Q, Q, Q, Q, Q, Q, Q, Q)
+	var name string
+	throwFail(t, dORM.Raw(query).BindStruct(&filter).QueryRow(&name))
+	throwFail(t, AssertIs(name, "slene"))
+
+	query = fmt.Sprintf("SELECT %suser_name%s FROM %suser%s WHERE %suser_name%s = :user_name", Q, Q, Q, Q, Q, Q)
+	name = ""
+	throwFail(t, dORM.Raw(query).BindStruct(&User{UserName: "astaxie"}).QueryRow(&name))
+	throwFail(t, AssertIs(name, "astaxie"))
+
+	_, err = dORM.Raw(query).BindMap(Params{}).Exec()
+	throwFail(t, AssertNot(err, nil))
+	_, err = dORM.Raw(query).BindMap(Params{"user_name": []string{}}).Exec()
+	throwFail(t, AssertNot(err, nil))
+
+	_, err = dORM.Raw(query).BindMap(Params{"user_name": "slene"}).Prepare()
+	throwFail(t, AssertNot(err, nil))
+
+	q, args, err := replaceNamedParams("SELECT ':a', a::text FROM t WHERE a = :a AND b IN (:b)", Params{"a": 1, "b": []string{"x", "y"}})
+	throwFail(t, err)
+	throwFail(t, AssertIs(q, "SELECT ':a', a::text FROM t WHERE a = ? AND b IN (?, ?)"))
+	throwFail(t, AssertIs(len(args), 3))
+}
+
 func TestRawPrepare(t *testing.T) {
 	switch {
 	case IsMysql || IsSqlite:
@@ -2070,211 +2122,1832 @@ func TestBeginTxWithContextCanceled(t *testing.T) {
 	throwFail(t, AssertIs(err, context.Canceled))
 }
 
-func TestReadOrCreate(t *testing.T) {
-	u := &User{
-		UserName: "Kyle",
-		Email:    "kylemcc@gmail.com",
-		Password: "other_pass",
-		Status:   7,
-		IsStaff:  false,
-		IsActive: true,
-	}
+func TestWithCtx(t *testing.T) {
+	ctx := context.Background()
 
-	created, pk, err := dORM.ReadOrCreate(u, "UserName")
+	tag := &Tag{Name: "test-with-ctx"}
+	id, err := dORM.InsertWithCtx(ctx, tag)
 	throwFail(t, err)
-	throwFail(t, AssertIs(created, true))
-	throwFail(t, AssertIs(u.ID, pk))
-	throwFail(t, AssertIs(u.UserName, "Kyle"))
-	throwFail(t, AssertIs(u.Email, "kylemcc@gmail.com"))
-	throwFail(t, AssertIs(u.Password, "other_pass"))
-	throwFail(t, AssertIs(u.Status, 7))
-	throwFail(t, AssertIs(u.IsStaff, false))
-	throwFail(t, AssertIs(u.IsActive, true))
-	throwFail(t, AssertIs(u.Created.In(DefaultTimeLoc), u.Created.In(DefaultTimeLoc), testDate))
-	throwFail(t, AssertIs(u.Updated.In(DefaultTimeLoc), u.Updated.In(DefaultTimeLoc), testDateTime))
+	throwFail(t, AssertIs(id > 0, true))
 
-	nu := &User{UserName: u.UserName, Email: "someotheremail@gmail.com"}
-	created, pk, err = dORM.ReadOrCreate(nu, "UserName")
+	tag2 := &Tag{ID: tag.ID}
+	err = dORM.ReadWithCtx(ctx, tag2)
 	throwFail(t, err)
-	throwFail(t, AssertIs(created, false))
-	throwFail(t, AssertIs(nu.ID, u.ID))
-	throwFail(t, AssertIs(pk, u.ID))
-	throwFail(t, AssertIs(nu.UserName, u.UserName))
-	throwFail(t, AssertIs(nu.Email, u.Email)) // should contain the value in the table, not the one specified above
-	throwFail(t, AssertIs(nu.Password, u.Password))
-	throwFail(t, AssertIs(nu.Status, u.Status))
-	throwFail(t, AssertIs(nu.IsStaff, u.IsStaff))
-	throwFail(t, AssertIs(nu.IsActive, u.IsActive))
+	throwFail(t, AssertIs(tag2.Name, "test-with-ctx"))
 
-	dORM.Delete(u)
-}
+	num, err := dORM.QueryTable("tag").WithCtx(ctx).Filter("name", "test-with-ctx").Count()
+	throwFail(t, err)
+	throwFail(t, AssertIs(num, 1))
 
-func TestInLine(t *testing.T) {
-	name := "inline"
-	email := "hello@go.com"
-	inline := NewInLine()
-	inline.Name = name
-	inline.Email = email
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
 
-	id, err := dORM.Insert(inline)
-	throwFail(t, err)
-	throwFail(t, AssertIs(id, 1))
+	err = dORM.ReadWithCtx(canceled, &Tag{ID: tag.ID})
+	throwFail(t, AssertIs(err, context.Canceled))
 
-	il := NewInLine()
-	il.ID = 1
-	err = dORM.Read(il)
-	throwFail(t, err)
+	var tags []*Tag
+	_, err = dORM.QueryTable("tag").WithCtx(canceled).All(&tags)
+	throwFail(t, AssertIs(err, context.Canceled))
 
-	throwFail(t, AssertIs(il.Name, name))
-	throwFail(t, AssertIs(il.Email, email))
-	throwFail(t, AssertIs(il.Created.In(DefaultTimeLoc), inline.Created.In(DefaultTimeLoc), testDate))
-	throwFail(t, AssertIs(il.Updated.In(DefaultTimeLoc), inline.Updated.In(DefaultTimeLoc), testDateTime))
-}
+	_, err = dORM.Raw("SELECT * FROM tag").WithCtx(canceled).QueryRows(&tags)
+	throwFail(t, AssertIs(err, context.Canceled))
 
-func TestInLineOneToOne(t *testing.T) {
-	name := "121"
-	email := "121@go.com"
-	inline := NewInLine()
-	inline.Name = name
-	inline.Email = email
+	_, err = dORM.UpdateWithCtx(canceled, tag)
+	throwFail(t, AssertIs(err, context.Canceled))
 
-	id, err := dORM.Insert(inline)
+	num, err = dORM.DeleteWithCtx(ctx, tag)
 	throwFail(t, err)
-	throwFail(t, AssertIs(id, 2))
+	throwFail(t, AssertIs(num, 1))
+}
 
-	note := "one2one"
-	il121 := NewInLineOneToOne()
-	il121.Note = note
-	il121.InLine = inline
-	_, err = dORM.Insert(il121)
-	throwFail(t, err)
-	throwFail(t, AssertIs(il121.ID, 1))
+func TestReplica(t *testing.T) {
+	if !IsSqlite {
+		return
+	}
 
-	il := NewInLineOneToOne()
-	err = dORM.QueryTable(il).Filter("Id", 1).RelatedSel().One(il)
+	// the replica is an empty database, so reads routed to it miss the tables
+	dir, err := ioutil.TempDir("", "orm_replica")
+	throwFailNow(t, err)
+	defer os.RemoveAll(dir)
 
-	throwFail(t, err)
-	throwFail(t, AssertIs(il.Note, note))
-	throwFail(t, AssertIs(il.InLine.ID, id))
-	throwFail(t, AssertIs(il.InLine.Name, name))
-	throwFail(t, AssertIs(il.InLine.Email, email))
+	err = RegisterDataBase("replica_test", DBARGS.Driver, DBARGS.Source)
+	throwFailNow(t, err)
+	err = RegisterReplica("replica_test", filepath.Join(dir, "replica.db"), 1)
+	throwFailNow(t, err)
+	throwFail(t, AssertNot(RegisterReplica("replica_not_exist", DBARGS.Source, 1), nil))
 
-	rinline := NewInLine()
-	err = dORM.QueryTable(rinline).Filter("InLineOneToOne__Id", 1).One(rinline)
+	o := NewOrm()
+	throwFailNow(t, o.Using("replica_test"))
 
-	throwFail(t, err)
-	throwFail(t, AssertIs(rinline.ID, id))
-	throwFail(t, AssertIs(rinline.Name, name))
-	throwFail(t, AssertIs(rinline.Email, email))
-}
+	tag := &Tag{Name: "test-replica"}
+	_, err = o.Insert(tag)
+	throwFailNow(t, err)
 
-func TestIntegerPk(t *testing.T) {
-	its := []IntegerPk{
-		{ID: math.MinInt64, Value: "-"},
-		{ID: 0, Value: "0"},
-		{ID: math.MaxInt64, Value: "+"},
-	}
+	_, err = o.QueryTable("tag").Filter("name", "test-replica").Count()
+	throwFail(t, AssertNot(err, nil))
+	throwFail(t, AssertNot(o.Read(&Tag{ID: tag.ID}), nil))
 
-	num, err := dORM.InsertMulti(len(its), its)
+	num, err := o.QueryTable("tag").Filter("name", "test-replica").ForcePrimary().Count()
 	throwFail(t, err)
-	throwFail(t, AssertIs(num, len(its)))
+	throwFail(t, AssertIs(num, 1))
+	throwFail(t, o.ForcePrimary().Read(&Tag{ID: tag.ID}))
 
-	for _, intPk := range its {
-		out := IntegerPk{ID: intPk.ID}
-		err = dORM.Read(&out)
-		throwFail(t, err)
-		throwFail(t, AssertIs(out.Value, intPk.Value))
+	// reads inside transaction stay on the primary
+	throwFailNow(t, o.Begin())
+	num, err = o.QueryTable("tag").Filter("name", "test-replica").Count()
+	throwFail(t, err)
+	throwFail(t, AssertIs(num, 1))
+	throwFail(t, o.Rollback())
+
+	// unhealthy replica falls back to the primary
+	al := getDbAlias("replica_test")
+	for _, r := range al.Replicas.all() {
+		r.setHealthy(false)
 	}
+	throwFail(t, o.Read(&Tag{ID: tag.ID}))
 
-	num, err = dORM.InsertMulti(1, []*IntegerPk{{
-		ID: 1, Value: "ok",
-	}})
+	throwFail(t, StopReplicaHealthCheck("replica_test"))
+	throwFail(t, AssertIs(al.Replicas.stop == nil, true))
+	throwFail(t, AssertNot(StopReplicaHealthCheck("replica_not_exist"), nil))
+
+	num, err = o.Delete(tag)
 	throwFail(t, err)
 	throwFail(t, AssertIs(num, 1))
 }
 
-func TestInsertAuto(t *testing.T) {
-	u := &User{
-		UserName: "autoPre",
-		Email:    "autoPre@gmail.com",
-	}
+func TestInterceptor(t *testing.T) {
+	err := RegisterDataBase("intercept_test", DBARGS.Driver, DBARGS.Source)
+	throwFailNow(t, err)
+	throwFail(t, AssertNot(AddInterceptor("intercept_not_exist"), nil))
 
-	id, err := dORM.Insert(u)
+	var (
+		order []string
+		infos []QueryInfo
+	)
+	// the ormer created before the interceptors are added is intercepted too
+	o := NewOrm()
+	throwFailNow(t, o.Using("intercept_test"))
+
+	type ctxKey struct{}
+	var values []interface{}
+	errAbort := errors.New("abort")
+	err = AddInterceptor("intercept_test",
+		func(ctx context.Context, info *QueryInfo, next QueryHandler) error {
+			order = append(order, "first")
+			infos = append(infos, *info)
+			values = append(values, ctx.Value(ctxKey{}))
+			return next(ctx, info)
+		},
+		func(ctx context.Context, info *QueryInfo, next QueryHandler) error {
+			order = append(order, "second")
+			if info.Query == "abort" {
+				return errAbort
+			}
+			return next(ctx, info)
+		},
+	)
+	throwFailNow(t, err)
+
+	num, err := o.QueryTable("user").Filter("user_name", "slene").Count()
 	throwFail(t, err)
+	throwFail(t, AssertIs(num, 1))
+	throwFailNow(t, AssertIs(len(infos), 1))
+	throwFail(t, AssertIs(infos[0].Alias, "intercept_test"))
+	throwFail(t, AssertIs(infos[0].Operation, "db.QueryRow"))
+	throwFail(t, AssertIs(infos[0].Args[0], "slene"))
+	throwFail(t, AssertIs(strings.Join(order, ","), "first,second"))
+
+	_, err = o.Raw("abort").Exec()
+	throwFail(t, AssertIs(err, errAbort))
+
+	infos = nil
+	throwFailNow(t, o.Begin())
+	throwFail(t, o.Rollback())
+	throwFailNow(t, AssertIs(len(infos), 2))
+	throwFail(t, AssertIs(infos[0].Operation, "db.BeginTx"))
+	throwFail(t, AssertIs(infos[1].Operation, "tx.Rollback"))
+
+	// the prepared statement is intercepted with the context it is prepared with
+	infos, values = nil, nil
+	ctx := context.WithValue(context.Background(), ctxKey{}, "prepared")
+	pre, err := o.Raw("SELECT 1").WithCtx(ctx).Prepare()
+	throwFailNow(t, err)
+	throwFail(t, pre.Close())
+	throwFailNow(t, AssertIs(len(infos), 2))
+	throwFail(t, AssertIs(infos[0].Operation, "db.Prepare"))
+	throwFail(t, AssertIs(infos[1].Operation, "st.Close"))
+	throwFail(t, AssertIs(values[0], "prepared"))
+	throwFail(t, AssertIs(values[1], "prepared"))
+}
 
-	id += 100
-	su := &User{
-		ID:       int(id),
-		UserName: "auto",
-		Email:    "auto@gmail.com",
+func TestMigrate(t *testing.T) {
+	RegisterMigration(1, "create_migrate_go", func(o Ormer) error {
+		_, err := o.Raw("CREATE TABLE migrate_go (id integer)").Exec()
+		return err
+	}, func(o Ormer) error {
+		_, err := o.Raw("DROP TABLE migrate_go").Exec()
+		return err
+	})
+
+	dir, err := ioutil.TempDir("", "orm_migrate")
+	throwFailNow(t, err)
+	defer os.RemoveAll(dir)
+	files, err := createMigrationFiles(dir, "create_migrate_sql", "sql", []string{
+		"CREATE TABLE migrate_sql (id integer);",
+		"-- comment\nINSERT INTO migrate_sql (id)\nVALUES (1);",
+	})
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(len(files), 2))
+	for _, file := range files {
+		if strings.HasSuffix(file, ".down.sql") {
+			throwFailNow(t, ioutil.WriteFile(file, []byte("DROP TABLE migrate_sql;\n"), 0644))
+		}
 	}
+	throwFailNow(t, LoadMigrations(dir))
 
-	nid, err := dORM.Insert(su)
+	// a version with only the down file is not registered
+	downDir, err := ioutil.TempDir("", "orm_migrate_down")
+	throwFailNow(t, err)
+	defer os.RemoveAll(downDir)
+	throwFailNow(t, ioutil.WriteFile(filepath.Join(downDir, "3_only_down.down.sql"), []byte("DROP TABLE migrate_sql;\n"), 0644))
+	throwFail(t, AssertNot(LoadMigrations(downDir), nil))
+
+	files, err = createMigrationFiles(downDir, "create_migrate_tpl", "go", nil)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(len(files), 1))
+	content, err := ioutil.ReadFile(files[0])
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(strings.Contains(string(content), "_create_migrate_tpl.go: up is not written"), true))
+
+	states, err := MigrationStatus("default")
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(len(states), 2))
+	throwFail(t, AssertIs(states[0].Name, "create_migrate_go"))
+	throwFail(t, AssertIs(states[0].Applied, false))
+
+	done, err := MigrateUp("default", 0)
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(len(done), 2))
+
+	var num int
+	throwFail(t, dORM.Raw("SELECT COUNT(*) FROM migrate_sql").QueryRow(&num))
+	throwFail(t, AssertIs(num, 1))
+
+	states, err = MigrationStatus("default")
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(states[1].Applied, true))
+
+	done, err = MigrateUp("default", 0)
 	throwFail(t, err)
-	throwFail(t, AssertIs(nid, id))
+	throwFail(t, AssertIs(len(done), 0))
 
-	users := []User{
-		{ID: int(id + 100), UserName: "auto_100"},
-		{ID: int(id + 110), UserName: "auto_110"},
-		{ID: int(id + 120), UserName: "auto_120"},
+	done, err = MigrateDown("default", 2)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(len(done), 2))
+	throwFail(t, AssertIs(done[0].Name, "create_migrate_sql"))
+
+	states, err = MigrationStatus("default")
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(states[0].Applied, false))
+	throwFail(t, AssertIs(states[1].Applied, false))
+
+	RegisterMigration(99999999999999, "fail", func(o Ormer) error {
+		_, err := o.Raw("CREATE TABLE migrate_fail (id integer)").Exec()
+		if err != nil {
+			return err
+		}
+		return errors.New("fail")
+	}, nil)
+	done, err = MigrateUp("default", 0)
+	throwFail(t, AssertNot(err, nil))
+	throwFail(t, AssertIs(len(done), 2))
+
+	// the failed migration is rolled back and not recorded
+	states, err = MigrationStatus("default")
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(len(states), 3))
+	throwFail(t, AssertIs(states[2].Applied, false))
+
+	done, err = MigrateDown("default", 0)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(len(done), 1))
+	throwFail(t, AssertIs(done[0].Name, "create_migrate_sql"))
+	done, err = MigrateDown("default", 0)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(len(done), 1))
+	throwFail(t, AssertIs(done[0].Name, "create_migrate_go"))
+}
+
+func TestMigrationDiff(t *testing.T) {
+	if !IsSqlite {
+		return
 	}
-	num, err := dORM.InsertMulti(100, users)
-	throwFail(t, err)
-	throwFail(t, AssertIs(num, 3))
 
-	u = &User{
-		UserName: "auto_121",
+	queries, err := MigrationDiff("default")
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(len(queries), 0))
+
+	_, err = dORM.Raw("ALTER TABLE tag ADD COLUMN extra integer").Exec()
+	throwFailNow(t, err)
+	queries, err = MigrationDiff("default")
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(len(queries), 1))
+	throwFail(t, AssertIs(queries[0], "ALTER TABLE `tag` DROP COLUMN `extra`;"))
+	_, err = dORM.Raw(queries[0]).Exec()
+	throwFailNow(t, err)
+
+	queries, err = MigrationDiff("default")
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(len(queries), 0))
+}
+
+func TestPostgresQueryBuilder(t *testing.T) {
+	qb, err := NewQueryBuilder("postgres")
+	throwFailNow(t, err)
+	query := qb.Select("user.user_name", "profile.age", "COUNT(*)").
+		From("user").
+		InnerJoin("profile p").On("user.profile_id = p.id").
+		Where("user.user_name = ?").And("p.age > ?").
+		GroupBy("user.user_name", "profile.age").
+		OrderBy("profile.age DESC").
+		Limit(10).Offset(20).
+		String()
+	throwFail(t, AssertIs(query, `SELECT "user"."user_name", "profile"."age", COUNT(*) FROM "user" `+
+		`INNER JOIN "profile" "p" ON "user"."profile_id" = "p"."id" WHERE user.user_name = $1 AND p.age > $2 `+
+		`GROUP BY "user"."user_name", "profile"."age" ORDER BY "profile"."age" DESC LIMIT 10 OFFSET 20`))
+
+	sub, _ := NewQueryBuilder("postgres")
+	sub.Select("id").From("user").Where("status = ?")
+	qb, _ = NewQueryBuilder("postgres")
+	query = qb.Select("*").From(qb.Subquery(sub.String(), "u")).Where("id > ? AND name = '?'").String()
+	throwFail(t, AssertIs(query, `SELECT * FROM (SELECT "id" FROM "user" WHERE status = $1) AS "u" WHERE id > $2 AND name = '?'`))
+
+	qb, _ = NewQueryBuilder("postgres")
+	query = qb.InsertInto("tag", "name").Values("?").(ReturningQueryBuilder).Returning("id").String()
+	throwFail(t, AssertIs(query, `INSERT INTO "tag" ( "name" ) VALUES ( $1 ) RETURNING "id"`))
+
+	qb, _ = NewQueryBuilder("postgres")
+	query = qb.Select("id").From("item").Where(`attrs ?? ?`).And(`attrs ??| array['a', '?']`).And(`"?" = ?`).ForUpdate().String()
+	throwFail(t, AssertIs(query, `SELECT "id" FROM "item" WHERE attrs ?? $1 AND attrs ??| array['a', '?'] AND "?" = $2 FOR UPDATE`))
+	pg := new(dbBasePostgres)
+	pg.ReplaceMarks(&query)
+	throwFail(t, AssertIs(query, `SELECT "id" FROM "item" WHERE attrs ? $1 AND attrs ?| array['a', '?'] AND "?" = $2 FOR UPDATE`))
+
+	// the $n written by user are kept
+	qb, _ = NewQueryBuilder("postgres")
+	query = qb.Select("id").From("user").Where("a = $1 OR b = $1").And("c = ?").String()
+	throwFail(t, AssertIs(query, `SELECT "id" FROM "user" WHERE a = $1 OR b = $1 AND c = $2`))
+	pg.ReplaceMarks(&query)
+	throwFail(t, AssertIs(query, `SELECT "id" FROM "user" WHERE a = $1 OR b = $1 AND c = $2`))
+	sub, _ = NewQueryBuilder("postgres")
+	sub.Select("id").From("user").Where("a = $1 OR b = $1")
+	qb, _ = NewQueryBuilder("postgres")
+	query = qb.Select("*").From(qb.Subquery(sub.String(), "u")).String()
+	throwFail(t, AssertIs(query, `SELECT * FROM (SELECT "id" FROM "user" WHERE a = $1 OR b = $1) AS "u"`))
+
+	if !IsPostgres {
+		return
 	}
 
-	nid, err = dORM.Insert(u)
+	qb, _ = NewQueryBuilder("postgres")
+	query = qb.Select("id").From("tag").Where(`'{"a": 1}'::jsonb ?? ?`).And("id = $2 OR id = $2").String()
+	var ids []int
+	_, err = dORM.Raw(query, "a", 1).QueryRows(&ids)
 	throwFail(t, err)
-	throwFail(t, AssertIs(nid, id+120+1))
 }
 
-func TestUintPk(t *testing.T) {
-	name := "go"
-	u := &UintPk{
-		ID:   8,
-		Name: name,
+func TestSQLiteQueryBuilder(t *testing.T) {
+	qb, err := NewQueryBuilder("sqlite")
+	throwFailNow(t, err)
+	query := qb.Select("user_name").From("user").OrderBy("id").Offset(1).Limit(2).String()
+	throwFail(t, AssertIs(query, `SELECT "user_name" FROM "user" ORDER BY "id" LIMIT 2 OFFSET 1`))
+
+	qb, _ = NewQueryBuilder("sqlite3")
+	query = qb.Select("user_name").From("user").OrderBy("id").Offset(1).String()
+	throwFail(t, AssertIs(query, `SELECT "user_name" FROM "user" ORDER BY "id" LIMIT -1 OFFSET 1`))
+
+	fqb, _ := NewQueryBuilder("sqlite")
+	throwFail(t, AssertIs(fqb.Select("id").From("user").Where("id = ?").ForUpdate().String(), `SELECT "id" FROM "user" WHERE id = ?`))
+
+	if !IsSqlite {
+		return
 	}
 
-	created, _, err := dORM.ReadOrCreate(u, "ID")
+	var names []string
+	_, err = dORM.Raw(query).QueryRows(&names)
 	throwFail(t, err)
-	throwFail(t, AssertIs(created, true))
-	throwFail(t, AssertIs(u.Name, name))
+	throwFail(t, AssertIs(len(names), 2))
 
-	nu := &UintPk{ID: 8}
-	created, pk, err := dORM.ReadOrCreate(nu, "ID")
-	throwFail(t, err)
-	throwFail(t, AssertIs(created, false))
-	throwFail(t, AssertIs(nu.ID, u.ID))
-	throwFail(t, AssertIs(pk, u.ID))
-	throwFail(t, AssertIs(nu.Name, name))
+	qb, _ = NewQueryBuilder("sqlite")
+	query = qb.InsertInto("tag", "name").Values("?").(ReturningQueryBuilder).Returning("id").String()
+	var id int
+	throwFail(t, dORM.Raw(query, "qb-returning").QueryRow(&id))
+	throwFail(t, AssertNot(id, 0))
 
-	dORM.Delete(u)
+	qb, _ = NewQueryBuilder("sqlite")
+	query = qb.Delete("tag").Where("id = ?").String()
+	res, err := dORM.Raw(query, id).Exec()
+	throwFail(t, err)
+	num, _ := res.RowsAffected()
+	throwFail(t, AssertIs(num, 1))
 }
 
-func TestPtrPk(t *testing.T) {
-	parent := &IntegerPk{ID: 10, Value: "10"}
+func TestSQLBuilder(t *testing.T) {
+	_, err := NewSQLBuilder("unknown")
+	throwFail(t, AssertNot(err, nil))
 
-	id, _ := dORM.Insert(parent)
-	if !IsMysql {
-		// MySql does not support last_insert_id in this case: see #2382
-		throwFail(t, AssertIs(id, 10))
+	qb, err := NewSQLBuilder("postgres")
+	throwFailNow(t, err)
+	sub := qb.New().Select("user_id").From("profile").Where("age > ?", 18)
+	query, args := qb.Select("id", "user_name").From("user").
+		Where("status = ?", 1).
+		And(AnyOf(Expr("is_staff = ?", true), In("id", []int{1, 2}))).
+		And(In("id", sub)).
+		OrderBy("id DESC").
+		Offset(10).
+		ToSQL()
+	throwFail(t, AssertIs(query, `SELECT "id", "user_name" FROM "user" WHERE (status = $1) AND ((is_staff = $2) OR "id" IN ($3, $4)) `+
+		`AND "id" IN (SELECT "user_id" FROM "profile" WHERE age > $5) ORDER BY "id" DESC OFFSET 10`))
+	throwFail(t, AssertIs(fmt.Sprint(args), "[1 true 1 2 18]"))
+
+	qb, _ = NewSQLBuilder("mysql")
+	query, args = qb.Update("user").Set("status", 2).SetExpr("nums", "nums + ?", 1).
+		Where(Not(In("id"))).Offset(5).ToSQL()
+	throwFail(t, AssertIs(query, "UPDATE `user` SET `status` = ?, `nums` = nums + ? WHERE NOT (1 = 0) LIMIT 18446744073709551615 OFFSET 5"))
+	throwFail(t, AssertIs(len(args), 2))
+
+	qb, _ = NewSQLBuilder("postgres")
+	query, args = qb.Select("id").From("item").Where("attrs ?? ?", "color").And("id IN ?", qb.New().Select("id").From("tag")).ToSQL()
+	throwFail(t, AssertIs(query, `SELECT "id" FROM "item" WHERE (attrs ?? $1) AND (id IN (SELECT "id" FROM "tag"))`))
+	throwFail(t, AssertIs(len(args), 1))
+
+	if IsPostgres {
+		qb, _ = NewSQLBuilder("postgres")
+		var ids []int
+		_, err = dORM.Raw(qb.Select("id").From("tag").Where(`'{"a": 1}'::jsonb ?? ?`, "a").And("id = $2 OR id = $2").ToSQL()).QueryRows(&ids)
+		throwFail(t, AssertIs(err, nil))
 	}
 
-	ptr := PtrPk{ID: parent, Positive: true}
-	num, err := dORM.InsertMulti(2, []PtrPk{ptr})
+	if !IsSqlite {
+		return
+	}
+
+	qb, _ = NewSQLBuilder("sqlite3")
+	qb.InsertInto("tag", "name").Values("qb-1").Values("qb-2").Returning("id")
+	var ids []int
+	num, err := dORM.Raw(qb.ToSQL()).QueryRows(&ids)
+	throwFail(t, err)
+	throwFail(t, AssertIs(num, 2))
+
+	qb, _ = NewSQLBuilder("sqlite3")
+	qb.Select("name").From("tag").In("id", ids).And("name LIKE ?", "qb-%").OrderBy("name DESC").Offset(1)
+	var names []string
+	num, err = dORM.Raw(qb.ToSQL()).QueryRows(&names)
 	throwFail(t, err)
 	throwFail(t, AssertIs(num, 1))
-	throwFail(t, AssertIs(ptr.ID, parent))
+	throwFail(t, AssertIs(names[0], "qb-1"))
 
-	nptr := &PtrPk{ID: parent}
-	created, pk, err := dORM.ReadOrCreate(nptr, "ID")
+	qb, _ = NewSQLBuilder("sqlite3")
+	res, err := dORM.Raw(qb.Delete("tag").Where(In("id", ids)).ToSQL()).Exec()
 	throwFail(t, err)
-	throwFail(t, AssertIs(created, false))
-	throwFail(t, AssertIs(pk, 10))
-	throwFail(t, AssertIs(nptr.ID, parent))
+	num, _ = res.RowsAffected()
+	throwFail(t, AssertIs(num, 2))
+}
+
+func TestOptimisticLock(t *testing.T) {
+	m := VersionModel{Name: "first"}
+	id, err := dORM.Insert(&m)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(id > 0, true))
+
+	a := VersionModel{ID: m.ID}
+	b := VersionModel{ID: m.ID}
+	throwFailNow(t, dORM.Read(&a))
+	throwFailNow(t, dORM.Read(&b))
+
+	a.Name = "second"
+	num, err := dORM.Update(&a)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 1))
+	throwFailNow(t, AssertIs(a.Version, 1))
+
+	b.Name = "third"
+	num, err = dORM.Update(&b, "Name")
+	throwFailNow(t, AssertIs(err, ErrStaleObject))
+	throwFailNow(t, AssertIs(num, 0))
+	throwFailNow(t, AssertIs(b.Version, 0))
+
+	// version set by hand is ignored
+	a.Name = "fourth"
+	a.Version = 1
+	num, err = dORM.Update(&a, "Name", "Version")
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 1))
+
+	c := VersionModel{ID: m.ID}
+	throwFailNow(t, dORM.Read(&c))
+	throwFailNow(t, AssertIs(c.Name, "fourth"))
+	throwFailNow(t, AssertIs(c.Version, 2))
+
+	// a missing row is not stale
+	num, err = dORM.Update(&VersionModel{ID: m.ID + 1000, Name: "missing"})
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 0))
+}
+
+func TestKeysetPaging(t *testing.T) {
+	for i, version := range []int{3, 1, 3, 2, 1} {
+		_, err := dORM.Insert(&VersionModel{Name: fmt.Sprintf("keyset%d", i+1), Version: version})
+		throwFailNow(t, err)
+	}
+
+	qs := dORM.QueryTable("version_model").Filter("name__startswith", "keyset").OrderBy("-version")
+	var names []string
+	var cursor string
+	for page := 0; page < 5; page++ {
+		var rows []*VersionModel
+		pqs := qs.After(cursor).Limit(2)
+		num, err := pqs.All(&rows)
+		throwFailNow(t, err)
+		for _, row := range rows {
+			names = append(names, row.Name)
+		}
+		if num < 2 {
+			break
+		}
+		cursor, err = pqs.Cursor(rows[len(rows)-1])
+		throwFailNow(t, err)
+	}
+	throwFailNow(t, AssertIs(strings.Join(names, ","), "keyset1,keyset3,keyset4,keyset2,keyset5"))
+
+	_, err := qs.After("bad").Count()
+	throwFailNow(t, AssertIs(err, ErrInvalidCursor))
+	// cursor of other order
+	_, err = qs.OrderBy("name").After(cursor).Count()
+	throwFailNow(t, AssertIs(err, ErrInvalidCursor))
+	_, err = qs.OrderBy("missing").After("").Count()
+	throwFailNow(t, AssertNot(err, nil))
+	_, err = qs.Cursor(&HookModel{})
+	throwFailNow(t, AssertNot(err, nil))
+
+	// rows in the same second are paged by the fractional seconds
+	base := time.Date(2020, 1, 2, 3, 4, 5, 0, DefaultTimeLoc)
+	for i := 0; i < 3; i++ {
+		m := &UpsertModel{Code: fmt.Sprintf("keyset-ts%d", i), Created: base.Add(time.Duration(i) * 100 * time.Millisecond)}
+		_, err = dORM.Insert(m)
+		throwFailNow(t, err)
+	}
+	tqs := dORM.QueryTable("upsert_model").Filter("code__startswith", "keyset-ts").OrderBy("-created")
+	names, cursor = nil, ""
+	for page := 0; page < 4; page++ {
+		var rows []*UpsertModel
+		pqs := tqs.After(cursor).Limit(1)
+		num, err := pqs.All(&rows)
+		throwFailNow(t, err)
+		if num == 0 {
+			break
+		}
+		names = append(names, rows[0].Code)
+		cursor, err = pqs.Cursor(rows[0])
+		throwFailNow(t, err)
+	}
+	throwFailNow(t, AssertIs(strings.Join(names, ","), "keyset-ts2,keyset-ts1,keyset-ts0"))
+	_, err = tqs.Delete()
+	throwFailNow(t, err)
+}
+
+func TestIterate(t *testing.T) {
+	ctx := context.Background()
+	qs := dORM.QueryTable("post").OrderBy("id").RelatedSel()
+	var posts []*Post
+	num, err := qs.All(&posts)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num > 2, true))
+
+	var last interface{}
+	var i int
+	num, err = qs.Iterate(ctx, func(row interface{}) error {
+		post := row.(*Post)
+		throwFailNow(t, AssertIs(post.Title, posts[i].Title))
+		throwFailNow(t, AssertNot(post.User, nil))
+		throwFailNow(t, AssertIs(post.User.UserName, posts[i].User.UserName))
+		if last != nil {
+			throwFailNow(t, AssertIs(row == last, true))
+		}
+		last = row
+		i++
+		return nil
+	})
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, len(posts)))
+	throwFailNow(t, AssertIs(i, len(posts)))
+
+	// streaming is not capped by DefaultRowsLimit
+	limit := DefaultRowsLimit
+	DefaultRowsLimit = 2
+	num, err = qs.Iterate(ctx, func(row interface{}) error { return nil })
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, len(posts)))
+	num, err = qs.Limit(1).Iterate(ctx, func(row interface{}) error { return nil })
+	DefaultRowsLimit = limit
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 1))
+
+	errStop := errors.New("stop")
+	num, err = qs.Iterate(ctx, func(row interface{}) error {
+		if row.(*Post).ID == posts[1].ID {
+			return errStop
+		}
+		return nil
+	}, "ID", "Title")
+	throwFailNow(t, AssertIs(err, errStop))
+	throwFailNow(t, AssertIs(num, 2))
+
+	rows, err := qs.Filter("id", posts[0].ID).Rows(ctx)
+	throwFailNow(t, err)
+	var post Post
+	for rows.Next() {
+		throwFailNow(t, rows.Scan(&post))
+	}
+	throwFailNow(t, rows.Err())
+	throwFailNow(t, rows.Close())
+	throwFailNow(t, rows.Close())
+	throwFailNow(t, AssertIs(post.Title, posts[0].Title))
+
+	_, err = dORM.QueryTable("post").OrderBy("id").All(&posts)
+	throwFailNow(t, err)
+	Q := dDbBaser.TableQuote()
+	query := fmt.Sprintf("SELECT %sid%s, %stitle%s FROM %spost%s ORDER BY %sid%s", Q, Q, Q, Q, Q, Q, Q, Q)
+	i = 0
+	num, err = dORM.Raw(query).Iterate(ctx, &post, func(row interface{}) error {
+		throwFailNow(t, AssertIs(row.(*Post).Title, posts[i].Title))
+		throwFailNow(t, AssertIs(post.Content, ""))
+		i++
+		return nil
+	})
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, len(posts)))
+}
+
+type fakeMySQLError struct {
+	Number uint16
+}
+
+func (e *fakeMySQLError) Error() string {
+	return fmt.Sprintf("Error %d", e.Number)
+}
+
+func TestDoTx(t *testing.T) {
+	ctx := context.Background()
+	qs := dORM.QueryTable("version_model")
+	errFail := errors.New("fail")
+
+	err := dORM.DoTx(ctx, nil, func(txOrm Ormer) error {
+		_, err := txOrm.Insert(&VersionModel{Name: "tx_outer"})
+		throwFailNow(t, err)
+
+		err = txOrm.DoTx(ctx, nil, func(spOrm Ormer) error {
+			_, err := spOrm.Insert(&VersionModel{Name: "tx_rollback"})
+			throwFailNow(t, err)
+			return errFail
+		})
+		throwFailNow(t, AssertIs(err, errFail))
+
+		return txOrm.DoTx(ctx, nil, func(spOrm Ormer) error {
+			_, err := spOrm.Insert(&VersionModel{Name: "tx_inner"})
+			return err
+		})
+	})
+	throwFailNow(t, err)
+	num, err := qs.Filter("name__startswith", "tx_").Count()
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 2))
+	throwFailNow(t, AssertIs(qs.Filter("name", "tx_rollback").Exist(), false))
+
+	err = dORM.DoTx(ctx, nil, func(txOrm Ormer) error {
+		_, err := txOrm.Insert(&VersionModel{Name: "tx_failed"})
+		throwFailNow(t, err)
+		return errFail
+	})
+	throwFailNow(t, AssertIs(err, errFail))
+	throwFailNow(t, AssertIs(qs.Filter("name", "tx_failed").Exist(), false))
+
+	func() {
+		defer func() {
+			throwFailNow(t, AssertIs(recover(), "panic"))
+		}()
+		dORM.DoTx(ctx, nil, func(txOrm Ormer) error {
+			_, err := txOrm.Insert(&VersionModel{Name: "tx_panic"})
+			throwFailNow(t, err)
+			panic("panic")
+		})
+	}()
+	throwFailNow(t, AssertIs(qs.Filter("name", "tx_panic").Exist(), false))
+
+	var tries int
+	errRetry := &fakeMySQLError{Number: 1213}
+	err = dORM.DoTxWithRetry(ctx, nil, TxRetryPolicy{MaxRetries: 3, Backoff: time.Millisecond}, func(txOrm Ormer) error {
+		tries++
+		_, err := txOrm.Insert(&VersionModel{Name: fmt.Sprintf("tx_retry%d", tries)})
+		throwFailNow(t, err)
+		if tries < 3 {
+			return fmt.Errorf("insert: %w", errRetry)
+		}
+		return nil
+	})
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(tries, 3))
+	num, err = qs.Filter("name__startswith", "tx_retry").Count()
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 1))
+
+	tries = 0
+	err = dORM.DoTxWithRetry(ctx, nil, TxRetryPolicy{MaxRetries: 3}, func(txOrm Ormer) error {
+		tries++
+		return errFail
+	})
+	throwFailNow(t, AssertIs(err, errFail))
+	throwFailNow(t, AssertIs(tries, 1))
+
+	throwFailNow(t, AssertIs(IsRetryableTxError(&fakeMySQLError{Number: 1062}), false))
+	throwFailNow(t, AssertIs(IsRetryableTxError(nil), false))
+}
+
+func TestPrefetch(t *testing.T) {
+	var users []*User
+	num, err := dORM.QueryTable("user").OrderBy("id").Prefetch("Posts__Tags", "Posts__User", "Profile").All(&users)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 3))
+	for _, user := range users {
+		expected := User{ID: user.ID}
+		throwFailNow(t, dORM.Read(&expected))
+		_, err = dORM.LoadRelated(&expected, "Posts")
+		throwFailNow(t, err)
+		throwFailNow(t, AssertIs(len(user.Posts), len(expected.Posts)))
+		for i, post := range user.Posts {
+			throwFailNow(t, AssertIs(post.ID, expected.Posts[i].ID))
+			throwFailNow(t, AssertIs(post.User.UserName, user.UserName))
+
+			tags := Post{ID: post.ID}
+			_, err = dORM.LoadRelated(&tags, "Tags")
+			throwFailNow(t, err)
+			throwFailNow(t, AssertIs(len(post.Tags), len(tags.Tags)))
+			for j, tag := range post.Tags {
+				throwFailNow(t, AssertIs(tag.Name, tags.Tags[j].Name))
+			}
+		}
+		if expected.Profile == nil {
+			throwFailNow(t, AssertIs(user.Profile == nil, true))
+		} else {
+			throwFailNow(t, dORM.Read(expected.Profile))
+			throwFailNow(t, AssertIs(user.Profile.Age, expected.Profile.Age))
+		}
+	}
+	// users without posts have an empty slice
+	for _, user := range users {
+		throwFailNow(t, AssertIs(user.Posts != nil, true))
+	}
+
+	// reverse many through m2m
+	var tags []Tag
+	num, err = dORM.QueryTable("tag").OrderBy("id").Prefetch("Posts").All(&tags)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, len(tags)))
+	for _, tag := range tags {
+		expected := Tag{ID: tag.ID}
+		_, err = dORM.LoadRelated(&expected, "Posts")
+		throwFailNow(t, err)
+		throwFailNow(t, AssertIs(len(tag.Posts), len(expected.Posts)))
+	}
+
+	// reverse one
+	for _, user := range users {
+		if user.Profile == nil {
+			continue
+		}
+		var profile Profile
+		err = dORM.QueryTable("user_profile").Filter("id", user.Profile.ID).Prefetch("User").One(&profile)
+		throwFailNow(t, err)
+		throwFailNow(t, AssertIs(profile.User.UserName, user.UserName))
+	}
+
+	_, err = dORM.QueryTable("user").Prefetch("UserName").All(&users)
+	throwFailNow(t, AssertIs(err != nil, true))
+}
+
+func TestAggregate(t *testing.T) {
+	var profiles []*Profile
+	num, err := dORM.QueryTable("user_profile").All(&profiles)
+	throwFailNow(t, err)
+	var sum, max int64
+	var money float64
+	for _, p := range profiles {
+		sum += int64(p.Age)
+		if int64(p.Age) > max {
+			max = int64(p.Age)
+		}
+		money += p.Money
+	}
+
+	var params Params
+	_, err = dORM.QueryTable("user_profile").Aggregate(&params, "Count(*) AS total", "Sum(Age)", "Max(Age)", "Avg(Age)", "Sum(Money)")
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(params["total"], num))
+	throwFailNow(t, AssertIs(params["sum_age"], sum))
+	throwFailNow(t, AssertIs(params["max_age"], max))
+	throwFailNow(t, AssertIs(params["avg_age"], float64(sum)/float64(num)))
+	throwFailNow(t, AssertIs(params["sum_money"], money))
+
+	var stat struct {
+		Total  int64 `orm:"column(total)"`
+		SumAge int
+		MaxAge *int16
+	}
+	_, err = dORM.QueryTable("user_profile").Aggregate(&stat, "Count(*) AS total", "Sum(Age)", "Max(Age)")
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(stat.Total, num))
+	throwFailNow(t, AssertIs(stat.SumAge, sum))
+	throwFailNow(t, AssertIs(*stat.MaxAge, max))
+
+	// null for empty rows
+	_, err = dORM.QueryTable("user_profile").Filter("age__lt", 0).Aggregate(&stat, "Count(*) AS total", "Max(Age)")
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(stat.Total, 0))
+	throwFailNow(t, AssertIs(stat.MaxAge == nil, true))
+
+	// annotate with group by
+	var posts []*Post
+	_, err = dORM.QueryTable("post").Filter("User__UserName__isnull", false).All(&posts)
+	throwFailNow(t, err)
+	counts := make(map[int]int64)
+	for _, post := range posts {
+		counts[post.User.ID]++
+	}
+
+	var maps []Params
+	num, err = dORM.QueryTable("post").GroupBy("User__ID").Annotate("Count(ID) AS posts").OrderBy("-posts", "User__ID").Values(&maps)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, len(counts)))
+	for i, m := range maps {
+		throwFailNow(t, AssertIs(m["posts"], counts[int(m["User__ID"].(int64))]))
+		if i > 0 {
+			throwFailNow(t, AssertIs(maps[i-1]["posts"].(int64) >= m["posts"].(int64), true))
+		}
+	}
+
+	var rows []struct {
+		User  int `orm:"column(User__ID)"`
+		Posts int64
+	}
+	num, err = dORM.QueryTable("post").GroupBy("User__ID").OrderBy("User__ID").Aggregate(&rows, "Count(ID) AS posts")
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, len(counts)))
+	for _, row := range rows {
+		throwFailNow(t, AssertIs(row.Posts, counts[row.User]))
+	}
+
+	// related field
+	var users []*User
+	_, err = dORM.QueryTable("user").Filter("profile__isnull", false).RelatedSel("profile").All(&users)
+	throwFailNow(t, err)
+	var total int64
+	for _, user := range users {
+		total += int64(user.Profile.Age)
+	}
+	_, err = dORM.QueryTable("user").Aggregate(&params, "Sum(Profile__Age)")
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(params["sum_profile__age"], total))
+
+	_, err = dORM.QueryTable("user").Aggregate(&params, "Foo(ID)")
+	throwFailNow(t, AssertIs(err != nil, true))
+	_, err = dORM.QueryTable("user").Aggregate(&params, "Sum(*)")
+	throwFailNow(t, AssertIs(err != nil, true))
+}
+
+func TestInsertMultiOrUpdate(t *testing.T) {
+	models := []*UpsertModel{
+		{Code: "a", Name: "a1", Nums: 1},
+		{Code: "b", Name: "b1", Nums: 1},
+		{Code: "c", Name: "c1", Nums: 1},
+	}
+	nums, err := dORM.InsertMultiOrUpdate(2, models, []string{"Code"}, nil)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(len(nums), 2))
+	throwFailNow(t, AssertIs(nums[0], 2))
+	throwFailNow(t, AssertIs(nums[1], 1))
+
+	var a UpsertModel
+	throwFailNow(t, dORM.QueryTable("upsert_model").Filter("code", "a").One(&a))
+	created := a.Created
+
+	models = []*UpsertModel{
+		{Code: "a", Name: "a2", Nums: 2},
+		{Code: "d", Name: "d2", Nums: 2},
+	}
+	nums, err = dORM.InsertMultiOrUpdate(10, models, []string{"Code"}, []string{"Name"})
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(len(nums), 1))
+
+	var list []*UpsertModel
+	num, err := dORM.QueryTable("upsert_model").OrderBy("code").All(&list)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 4))
+	// only Name is updated, Nums and Created are kept
+	throwFailNow(t, AssertIs(list[0].Name, "a2"))
+	throwFailNow(t, AssertIs(list[0].Nums, 1))
+	throwFailNow(t, AssertIs(list[0].Created.Unix(), created.Unix()))
+	throwFailNow(t, AssertIs(list[0].Version, 1))
+	throwFailNow(t, AssertIs(list[3].Name, "d2"))
+	throwFailNow(t, AssertIs(list[3].Version, 0))
+
+	// all columns except conflict ones
+	models = []*UpsertModel{{Code: "b", Name: "b3", Nums: 3}}
+	_, err = dORM.InsertMultiOrUpdate(1, models, []string{"code"}, nil)
+	throwFailNow(t, err)
+	var b UpsertModel
+	throwFailNow(t, dORM.QueryTable("upsert_model").Filter("code", "b").One(&b))
+	throwFailNow(t, AssertIs(b.Name, "b3"))
+	throwFailNow(t, AssertIs(b.Nums, 3))
+
+	_, err = dORM.InsertMultiOrUpdate(1, models, []string{"Unknown"}, nil)
+	throwFailNow(t, AssertIs(err != nil, true))
+	if !IsMysql {
+		_, err = dORM.InsertMultiOrUpdate(1, models, nil, nil)
+		throwFailNow(t, AssertIs(err != nil, true))
+	}
+}
+
+func TestGenerateModels(t *testing.T) {
+	al := getDbAlias("default")
+	sources, err := generateModels(al, "models", []string{"user_profile", "tag"})
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(len(sources), 2))
+
+	src := string(sources["user_profile"])
+	throwFailNow(t, AssertIs(strings.Contains(src, "type UserProfile struct"), true))
+	throwFailNow(t, AssertIs(strings.Contains(src, `func (m *UserProfile) TableName() string`), true))
+	throwFailNow(t, AssertIs(strings.Contains(src, `orm:"column(age)"`), true))
+	throwFailNow(t, AssertIs(strings.Contains(src, "var _ model.BaseModel = new(UserProfile)"), true))
+	throwFailNow(t, AssertIs(strings.Contains(src, "func (m *UserProfile) GetID() int64"), true))
+
+	src = string(sources["tag"])
+	throwFailNow(t, AssertIs(strings.Contains(src, `orm:"column(name);size(30)"`), true))
+
+	if !IsSqlite {
+		return
+	}
+
+	queries := []string{
+		"CREATE TABLE gen_group (id integer PRIMARY KEY, name varchar(20) NOT NULL UNIQUE)",
+		"CREATE TABLE gen_member (id integer PRIMARY KEY, group_id integer REFERENCES gen_group(id) ON DELETE SET NULL, " +
+			"code varchar(10) NOT NULL, num int NOT NULL, created datetime, UNIQUE (code, num))",
+		"CREATE INDEX gen_member_created ON gen_member (created, num)",
+		"CREATE TABLE gen_pair (a int NOT NULL, b int NOT NULL, PRIMARY KEY (a, b))",
+	}
+	for _, query := range queries {
+		_, err = dORM.Raw(query).Exec()
+		throwFailNow(t, err)
+	}
+	defer func() {
+		for _, table := range []string{"gen_member", "gen_group", "gen_pair"} {
+			dORM.Raw("DROP TABLE " + table).Exec()
+		}
+	}()
+
+	sources, err = generateModels(al, "models", []string{"gen_group", "gen_member", "gen_pair"})
+	throwFailNow(t, err)
+
+	src = string(sources["gen_group"])
+	throwFailNow(t, AssertIs(strings.Contains(src, "ID   int    `orm:\"column(id);auto\"`"), true))
+	throwFailNow(t, AssertIs(strings.Contains(src, "Name string `orm:\"column(name);size(20);unique\"`"), true))
+
+	src = string(sources["gen_member"])
+	throwFailNow(t, AssertIs(strings.Contains(src, "Group   *GenGroup `orm:\"column(group_id);rel(fk);null;on_delete(set_null)\"`"), true))
+	throwFailNow(t, AssertIs(strings.Contains(src, "Created time.Time `orm:\"column(created);null;type(datetime)\"`"), true))
+	throwFailNow(t, AssertIs(strings.Contains(src, `{"Created", "Num"},`), true))
+	throwFailNow(t, AssertIs(strings.Contains(src, `{"Code", "Num"},`), true))
+
+	src = string(sources["gen_pair"])
+	throwFailNow(t, AssertIs(strings.Contains(src, `orm:"column(a);pk"`), true))
+	throwFailNow(t, AssertIs(strings.Contains(src, `orm:"column(b);pk"`), true))
+	throwFailNow(t, AssertIs(strings.Contains(src, "TableUnique"), false))
+	throwFailNow(t, AssertIs(strings.Contains(src, "model.BaseModel"), false))
+}
+
+func TestCompositePk(t *testing.T) {
+	var user User
+	err := dORM.QueryTable("user").OrderBy("ID").Limit(1).One(&user)
+	throwFailNow(t, err)
+	var post Post
+	err = dORM.QueryTable("post").OrderBy("ID").Limit(1).One(&post)
+	throwFailNow(t, err)
+
+	al := getDbAlias("default")
+	sqls, _ := getDbCreateSQL(al)
+	Q := al.DbBaser.TableQuote()
+	pk := fmt.Sprintf("PRIMARY KEY (%sdevice%s, %sts%s)", Q, Q, Q, Q)
+	throwFailNow(t, AssertIs(strings.Contains(strings.Join(sqls, "\n"), pk), true))
+
+	vote := PostVote{Post: &post, User: &user, Score: 1}
+	_, err = dORM.Insert(&vote)
+	throwFailNow(t, err)
+	_, err = dORM.Insert(&vote)
+	throwFailNow(t, AssertIs(err != nil, true))
+
+	v := PostVote{Post: &Post{ID: post.ID}, User: &User{ID: user.ID}}
+	err = dORM.Read(&v)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(v.Score, 1))
+	err = dORM.Read(&PostVote{Post: &post})
+	throwFailNow(t, AssertIs(err, ErrMissPK))
+
+	v.Score = 2
+	num, err := dORM.Update(&v)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 1))
+	err = dORM.Read(&vote)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(vote.Score, 2))
+
+	if IsMysql || IsPostgres || IsSqlite {
+		v.Score = 5
+		_, err = dORM.InsertOrUpdate(&v)
+		throwFailNow(t, err)
+		num, err = dORM.QueryTable("post_vote").Count()
+		throwFailNow(t, err)
+		throwFailNow(t, AssertIs(num, 1))
+		err = dORM.Read(&vote)
+		throwFailNow(t, err)
+		throwFailNow(t, AssertIs(vote.Score, 5))
+	}
+
+	throwFail(t, AssertIs(func() (err error) {
+		defer func() { err, _ = recover().(error) }()
+		dORM.QueryTable("post_vote").Filter("score", &v).Count()
+		return nil
+	}() != nil, true))
+
+	var votes []*PostVote
+	num, err = dORM.QueryTable("post_vote").RelatedSel().All(&votes)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 1))
+	throwFailNow(t, AssertIs(votes[0].User.UserName, user.UserName))
+	throwFailNow(t, AssertIs(votes[0].Post.Title, post.Title))
+
+	num, err = dORM.Delete(&v)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 1))
+
+	metrics := []*Metric{{Device: "d1", Ts: 1, Value: 1}, {Device: "d1", Ts: 2, Value: 2}, {Device: "d2", Ts: 1, Value: 3}}
+	num, err = dORM.InsertMulti(len(metrics), metrics)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 3))
+
+	num, err = dORM.QueryTable("metric").Filter("Device", "d1").Update(Params{"Value": 10})
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 2))
+	m := Metric{Device: "d1", Ts: 2}
+	err = dORM.Read(&m)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(m.Value, 10))
+
+	num, err = dORM.QueryTable("metric").Filter("Ts", 1).Delete()
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 2))
+	num, err = dORM.Delete(&m)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 1))
+}
+
+func TestJSONField(t *testing.T) {
+	doc1 := JSONDoc{Tags: []string{"x", "y"}}
+	doc1.Attrs.Color = "red"
+	doc1.Attrs.Size.Width = 10
+	doc2 := JSONDoc{Tags: []string{"y"}, Meta: map[string]interface{}{"k": "v"}}
+	doc2.Attrs.Color = "blue"
+	doc2.Attrs.Size.Width = 20
+	for _, doc := range []*JSONDoc{&doc1, &doc2} {
+		_, err := dORM.Insert(doc)
+		throwFailNow(t, err)
+	}
+
+	doc := JSONDoc{ID: doc1.ID}
+	err := dORM.Read(&doc)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(doc.Attrs.Color, "red"))
+	throwFailNow(t, AssertIs(doc.Attrs.Size.Width, 10))
+	throwFailNow(t, AssertIs(strings.Join(doc.Tags, ","), "x,y"))
+	throwFailNow(t, AssertIs(doc.Meta == nil, true))
+
+	doc = JSONDoc{ID: doc2.ID}
+	err = dORM.Read(&doc)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(doc.Meta["k"], "v"))
+
+	rs := dORM.Raw("SELECT id FROM json_doc WHERE attrs = :attrs").BindStruct(&doc1).(*rawSet)
+	throwFail(t, AssertIs(strings.Contains(ToStr(rs.named["attrs"]), `"red"`), true))
+
+	qs := dORM.QueryTable(new(JSONDoc))
+	num, err := qs.Filter("Attrs__json__color", "red").Count()
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 1))
+	num, err = qs.Filter("Attrs__json__size__width__gt", 15).Count()
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 1))
+	// "10" < "9" as text
+	num, err = qs.Filter("Attrs__json__size__width__lt", 9).Count()
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 0))
+	num, err = qs.Filter("Attrs__json__size__width__in", []int{10, 30}).Count()
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 1))
+
+	pg := new(dbBasePostgres)
+	throwFail(t, AssertIs(pg.GenerateJSONPath(`"T0"."attrs"`, []string{"size", "width"}, []interface{}{9}), `("T0"."attrs" #>> '{size,width}')::numeric`))
+	throwFail(t, AssertIs(pg.GenerateJSONPath(`"T0"."attrs"`, []string{"size", "width"}, []interface{}{[]float64{1.5, 2}}), `("T0"."attrs" #>> '{size,width}')::numeric`))
+	throwFail(t, AssertIs(pg.GenerateJSONPath(`"T0"."attrs"`, []string{"color"}, []interface{}{"red"}), `("T0"."attrs" #>> '{color}')`))
+	num, err = qs.Filter("Meta__json__k", "v").Count()
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 1))
+	num, err = qs.Filter("Meta__isnull", true).Count()
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 1))
+
+	num, err = qs.Filter("Tags__json_contains", "y").Count()
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 2))
+	var docs []*JSONDoc
+	num, err = qs.Filter("Tags__json_contains", "x").All(&docs)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 1))
+	throwFailNow(t, AssertIs(docs[0].Attrs.Color, "red"))
+}
+
+func TestShard(t *testing.T) {
+	if !IsSqlite {
+		return
+	}
+
+	dir, err := ioutil.TempDir("", "orm_shard")
+	throwFailNow(t, err)
+	defer os.RemoveAll(dir)
+
+	err = RegisterDataBase("shard_test", DBARGS.Driver, filepath.Join(dir, "shard.db"))
+	throwFailNow(t, err)
+	throwFailNow(t, RunSyncdb("shard_test", true, false))
+
+	index, err := ShardByRange(100, 200).Shard(150, 3)
+	throwFail(t, err)
+	throwFail(t, AssertIs(index, 1))
+	index, err = ShardByRange(100, 200).Shard(int64(300), 3)
+	throwFail(t, err)
+	throwFail(t, AssertIs(index, 2))
+	_, err = ShardByRange(100).Shard(1, 1)
+	throwFail(t, AssertIs(err.Error(), "<ShardByRange> 1 shards need at most 0 bounds, got 1"))
+
+	// owner 1 is on shard_test, owner 2 is on default
+	orders := []*ShardOrder{{Owner: 1, Amount: 10}, {Owner: 2, Amount: 20}, {Owner: 1, Amount: 30}}
+	for _, order := range orders {
+		_, err := dORM.Insert(order)
+		throwFailNow(t, err)
+	}
+	num, err := dORM.InsertMulti(10, []*ShardOrder{{Owner: 2, Amount: 40}, {Owner: 1, Amount: 50}})
+	throwFail(t, err)
+	throwFail(t, AssertIs(num, 2))
+
+	tables, err := getDbAlias("default").DbBaser.GetTables(getDbAlias("default").DB)
+	throwFail(t, err)
+	throwFail(t, AssertIs(tables["shard_order_0"], true))
+	throwFail(t, AssertIs(tables["shard_order_1"], false))
+
+	o := NewOrm()
+	throwFailNow(t, o.Using("shard_test"))
+	var cnt int
+	throwFail(t, o.Raw("SELECT COUNT(*) FROM shard_order_1").QueryRow(&cnt))
+	throwFail(t, AssertIs(cnt, 3))
+
+	order := &ShardOrder{ID: orders[2].ID, Owner: 1}
+	throwFail(t, dORM.Read(order))
+	throwFail(t, AssertIs(order.Amount, 30))
+
+	order.Amount = 31
+	num, err = dORM.Update(order, "Amount")
+	throwFail(t, err)
+	throwFail(t, AssertIs(num, 1))
+
+	order.Owner = 2
+	_, err = dORM.Update(order, "Owner", "Amount")
+	throwFail(t, AssertIs(err, ErrShardKeyUpdate))
+	order.Owner = 1
+
+	qs := dORM.QueryTable(new(ShardOrder))
+	num, err = qs.Filter("Owner", 1).Count()
+	throwFail(t, err)
+	throwFail(t, AssertIs(num, 3))
+	num, err = qs.Filter("Owner", "1").Count()
+	throwFail(t, err)
+	throwFail(t, AssertIs(num, 3))
+	num, err = qs.Filter("Owner", int8(1)).Count()
+	throwFail(t, err)
+	throwFail(t, AssertIs(num, 3))
+	_, err = qs.Filter("Owner", 1).Update(Params{"owner": 2})
+	throwFail(t, AssertIs(err, ErrShardKeyUpdate))
+	num, err = qs.Filter("Owner", 2).Count()
+	throwFail(t, err)
+	throwFail(t, AssertIs(num, 2))
+
+	_, err = qs.Count()
+	throwFail(t, AssertIs(err, ErrNoShardKey))
+	_, err = qs.Filter("Amount__gt", 10).All(&[]*ShardOrder{})
+	throwFail(t, AssertIs(err, ErrNoShardKey))
+
+	num, err = qs.AllShards().Count()
+	throwFail(t, err)
+	throwFail(t, AssertIs(num, 5))
+
+	var list []*ShardOrder
+	num, err = qs.AllShards().OrderBy("-Amount").Limit(3, 1).All(&list)
+	throwFail(t, err)
+	throwFail(t, AssertIs(num, 3))
+	throwFail(t, AssertIs(list[0].Amount, 40))
+	throwFail(t, AssertIs(list[1].Amount, 31))
+	throwFail(t, AssertIs(list[2].Amount, 20))
+
+	var one ShardOrder
+	throwFail(t, qs.AllShards().Filter("Amount", 40).One(&one))
+	throwFail(t, AssertIs(one.Owner, 2))
+
+	var amounts ParamsList
+	num, err = qs.AllShards().OrderBy("Amount").ValuesFlat(&amounts, "Amount")
+	throwFail(t, err)
+	throwFail(t, AssertIs(num, 5))
+
+	_, err = qs.AllShards().Rows(context.Background())
+	throwFail(t, AssertIs(err, ErrShardFanOut))
+
+	num, err = qs.Filter("Owner", 1).Filter("Amount__gte", 31).Update(Params{"Amount": ColValue(ColAdd, 1)})
+	throwFail(t, err)
+	throwFail(t, AssertIs(num, 2))
+
+	num, err = dORM.Delete(&ShardOrder{ID: orders[1].ID, Owner: 2})
+	throwFail(t, err)
+	throwFail(t, AssertIs(num, 1))
+
+	// the shard key filled by hook routes the row
+	_, err = dORM.Insert(&ShardOrder{Amount: 60})
+	throwFail(t, err)
+	num, err = qs.Filter("Owner", 3).Count()
+	throwFail(t, err)
+	throwFail(t, AssertIs(num, 1))
+
+	num, err = qs.AllShards().Filter("Amount__gt", 0).Delete()
+	throwFail(t, err)
+	throwFail(t, AssertIs(num, 5))
+}
+
+func TestEncryptField(t *testing.T) {
+	keys := map[string][]byte{"v1": []byte("0123456789abcdef0123456789abcdef")}
+	SetKeyProvider(&StaticKeyProvider{Current: "v1", Keys: keys, IndexKey: []byte("index-key")})
+	defer SetKeyProvider(nil)
+
+	card := "6222020000001234"
+	user := &SecretUser{Phone: "13800001111", Card: &card}
+	_, err := dORM.Insert(user)
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(len(user.PhoneIndex), 64))
+
+	var raw string
+	throwFail(t, dORM.Raw("SELECT phone FROM secret_user WHERE id = ?", user.ID).QueryRow(&raw))
+	throwFail(t, AssertIs(strings.HasPrefix(raw, "enc:v1:"), true))
+	throwFail(t, AssertNot(strings.Contains(raw, "13800001111"), true))
+	throwFail(t, AssertIs(maskLogArg(raw), "enc:v1:***"))
+
+	u := &SecretUser{ID: user.ID}
+	throwFail(t, dORM.Read(u))
+	throwFail(t, AssertIs(u.Phone, "13800001111"))
+	throwFail(t, AssertIs(*u.Card, card))
+
+	qs := dORM.QueryTable(new(SecretUser))
+	u = &SecretUser{}
+	throwFail(t, qs.Filter("Phone", "13800001111").One(u))
+	throwFail(t, AssertIs(u.ID, user.ID))
+	num, err := qs.Filter("Phone__in", "13800001111", "13800002222").Count()
+	throwFail(t, err)
+	throwFail(t, AssertIs(num, 1))
+	num, err = qs.Filter("Card__isnull", false).Count()
+	throwFail(t, err)
+	throwFail(t, AssertIs(num, 1))
+	throwFail(t, AssertIs(func() (err error) {
+		defer func() { err, _ = recover().(error) }()
+		qs.Filter("Card", card).Count()
+		return nil
+	}() != nil, true))
+
+	var id int
+	throwFail(t, dORM.Raw("SELECT id FROM secret_user WHERE phone_index = :phone_index").BindStruct(&SecretUser{Phone: "13800001111"}).QueryRow(&id))
+	throwFail(t, AssertIs(id, user.ID))
+
+	var maps []Params
+	_, err = qs.Filter("Phone", "13800001111").Values(&maps, "Phone")
+	throwFail(t, err)
+	throwFail(t, AssertIs(maps[0]["Phone"], "13800001111"))
+
+	// rotate key, old values are still readable and new values use the new key
+	keys["v2"] = []byte("abcdef0123456789abcdef0123456789")
+	SetKeyProvider(&StaticKeyProvider{Current: "v2", Keys: keys, IndexKey: []byte("index-key")})
+	user.Phone = "13800002222"
+	num, err = dORM.Update(user, "Phone")
+	throwFail(t, err)
+	throwFail(t, AssertIs(num, 1))
+	throwFail(t, dORM.Raw("SELECT phone FROM secret_user WHERE id = ?", user.ID).QueryRow(&raw))
+	throwFail(t, AssertIs(strings.HasPrefix(raw, "enc:v2:"), true))
+	u = &SecretUser{}
+	throwFail(t, qs.Filter("Phone", "13800002222").One(u))
+	throwFail(t, AssertIs(*u.Card, card))
+
+	num, err = qs.Filter("Phone", "13800002222").Update(Params{"Phone": "13800003333"})
+	throwFail(t, err)
+	throwFail(t, AssertIs(num, 1))
+	throwFail(t, AssertIs(qs.Filter("Phone", "13800003333").Exist(), true))
+
+	throwFail(t, AssertIs(MaskString("13800003333", 3, 4), "138****3333"))
+	throwFail(t, AssertIs(MaskString("abc", 2, 2), "***"))
+
+	LogMask = func(arg interface{}) interface{} {
+		if s, ok := arg.(string); ok && len(s) == 11 {
+			return MaskString(s, 3, 4)
+		}
+		return arg
+	}
+	defer func() { LogMask = nil }()
+	masked := maskLogArgs([]interface{}{"13800003333", 1, raw})
+	throwFail(t, AssertIs(masked[0], "138****3333"))
+	throwFail(t, AssertIs(masked[1], 1))
+	throwFail(t, AssertIs(masked[2], "enc:v2:***"))
+}
+
+func TestPool(t *testing.T) {
+	SetConnMaxLifetime("default", time.Hour)
+	SetConnMaxIdleTime("default", time.Minute)
+	defer SetConnMaxLifetime("default", 0)
+	defer SetConnMaxIdleTime("default", 0)
+	al := getDbAlias("default")
+	throwFail(t, AssertIs(al.ConnMaxLifetime, time.Hour))
+	throwFail(t, AssertIs(al.ConnMaxIdleTime, time.Minute))
+
+	stats, err := GetDBStats()
+	throwFail(t, err)
+	throwFail(t, AssertIs(stats.OpenConnections > 0, true))
+
+	reg := prometheus.NewRegistry()
+	throwFailNow(t, reg.Register(NewPoolCollector("test")))
+	mfs, err := reg.Gather()
+	throwFailNow(t, err)
+	found := false
+	for _, mf := range mfs {
+		if mf.GetName() != "test_orm_pool_open_connections" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["alias"] == "default" && labels["db"] == "primary" {
+				found = true
+				throwFail(t, AssertIs(m.GetGauge().GetValue(), float64(stats.OpenConnections)))
+			}
+		}
+	}
+	throwFail(t, AssertIs(found, true))
+
+	throwFail(t, Ping(context.Background(), "default"))
+	throwFail(t, AssertNot(Ping(context.Background(), "not_exist"), nil))
+	throwFail(t, HealthCheck(context.Background()))
+
+	w := httptest.NewRecorder()
+	HealthHandler(time.Second).ServeHTTP(w, httptest.NewRequest("GET", "/ready", nil))
+	throwFail(t, AssertIs(w.Code, http.StatusOK))
+}
+
+func TestExplain(t *testing.T) {
+	qs := dORM.QueryTable("user")
+	query, args, err := qs.Filter("user_name", "slene").ToSQL("UserName")
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(strings.HasPrefix(query, "SELECT "), true))
+	throwFail(t, AssertIs(strings.Contains(query, "user_name"), true))
+	throwFail(t, AssertIs(len(args), 1))
+	throwFail(t, AssertIs(args[0], "slene"))
+
+	_, _, err = qs.ToSQL("not_exist")
+	throwFail(t, AssertNot(err, nil))
+
+	query, _, err = qs.ToSQL()
+	throwFailNow(t, err)
+	plan, err := qs.Explain(context.Background())
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(plan.Query, query))
+	throwFail(t, AssertIs(len(plan.Raw) > 0, true))
+	scans := plan.FullScans()
+	throwFailNow(t, AssertIs(len(scans), 1))
+	throwFail(t, AssertIs(scans[0].Table, "user"))
+
+	plan, err = qs.RelatedSel("profile").Filter("user_name", "slene").Explain(context.Background())
+	throwFailNow(t, err)
+	tables := make(map[string]bool)
+	for _, step := range plan.Steps {
+		if step.Table != "" {
+			tables[step.Table] = true
+		}
+		if !IsPostgres {
+			// postgres scans the small tables without index
+			throwFail(t, AssertIs(step.FullScan, false))
+		}
+	}
+	throwFail(t, AssertIs(tables["user"], true))
+	throwFail(t, AssertIs(tables["user_profile"], true))
+}
+
+func TestTenant(t *testing.T) {
+	al := getDbAlias("default")
+	Q := al.DbBaser.TableQuote()
+	for _, tenant := range []string{"t1", "t2"} {
+		table := Q + "tenant_doc_" + tenant + Q
+		_, err := dORM.Raw("DROP TABLE IF EXISTS " + table).Exec()
+		throwFailNow(t, err)
+		_, err = dORM.Raw(fmt.Sprintf("CREATE TABLE %s (%sid%s %s, %stitle%s varchar(100) NOT NULL)",
+			table, Q, Q, al.DbBaser.DbTypes()["auto"], Q, Q)).Exec()
+		throwFailNow(t, err)
+		defer dORM.Raw("DROP TABLE " + table).Exec()
+	}
+	ctx1 := metadata.NewContext(context.Background(), metadata.MD{metadata.Tenant: "t1"})
+	ctx2 := metadata.NewContext(context.Background(), metadata.MD{metadata.Tenant: "t2"})
+
+	tenant, ok := TenantFromContext(metadata.NewContext(context.Background(), metadata.MD{metadata.Tenant: int64(7)}))
+	throwFail(t, AssertIs(ok, true))
+	throwFail(t, AssertIs(tenant, "7"))
+
+	// no tenant, no fallback to the registered table
+	_, err := dORM.Insert(&TenantDoc{Title: "none"})
+	throwFail(t, AssertIs(err, ErrNoTenant))
+	_, err = dORM.QueryTable("tenant_doc").Count()
+	throwFail(t, AssertIs(err, ErrNoTenant))
+
+	doc := &TenantDoc{Title: "a"}
+	_, err = dORM.InsertWithCtx(ctx1, doc)
+	throwFailNow(t, err)
+	num, err := dORM.WithTenant(ctx2).InsertMulti(2, []*TenantDoc{{Title: "b"}, {Title: "c"}})
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(num, 2))
+
+	t1 := dORM.WithTenant(ctx1)
+	read := &TenantDoc{ID: doc.ID}
+	throwFail(t, t1.Read(read))
+	throwFail(t, AssertIs(read.Title, "a"))
+	num, err = t1.QueryTable("tenant_doc").Count()
+	throwFail(t, err)
+	throwFail(t, AssertIs(num, 1))
+	num, err = dORM.QueryTable("tenant_doc").WithCtx(ctx2).Count()
+	throwFail(t, err)
+	throwFail(t, AssertIs(num, 2))
+
+	// cross tenant
+	throwFail(t, AssertIs(t1.ReadWithCtx(ctx2, read), ErrCrossTenant))
+	_, err = t1.QueryTable("tenant_doc").WithCtx(ctx2).Count()
+	throwFail(t, AssertIs(err, ErrCrossTenant))
+
+	var titles []string
+	_, err = dORM.WithTenant(ctx2).Raw("SELECT title FROM {{tenant_doc}} ORDER BY title").QueryRows(&titles)
+	throwFail(t, err)
+	throwFail(t, AssertIs(strings.Join(titles, ","), "b,c"))
+	titles = nil
+	_, err = dORM.Raw("SELECT title FROM {{tenant_doc}}").WithCtx(ctx1).QueryRows(&titles)
+	throwFail(t, err)
+	throwFail(t, AssertIs(strings.Join(titles, ","), "a"))
+	_, err = dORM.Raw("SELECT title FROM {{tenant_doc}}").QueryRows(&titles)
+	throwFail(t, AssertIs(err, ErrNoTenant))
+	_, err = t1.Raw("SELECT * FROM {{not_exist}}").Exec()
+	throwFail(t, AssertNot(err, nil))
+
+	// joins into the tenant model use the tables of the tenant
+	_, err = dORM.Insert(&TenantNote{Doc: doc, Body: "note"})
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(func() (err error) {
+		defer func() { err, _ = recover().(error) }()
+		dORM.QueryTable("tenant_note").Filter("Doc__Title", "a").Count()
+		return nil
+	}(), ErrNoTenant))
+	num, err = dORM.QueryTable("tenant_note").WithCtx(ctx1).Filter("Doc__Title", "a").Count()
+	throwFail(t, err)
+	throwFail(t, AssertIs(num, 1))
+	num, err = dORM.QueryTable("tenant_note").WithCtx(ctx2).Filter("Doc__Title", "a").Count()
+	throwFail(t, err)
+	throwFail(t, AssertIs(num, 0))
+	var note TenantNote
+	throwFail(t, dORM.WithTenant(ctx2).QueryTable("tenant_note").RelatedSel().One(&note))
+	throwFail(t, AssertIs(note.Doc.Title, "b"))
+
+	bad := metadata.NewContext(context.Background(), metadata.MD{metadata.Tenant: "t1; --"})
+	_, err = dORM.QueryTable("tenant_doc").WithCtx(bad).Count()
+	throwFail(t, AssertNot(err, nil))
+
+	// transaction is pinned to the first tenant
+	o := NewOrm()
+	throwFailNow(t, o.Begin())
+	_, err = o.InsertWithCtx(ctx1, &TenantDoc{Title: "d"})
+	throwFail(t, err)
+	_, err = o.InsertWithCtx(ctx2, &TenantDoc{Title: "e"})
+	throwFail(t, AssertIs(err, ErrCrossTenant))
+	throwFail(t, o.Rollback())
+	num, err = o.QueryTable("tenant_doc").WithCtx(ctx2).Count()
+	throwFail(t, err)
+	throwFail(t, AssertIs(num, 2))
+}
+
+func TestSoftDelete(t *testing.T) {
+	article := SoftArticle{Title: "soft"}
+	_, err := dORM.Insert(&article)
+	throwFailNow(t, err)
+	tags := []*SoftTag{{Name: "go"}, {Name: "orm"}}
+	for _, tag := range tags {
+		_, err = dORM.Insert(tag)
+		throwFailNow(t, err)
+	}
+	num, err := dORM.QueryM2M(&article, "Tags").Add(tags)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 2))
+
+	num, err = dORM.Delete(tags[1])
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 1))
+	throwFailNow(t, AssertIs(tags[1].Deleted, true))
+	throwFailNow(t, AssertIs(tags[1].ID > 0, true))
+
+	qs := dORM.QueryTable("soft_tag")
+	num, err = qs.Count()
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 1))
+	num, err = qs.Unscoped().Count()
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 2))
+	var deleted []*SoftTag
+	num, err = qs.OnlyDeleted().All(&deleted)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 1))
+	throwFailNow(t, AssertIs(deleted[0].Name, "orm"))
+
+	// deleted tags are not loaded
+	num, err = dORM.LoadRelated(&article, "Tags")
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 1))
+	num, err = dORM.LoadRelated(&article, "Tags", SoftDeleteUnscoped)
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 2))
+
+	m2m := dORM.QueryM2M(&article, "Tags")
+	num, err = m2m.Count()
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 1))
+	throwFailNow(t, AssertIs(m2m.Exist(tags[1]), false))
+	num, err = m2m.Unscoped().Count()
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 2))
+
+	num, err = qs.Filter("name", "orm").Restore()
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 1))
+	num, err = qs.Count()
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 2))
+
+	_, err = dORM.QueryTable("soft_article").Restore()
+	throwFailNow(t, err)
+	_, err = dORM.QueryTable("hook_model").Restore()
+	throwFailNow(t, AssertIs(err, ErrNoSoftDelete))
+
+	// time soft delete field
+	qs = dORM.QueryTable("soft_article")
+	num, err = qs.Filter("title", "soft").Delete()
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 1))
+	throwFailNow(t, AssertIs(qs.Exist(), false))
+	var a SoftArticle
+	throwFailNow(t, qs.OnlyDeleted().One(&a))
+	throwFailNow(t, AssertIs(a.DeletedAt.IsZero(), false))
+	throwFailNow(t, dORM.Read(&SoftArticle{ID: article.ID}))
+
+	num, err = qs.Unscoped().Filter("id", article.ID).Delete()
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 1))
+	num, err = qs.Unscoped().Count()
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 0))
+
+	num, err = dORM.QueryTable("soft_tag").Unscoped().Filter("id__gt", 0).Delete()
+	throwFailNow(t, err)
+	throwFailNow(t, AssertIs(num, 2))
+}
+
+func TestModelHooks(t *testing.T) {
+	_, err := dORM.Insert(&HookModel{})
+	throwFail(t, AssertIs(err, errHookProtected))
+
+	m := &HookModel{Name: "hook"}
+	id, err := dORM.Insert(m)
+	throwFailNow(t, err)
+	throwFail(t, AssertIs(m.Audit, "inserted"))
+
+	m2 := &HookModel{ID: int(id)}
+	throwFail(t, dORM.Read(m2))
+	throwFail(t, AssertIs(m2.Audit, "inserted"))
+	throwFail(t, AssertIs(m2.Reads, 1))
+
+	_, err = dORM.Update(m2)
+	throwFail(t, err)
+	throwFail(t, dORM.Read(m2))
+	throwFail(t, AssertIs(m2.Audit, "updated"))
+
+	mds := []*HookModel{{Name: "hook1"}, {Name: "hook2"}}
+	num, err := dORM.InsertMulti(2, mds)
+	throwFail(t, err)
+	throwFail(t, AssertIs(num, 2))
+	throwFail(t, AssertIs(mds[1].Audit, "inserted"))
+
+	_, err = dORM.InsertMulti(2, []HookModel{{Name: "hook3"}, {}})
+	throwFail(t, AssertIs(err, errHookProtected))
+
+	var conflict []string
+	if IsPostgres || IsSqlite {
+		conflict = []string{"id"}
+	}
+	up := &HookModel{Name: "hook4"}
+	_, err = dORM.InsertOrUpdate(up, conflict...)
+	throwFail(t, err)
+	throwFail(t, AssertIs(up.Audit, "inserted"))
+
+	_, err = dORM.InsertMultiOrUpdate(2, []*HookModel{{Name: "hook5"}, {}}, []string{"ID"}, nil)
+	throwFail(t, AssertIs(err, errHookProtected))
+
+	var all []HookModel
+	num, err = dORM.QueryTable("hook_model").All(&all)
+	throwFail(t, err)
+	throwFail(t, AssertIs(num, 4))
+	throwFail(t, AssertIs(all[2].Reads, 1))
+
+	m2.Name = "protected"
+	_, err = dORM.Delete(m2)
+	throwFail(t, AssertIs(err, errHookProtected))
+
+	num, err = dORM.QueryTable("hook_model").Filter("id__gt", 0).Delete()
+	throwFail(t, err)
+	throwFail(t, AssertIs(num, 4))
+}
+
+func TestReadOrCreate(t *testing.T) {
+	u := &User{
+		UserName: "Kyle",
+		Email:    "kylemcc@gmail.com",
+		Password: "other_pass",
+		Status:   7,
+		IsStaff:  false,
+		IsActive: true,
+	}
+
+	created, pk, err := dORM.ReadOrCreate(u, "UserName")
+	throwFail(t, err)
+	throwFail(t, AssertIs(created, true))
+	throwFail(t, AssertIs(u.ID, pk))
+	throwFail(t, AssertIs(u.UserName, "Kyle"))
+	throwFail(t, AssertIs(u.Email, "kylemcc@gmail.com"))
+	throwFail(t, AssertIs(u.Password, "other_pass"))
+	throwFail(t, AssertIs(u.Status, 7))
+	throwFail(t, AssertIs(u.IsStaff, false))
+	throwFail(t, AssertIs(u.IsActive, true))
+	throwFail(t, AssertIs(u.Created.In(DefaultTimeLoc), u.Created.In(DefaultTimeLoc), testDate))
+	throwFail(t, AssertIs(u.Updated.In(DefaultTimeLoc), u.Updated.In(DefaultTimeLoc), testDateTime))
+
+	nu := &User{UserName: u.UserName, Email: "someotheremail@gmail.com"}
+	created, pk, err = dORM.ReadOrCreate(nu, "UserName")
+	throwFail(t, err)
+	throwFail(t, AssertIs(created, false))
+	throwFail(t, AssertIs(nu.ID, u.ID))
+	throwFail(t, AssertIs(pk, u.ID))
+	throwFail(t, AssertIs(nu.UserName, u.UserName))
+	throwFail(t, AssertIs(nu.Email, u.Email)) // should contain the value in the table, not the one specified above
+	throwFail(t, AssertIs(nu.Password, u.Password))
+	throwFail(t, AssertIs(nu.Status, u.Status))
+	throwFail(t, AssertIs(nu.IsStaff, u.IsStaff))
+	throwFail(t, AssertIs(nu.IsActive, u.IsActive))
+
+	dORM.Delete(u)
+}
+
+func TestInLine(t *testing.T) {
+	name := "inline"
+	email := "hello@go.com"
+	inline := NewInLine()
+	inline.Name = name
+	inline.Email = email
+
+	id, err := dORM.Insert(inline)
+	throwFail(t, err)
+	throwFail(t, AssertIs(id, 1))
+
+	il := NewInLine()
+	il.ID = 1
+	err = dORM.Read(il)
+	throwFail(t, err)
+
+	throwFail(t, AssertIs(il.Name, name))
+	throwFail(t, AssertIs(il.Email, email))
+	throwFail(t, AssertIs(il.Created.In(DefaultTimeLoc), inline.Created.In(DefaultTimeLoc), testDate))
+	throwFail(t, AssertIs(il.Updated.In(DefaultTimeLoc), inline.Updated.In(DefaultTimeLoc), testDateTime))
+}
+
+func TestInLineOneToOne(t *testing.T) {
+	name := "121"
+	email := "121@go.com"
+	inline := NewInLine()
+	inline.Name = name
+	inline.Email = email
+
+	id, err := dORM.Insert(inline)
+	throwFail(t, err)
+	throwFail(t, AssertIs(id, 2))
+
+	note := "one2one"
+	il121 := NewInLineOneToOne()
+	il121.Note = note
+	il121.InLine = inline
+	_, err = dORM.Insert(il121)
+	throwFail(t, err)
+	throwFail(t, AssertIs(il121.ID, 1))
+
+	il := NewInLineOneToOne()
+	err = dORM.QueryTable(il).Filter("Id", 1).RelatedSel().One(il)
+
+	throwFail(t, err)
+	throwFail(t, AssertIs(il.Note, note))
+	throwFail(t, AssertIs(il.InLine.ID, id))
+	throwFail(t, AssertIs(il.InLine.Name, name))
+	throwFail(t, AssertIs(il.InLine.Email, email))
+
+	rinline := NewInLine()
+	err = dORM.QueryTable(rinline).Filter("InLineOneToOne__Id", 1).One(rinline)
+
+	throwFail(t, err)
+	throwFail(t, AssertIs(rinline.ID, id))
+	throwFail(t, AssertIs(rinline.Name, name))
+	throwFail(t, AssertIs(rinline.Email, email))
+}
+
+func TestIntegerPk(t *testing.T) {
+	its := []IntegerPk{
+		{ID: math.MinInt64, Value: "-"},
+		{ID: 0, Value: "0"},
+		{ID: math.MaxInt64, Value: "+"},
+	}
+
+	num, err := dORM.InsertMulti(len(its), its)
+	throwFail(t, err)
+	throwFail(t, AssertIs(num, len(its)))
+
+	for _, intPk := range its {
+		out := IntegerPk{ID: intPk.ID}
+		err = dORM.Read(&out)
+		throwFail(t, err)
+		throwFail(t, AssertIs(out.Value, intPk.Value))
+	}
+
+	num, err = dORM.InsertMulti(1, []*IntegerPk{{
+		ID: 1, Value: "ok",
+	}})
+	throwFail(t, err)
+	throwFail(t, AssertIs(num, 1))
+}
+
+func TestInsertAuto(t *testing.T) {
+	u := &User{
+		UserName: "autoPre",
+		Email:    "autoPre@gmail.com",
+	}
+
+	id, err := dORM.Insert(u)
+	throwFail(t, err)
+
+	id += 100
+	su := &User{
+		ID:       int(id),
+		UserName: "auto",
+		Email:    "auto@gmail.com",
+	}
+
+	nid, err := dORM.Insert(su)
+	throwFail(t, err)
+	throwFail(t, AssertIs(nid, id))
+
+	users := []User{
+		{ID: int(id + 100), UserName: "auto_100"},
+		{ID: int(id + 110), UserName: "auto_110"},
+		{ID: int(id + 120), UserName: "auto_120"},
+	}
+	num, err := dORM.InsertMulti(100, users)
+	throwFail(t, err)
+	throwFail(t, AssertIs(num, 3))
+
+	u = &User{
+		UserName: "auto_121",
+	}
+
+	nid, err = dORM.Insert(u)
+	throwFail(t, err)
+	throwFail(t, AssertIs(nid, id+120+1))
+}
+
+func TestUintPk(t *testing.T) {
+	name := "go"
+	u := &UintPk{
+		ID:   8,
+		Name: name,
+	}
+
+	created, _, err := dORM.ReadOrCreate(u, "ID")
+	throwFail(t, err)
+	throwFail(t, AssertIs(created, true))
+	throwFail(t, AssertIs(u.Name, name))
+
+	nu := &UintPk{ID: 8}
+	created, pk, err := dORM.ReadOrCreate(nu, "ID")
+	throwFail(t, err)
+	throwFail(t, AssertIs(created, false))
+	throwFail(t, AssertIs(nu.ID, u.ID))
+	throwFail(t, AssertIs(pk, u.ID))
+	throwFail(t, AssertIs(nu.Name, name))
+
+	dORM.Delete(u)
+}
+
+func TestPtrPk(t *testing.T) {
+	parent := &IntegerPk{ID: 10, Value: "10"}
+
+	id, _ := dORM.Insert(parent)
+	if !IsMysql {
+		// MySql does not support last_insert_id in this case: see #2382
+		throwFail(t, AssertIs(id, 10))
+	}
+
+	ptr := PtrPk{ID: parent, Positive: true}
+	num, err := dORM.InsertMulti(2, []PtrPk{ptr})
+	throwFail(t, err)
+	throwFail(t, AssertIs(num, 1))
+	throwFail(t, AssertIs(ptr.ID, parent))
+
+	nptr := &PtrPk{ID: parent}
+	created, pk, err := dORM.ReadOrCreate(nptr, "ID")
+	throwFail(t, err)
+	throwFail(t, AssertIs(created, false))
+	throwFail(t, AssertIs(pk, 10))
+	throwFail(t, AssertIs(nptr.ID, parent))
 	throwFail(t, AssertIs(nptr.Positive, true))
 
 	nptr = &PtrPk{Positive: true}
@@ -2348,11 +4021,6 @@ func TestInsertOrUpdate(t *testing.T) {
 	user2 := User{UserName: "unique_username133", Status: 3, Password: "oo"}
 	dORM.Insert(&user)
 	test := User{UserName: "unique_username133"}
-	fmt.Println(dORM.Driver().Name())
-	if dORM.Driver().Name() == "sqlite3" {
-		fmt.Println("sqlite3 is nonsupport")
-		return
-	}
 	//test1
 	_, err := dORM.InsertOrUpdate(&user1, "user_name")
 	if err != nil {
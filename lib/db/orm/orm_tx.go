@@ -0,0 +1,167 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// TxFunc is the function run in transaction by DoTx, txOrm is bound to the transaction.
+type TxFunc func(txOrm Ormer) error
+
+// TxRetryPolicy retry the whole transaction of DoTxWithRetry which fails with retryable error.
+type TxRetryPolicy struct {
+	// max times of retry after the first try
+	MaxRetries int
+	// wait before the first retry, doubled for every next retry
+	Backoff time.Duration
+	// check whether error is retryable, default is IsRetryableTxError
+	Retryable func(error) bool
+}
+
+// IsRetryableTxError check whether err is a deadlock or serialization failure,
+// which means the transaction could succeed when it is run again.
+// it recognizes the errors of mysql, postgres and sqlite drivers.
+func IsRetryableTxError(err error) bool {
+	for ; err != nil; err = errors.Unwrap(err) {
+		if e, ok := err.(interface{ SQLState() string }); ok && isRetryableSQLState(e.SQLState()) {
+			return true
+		}
+
+		ind := reflect.Indirect(reflect.ValueOf(err))
+		if ind.Kind() != reflect.Struct {
+			continue
+		}
+		// mysql error number, 1213 deadlock, 1205 lock wait timeout
+		if f := ind.FieldByName("Number"); f.IsValid() {
+			switch f.Kind() {
+			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				if n := f.Uint(); n == 1213 || n == 1205 {
+					return true
+				}
+			}
+		}
+		if f := ind.FieldByName("Code"); f.IsValid() {
+			switch f.Kind() {
+			case reflect.String:
+				// postgres sql state
+				if isRetryableSQLState(f.String()) {
+					return true
+				}
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				// sqlite SQLITE_BUSY and SQLITE_LOCKED
+				if n := f.Int(); n == 5 || n == 6 {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// 40001 serialization_failure, 40P01 deadlock_detected
+func isRetryableSQLState(state string) bool {
+	return state == "40001" || state == "40P01"
+}
+
+// run fn in transaction, commit when it returns nil, rollback when it returns error or panics.
+// it runs in a savepoint if o is in transaction already.
+func (o *orm) DoTx(ctx context.Context, opts *sql.TxOptions, fn TxFunc) error {
+	if o.isTx {
+		return o.doSavepoint(ctx, fn)
+	}
+	return o.doTx(ctx, opts, fn)
+}
+
+// run fn in transaction like DoTx, the whole transaction is run again if it fails with retryable error.
+// it runs in a savepoint without retry if o is in transaction already.
+func (o *orm) DoTxWithRetry(ctx context.Context, opts *sql.TxOptions, policy TxRetryPolicy, fn TxFunc) error {
+	if o.isTx {
+		return o.doSavepoint(ctx, fn)
+	}
+
+	retryable := policy.Retryable
+	if retryable == nil {
+		retryable = IsRetryableTxError
+	}
+	backoff := policy.Backoff
+	for i := 0; ; i++ {
+		err := o.doTx(ctx, opts, fn)
+		if err == nil || i >= policy.MaxRetries || !retryable(err) {
+			return err
+		}
+		if backoff > 0 {
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+	}
+}
+
+// begin a transaction on a copy of o and run fn with it.
+func (o *orm) doTx(ctx context.Context, opts *sql.TxOptions, fn TxFunc) (err error) {
+	txOrm := *o
+	if err = txOrm.BeginTx(ctx, opts); err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			txOrm.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			txOrm.Rollback()
+			return
+		}
+		err = txOrm.Commit()
+	}()
+
+	return fn(&txOrm)
+}
+
+// run fn in a new savepoint of the transaction of o.
+func (o *orm) doSavepoint(ctx context.Context, fn TxFunc) (err error) {
+	spOrm := *o
+	spOrm.savepoint++
+	name := fmt.Sprintf("orm_sp_%d", spOrm.savepoint)
+
+	db := newDbQueryCtx(ctx, o.db)
+	if _, err = db.Exec("SAVEPOINT " + name); err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			db.Exec("ROLLBACK TO SAVEPOINT " + name)
+			panic(p)
+		}
+		if err != nil {
+			db.Exec("ROLLBACK TO SAVEPOINT " + name)
+			return
+		}
+		_, err = db.Exec("RELEASE SAVEPOINT " + name)
+	}()
+
+	return fn(&spOrm)
+}
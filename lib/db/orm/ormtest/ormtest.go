@@ -0,0 +1,264 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ormtest is the test harness of orm, it runs the code using orm offline
+// on an in-memory sqlite database, with fixtures and transactions rolled back after each test.
+//
+// for example:
+//
+//	func TestMain(m *testing.M) {
+//		if err := ormtest.Setup("default", new(User), new(Post)); err != nil {
+//			panic(err)
+//		}
+//		os.Exit(m.Run())
+//	}
+//
+//	func TestPost(t *testing.T) {
+//		o := ormtest.Fixtures(t, "default", "testdata/users.yaml", "testdata/posts.yaml")
+//		// use o in the code under test, the changes are rolled back after the test
+//	}
+package ormtest
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gopherchai/contrib/lib/db/orm"
+	// sqlite driver of the in-memory database
+	_ "github.com/mattn/go-sqlite3"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	// connections keeping the in-memory databases alive, by alias name.
+	conns = make(map[string]*sql.Conn)
+	// types of the models registered by the first Setup, which bootstraps the models.
+	registered map[reflect.Type]bool
+)
+
+// Setup register alias as an in-memory sqlite database, register models and create their tables.
+// the database lives until the test binary exits and is shared by all connections of alias.
+// the models must not be registered before, and Setup should be called once for each alias,
+// usually in TestMain. the models are registered by the first Setup, the later Setup of other aliases
+// create the tables of all registered models and their models must be registered already.
+func Setup(aliasName string, models ...interface{}) error {
+	if registered != nil {
+		for _, md := range models {
+			if typ := reflect.TypeOf(md); !registered[typ] {
+				return fmt.Errorf("ormtest: model `%s` must be registered by the first Setup", typ)
+			}
+		}
+	}
+	dsn := fmt.Sprintf("file:ormtest_%s?mode=memory&cache=shared", aliasName)
+	if err := orm.RegisterDataBase(aliasName, "sqlite3", dsn); err != nil {
+		return err
+	}
+	db, err := orm.GetDB(aliasName)
+	if err != nil {
+		return err
+	}
+	// the in-memory database is dropped when its last connection closes
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		return err
+	}
+	conns[aliasName] = conn
+	if registered == nil {
+		registered = make(map[reflect.Type]bool)
+		for _, md := range models {
+			registered[reflect.TypeOf(md)] = true
+		}
+		orm.RegisterModel(models...)
+	}
+	return orm.RunSyncdb(aliasName, true, false)
+}
+
+// Begin return an Ormer using alias in a transaction, which is rolled back when t finishes.
+// the tests writing in transactions of the same alias should not run in parallel,
+// sqlite locks the database in a write transaction.
+func Begin(t testing.TB, aliasName string) orm.Ormer {
+	t.Helper()
+	o := orm.NewOrm()
+	if err := o.Using(aliasName); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := o.Rollback(); err != nil {
+			t.Error(err)
+		}
+	})
+	return o
+}
+
+// Fixtures begin a transaction like Begin and load the fixture files in it.
+func Fixtures(t testing.TB, aliasName string, paths ...string) orm.Ormer {
+	t.Helper()
+	o := Begin(t, aliasName)
+	if err := LoadFixtures(o, paths...); err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+// LoadFixtures insert the rows of fixture files by o.
+// a fixture file is YAML (.yaml, .yml) or JSON (.json), mapping table names to rows of columns:
+//
+//	user:
+//	  - id: 1
+//	    user_name: slene
+//	post:
+//	  - id: 1
+//	    user_id: 1
+//	    title: hello
+//
+// the tables of all files are inserted in the order of foreign keys between the registered models,
+// the referenced tables first. map and slice values are stored as JSON.
+func LoadFixtures(o orm.Ormer, paths ...string) error {
+	tables := make(map[string][]map[string]interface{})
+	for _, path := range paths {
+		fixture, err := readFixture(path)
+		if err != nil {
+			return err
+		}
+		for table, rows := range fixture {
+			tables[table] = append(tables[table], rows...)
+		}
+	}
+	names := make([]string, 0, len(tables))
+	for table := range tables {
+		names = append(names, table)
+	}
+	sort.Strings(names)
+	for _, table := range orm.SortTablesByRelation(names) {
+		for i, row := range tables[table] {
+			if err := insertRow(o, table, row); err != nil {
+				return fmt.Errorf("ormtest: fixture table `%s` row %d, %s", table, i, err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// read the rows of fixture file by table name.
+func readFixture(path string) (map[string][]map[string]interface{}, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fixture map[string][]map[string]interface{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &fixture)
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		err = dec.Decode(&fixture)
+	default:
+		return nil, fmt.Errorf("ormtest: unsupported fixture file `%s`", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ormtest: fixture file `%s`, %s", path, err.Error())
+	}
+	return fixture, nil
+}
+
+// insert row into table, the columns are sorted to keep the sql stable.
+func insertRow(o orm.Ormer, table string, row map[string]interface{}) error {
+	qb, err := orm.NewQueryBuilder(queryBuilderDriver(o.Driver().Type()))
+	if err != nil {
+		return err
+	}
+	cols := make([]string, 0, len(row))
+	for col := range row {
+		cols = append(cols, col)
+	}
+	sort.Strings(cols)
+	marks := make([]string, len(cols))
+	args := make([]interface{}, len(cols))
+	for i, col := range cols {
+		marks[i] = "?"
+		if args[i], err = fixtureValue(row[col]); err != nil {
+			return fmt.Errorf("column `%s`, %s", col, err.Error())
+		}
+	}
+	_, err = o.Raw(qb.InsertInto(table, cols...).Values(marks...).String(), args...).Exec()
+	return err
+}
+
+func queryBuilderDriver(t orm.DriverType) string {
+	switch t {
+	case orm.DRMySQL:
+		return "mysql"
+	case orm.DRPostgres:
+		return "postgres"
+	case orm.DRTiDB:
+		return "tidb"
+	}
+	return "sqlite"
+}
+
+// convert the decoded fixture value to the arg of column.
+func fixtureValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		return v.Float64()
+	case map[string]interface{}, map[interface{}]interface{}, []interface{}:
+		data, err := json.Marshal(jsonValue(v))
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	}
+	return value, nil
+}
+
+// convert the maps decoded by yaml to the maps of string keys, which can be marshaled to JSON.
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = jsonValue(e)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = jsonValue(e)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, e := range v {
+			s[i] = jsonValue(e)
+		}
+		return s
+	}
+	return value
+}
@@ -0,0 +1,127 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ormtest
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/gopherchai/contrib/lib/db/orm"
+)
+
+type Shelf struct {
+	Id   int
+	Name string `orm:"size(30)"`
+	Tags string `orm:"type(text)"`
+}
+
+type Book struct {
+	Id    int
+	Shelf *Shelf `orm:"rel(fk)"`
+	Title string `orm:"size(100)"`
+	Price float64
+}
+
+func TestMain(m *testing.M) {
+	if err := Setup("default", new(Book), new(Shelf)); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func TestSortTablesByRelation(t *testing.T) {
+	tables := orm.SortTablesByRelation([]string{"book", "other", "shelf"})
+	if want := []string{"shelf", "book", "other"}; !reflect.DeepEqual(tables, want) {
+		t.Fatalf("tables %v, want %v", tables, want)
+	}
+}
+
+func TestFixtures(t *testing.T) {
+	t.Run("load", func(t *testing.T) {
+		o := Fixtures(t, "default", "testdata/books.json", "testdata/shelves.yaml")
+
+		var books []*Book
+		num, err := o.QueryTable("book").RelatedSel().OrderBy("Id").All(&books)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if num != 2 {
+			t.Fatalf("books %d, want 2", num)
+		}
+		if books[0].Title != "The Go Programming Language" || books[0].Price != 35.5 {
+			t.Fatalf("wrong book %+v", books[0])
+		}
+		if books[1].Shelf.Name != "golang" || books[1].Shelf.Tags != `["go","orm"]` {
+			t.Fatalf("wrong shelf %+v", books[1].Shelf)
+		}
+
+		if _, err := o.Insert(&Book{Shelf: books[0].Shelf, Title: "Go Web"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	// the rows of fixtures and test are rolled back
+	o := orm.NewOrm()
+	for _, table := range []string{"book", "shelf"} {
+		num, err := o.QueryTable(table).Count()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if num != 0 {
+			t.Fatalf("table %s has %d rows after rollback", table, num)
+		}
+	}
+}
+
+func TestSetupAliases(t *testing.T) {
+	if err := Setup("second", new(Book)); err != nil {
+		t.Fatal(err)
+	}
+	o := Fixtures(t, "second", "testdata/books.json", "testdata/shelves.yaml")
+	num, err := o.QueryTable("book").Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if num != 2 {
+		t.Fatalf("books %d, want 2", num)
+	}
+
+	// the tables of alias default are not touched
+	num, err = orm.NewOrm().QueryTable("book").Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if num != 0 {
+		t.Fatalf("books of default %d, want 0", num)
+	}
+
+	type Other struct {
+		Id int
+	}
+	if err := Setup("third", new(Other)); err == nil {
+		t.Fatal("setup with new model after the first setup should fail")
+	}
+}
+
+func TestLoadFixturesError(t *testing.T) {
+	o := Begin(t, "default")
+	if err := LoadFixtures(o, "testdata/missing.yaml"); err == nil {
+		t.Fatal("load missing fixture file should fail")
+	}
+	if err := LoadFixtures(o, "ormtest_test.go"); err == nil {
+		t.Fatal("load unsupported fixture file should fail")
+	}
+}
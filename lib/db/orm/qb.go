@@ -14,7 +14,12 @@
 
 package orm
 
-import "errors"
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
 
 // QueryBuilder is the Query builder interface
 type QueryBuilder interface {
@@ -45,6 +50,12 @@ type QueryBuilder interface {
 	String() string
 }
 
+// ReturningQueryBuilder is the QueryBuilder of dialects supporting RETURNING clause
+type ReturningQueryBuilder interface {
+	QueryBuilder
+	Returning(fields ...string) QueryBuilder
+}
+
 // NewQueryBuilder return the QueryBuilder
 func NewQueryBuilder(driver string) (qb QueryBuilder, err error) {
 	if driver == "mysql" {
@@ -52,11 +63,159 @@ func NewQueryBuilder(driver string) (qb QueryBuilder, err error) {
 	} else if driver == "tidb" {
 		qb = new(TiDBQueryBuilder)
 	} else if driver == "postgres" {
-		err = errors.New("postgres query builder is not supported yet")
-	} else if driver == "sqlite" {
-		err = errors.New("sqlite query builder is not supported yet")
+		qb = newPostgresQueryBuilder()
+	} else if driver == "sqlite" || driver == "sqlite3" {
+		qb = newSQLiteQueryBuilder()
 	} else {
 		err = errors.New("unknown driver for query builder")
 	}
 	return
 }
+
+var qbIdentRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.([A-Za-z_][A-Za-z0-9_]*|\*))?$`)
+
+// quote the identifier name with q, name can be table, column, table.column or table.*
+// with an optional alias "user u", "user AS u" or order "name DESC".
+// expressions like COUNT(*) are returned untouched.
+func qbQuote(q string, name string) string {
+	parts := strings.Fields(name)
+	switch {
+	case len(parts) == 1 && qbIdentRe.MatchString(name):
+		names := strings.Split(name, ".")
+		for i, n := range names {
+			if n != "*" {
+				names[i] = q + n + q
+			}
+		}
+		return strings.Join(names, ".")
+	case len(parts) == 2 && qbIdentRe.MatchString(parts[0]) && qbIdentRe.MatchString(parts[1]):
+		if strings.EqualFold(parts[0], "DISTINCT") {
+			return name
+		}
+		if strings.EqualFold(parts[1], "ASC") || strings.EqualFold(parts[1], "DESC") {
+			return qbQuote(q, parts[0]) + " " + strings.ToUpper(parts[1])
+		}
+		return qbQuote(q, parts[0]) + " " + q + parts[1] + q
+	case len(parts) == 3 && qbIdentRe.MatchString(parts[0]) && strings.EqualFold(parts[1], "AS") && qbIdentRe.MatchString(parts[2]):
+		return qbQuote(q, parts[0]) + " AS " + q + parts[2] + q
+	}
+	return name
+}
+
+// quote all identifier names with q and join them.
+func qbQuoteJoin(q string, names []string) string {
+	quoted := make([]string, 0, len(names))
+	for _, name := range names {
+		quoted = append(quoted, qbQuote(q, name))
+	}
+	return strings.Join(quoted, CommaSpace)
+}
+
+// quote the columns of an equality join condition like "user.id = profile.user_id" with q.
+// other conditions are returned untouched.
+func qbQuoteOn(q string, cond string) string {
+	sides := strings.Split(cond, "=")
+	if len(sides) != 2 {
+		return cond
+	}
+	left, right := strings.TrimSpace(sides[0]), strings.TrimSpace(sides[1])
+	if !qbIdentRe.MatchString(left) || !qbIdentRe.MatchString(right) {
+		return cond
+	}
+	return qbQuote(q, left) + " = " + qbQuote(q, right)
+}
+
+// number the ? placeholders outside of string literals and quoted names as $n in order of appearance,
+// the $n written in query are kept and the ? are numbered after the largest of them.
+// ?? is the escaped ? kept as it is, Ormer.Raw turns it into ?, like the jsonb operators attrs ?? 'color'.
+func qbDollarMarks(query string) string {
+	num := 1
+	for _, n := range qbDollarNums(query) {
+		if n >= num {
+			num = n + 1
+		}
+	}
+	data := make([]byte, 0, len(query))
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '?' && i+1 < len(query) && query[i+1] == '?':
+			data = append(data, c)
+			i++
+		case c == '?':
+			data = append(data, '$')
+			data = append(data, strconv.Itoa(num)...)
+			num++
+			continue
+		}
+		data = append(data, c)
+	}
+	return string(data)
+}
+
+// turn the $1, $2... numbered by qbDollarMarks back to ?, so a subquery is numbered with the query embedding it.
+// the query with other $n, like $1 used twice, is returned untouched.
+func qbQuestionMarks(query string) string {
+	nums := qbDollarNums(query)
+	if len(nums) == 0 {
+		return query
+	}
+	for i, n := range nums {
+		if n != i+1 {
+			return query
+		}
+	}
+	data := make([]byte, 0, len(query))
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '$' && i+1 < len(query) && query[i+1] >= '0' && query[i+1] <= '9':
+			for i+1 < len(query) && query[i+1] >= '0' && query[i+1] <= '9' {
+				i++
+			}
+			c = '?'
+		}
+		data = append(data, c)
+	}
+	return string(data)
+}
+
+// get the numbers of $n placeholders outside of string literals and quoted names in order of appearance.
+func qbDollarNums(query string) []int {
+	var nums []int
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '$' && i+1 < len(query) && query[i+1] >= '0' && query[i+1] <= '9':
+			j := i + 1
+			for j < len(query) && query[j] >= '0' && query[j] <= '9' {
+				j++
+			}
+			n, _ := strconv.Atoi(query[i+1 : j])
+			nums = append(nums, n)
+			i = j - 1
+		}
+	}
+	return nums
+}
@@ -0,0 +1,531 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// sql dialect of SQLBuilder.
+type qbDialect struct {
+	quote     string
+	dollar    bool   // number placeholders as $n
+	maxLimit  string // LIMIT used when only OFFSET is set, empty means OFFSET can be used alone
+	forUpdate bool
+}
+
+var qbDialects = map[string]*qbDialect{
+	"mysql":    {quote: "`", maxLimit: "18446744073709551615", forUpdate: true},
+	"tidb":     {quote: "`", maxLimit: "18446744073709551615", forUpdate: true},
+	"postgres": {quote: `"`, dollar: true, forUpdate: true},
+	"sqlite":   {quote: `"`, maxLimit: "-1"},
+	"sqlite3":  {quote: `"`, maxLimit: "-1"},
+}
+
+// sql fragment with bound values.
+type qbExpr struct {
+	sql  string
+	args []interface{}
+}
+
+// SQLCond is a condition of SQLBuilder carrying its values.
+// conditions can be nested with AllOf, AnyOf and Not.
+type SQLCond struct {
+	expr  qbExpr
+	op    string
+	conds []*SQLCond
+}
+
+// Expr create condition from sql with ? marks and the values of marks.
+// a *SQLBuilder value is embedded as subquery, its mark is replaced by (subquery).
+func Expr(cond string, args ...interface{}) *SQLCond {
+	return &SQLCond{expr: qbExpr{cond, args}}
+}
+
+// In create the condition field IN (vals...).
+// vals can be a single slice of values, or a single *SQLBuilder used as subquery.
+func In(field string, vals ...interface{}) *SQLCond {
+	return qbIn(field, "IN", vals)
+}
+
+// NotIn create the condition field NOT IN (vals...).
+// vals can be a single slice of values, or a single *SQLBuilder used as subquery.
+func NotIn(field string, vals ...interface{}) *SQLCond {
+	return qbIn(field, "NOT IN", vals)
+}
+
+func qbIn(field, op string, vals []interface{}) *SQLCond {
+	if len(vals) == 1 {
+		val := reflect.ValueOf(vals[0])
+		if val.Kind() == reflect.Slice && val.Type().Elem().Kind() != reflect.Uint8 {
+			vals = make([]interface{}, 0, val.Len())
+			for i := 0; i < val.Len(); i++ {
+				vals = append(vals, val.Index(i).Interface())
+			}
+		}
+	}
+	c := &SQLCond{op: op}
+	c.expr.sql = field
+	c.expr.args = vals
+	return c
+}
+
+// AllOf group conditions with AND.
+func AllOf(conds ...*SQLCond) *SQLCond {
+	return &SQLCond{op: "AND", conds: conds}
+}
+
+// AnyOf group conditions with OR.
+func AnyOf(conds ...*SQLCond) *SQLCond {
+	return &SQLCond{op: "OR", conds: conds}
+}
+
+// Not negate the condition.
+func Not(cond *SQLCond) *SQLCond {
+	return &SQLCond{op: "NOT", conds: []*SQLCond{cond}}
+}
+
+// build condition sql with ? marks.
+func (c *SQLCond) build(d *qbDialect) (string, []interface{}) {
+	switch c.op {
+	case "AND", "OR":
+		var (
+			sqls []string
+			args []interface{}
+		)
+		for _, cond := range c.conds {
+			if cond == nil {
+				continue
+			}
+			sql, a := cond.build(d)
+			if sql == "" {
+				continue
+			}
+			if len(c.conds) > 1 && cond.op != "IN" && cond.op != "NOT IN" && cond.op != "NOT" {
+				sql = "(" + sql + ")"
+			}
+			sqls = append(sqls, sql)
+			args = append(args, a...)
+		}
+		return strings.Join(sqls, " "+c.op+" "), args
+	case "NOT":
+		sql, args := c.conds[0].build(d)
+		return "NOT (" + sql + ")", args
+	case "IN", "NOT IN":
+		field := qbQuote(d.quote, c.expr.sql)
+		if len(c.expr.args) == 1 {
+			if sub, ok := c.expr.args[0].(*SQLBuilder); ok {
+				sql, args := sub.build()
+				return fmt.Sprintf("%s %s (%s)", field, c.op, sql), args
+			}
+		}
+		if len(c.expr.args) == 0 {
+			// IN () is invalid sql
+			if c.op == "IN" {
+				return "1 = 0", nil
+			}
+			return "1 = 1", nil
+		}
+		marks := strings.TrimSuffix(strings.Repeat("?, ", len(c.expr.args)), ", ")
+		return fmt.Sprintf("%s %s (%s)", field, c.op, marks), c.expr.args
+	}
+	return qbBind(c.expr.sql, c.expr.args)
+}
+
+// embed the *SQLBuilder values of args as subqueries of sql.
+func qbBind(sql string, args []interface{}) (string, []interface{}) {
+	hasSub := false
+	for _, arg := range args {
+		if _, ok := arg.(*SQLBuilder); ok {
+			hasSub = true
+			break
+		}
+	}
+	if !hasSub {
+		return sql, args
+	}
+
+	var (
+		data  = make([]byte, 0, len(sql))
+		binds = make([]interface{}, 0, len(args))
+		n     = 0
+		inStr = false
+	)
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		if c == '\'' {
+			inStr = !inStr
+		} else if c == '?' && !inStr && i+1 < len(sql) && sql[i+1] == '?' {
+			// the escaped ?
+			data = append(data, "??"...)
+			i++
+			continue
+		} else if c == '?' && !inStr && n < len(args) {
+			if sub, ok := args[n].(*SQLBuilder); ok {
+				s, a := sub.build()
+				data = append(data, "("+s+")"...)
+				binds = append(binds, a...)
+			} else {
+				data = append(data, c)
+				binds = append(binds, args[n])
+			}
+			n++
+			continue
+		}
+		data = append(data, c)
+	}
+	return string(data), append(binds, args[n:]...)
+}
+
+// SQLBuilder build parameterized sql for a dialect.
+// values are bound to the conditions where they are used and returned by ToSQL in order,
+// so the result can be passed straight to Ormer.Raw:
+//
+//	qb, _ := orm.NewSQLBuilder("postgres")
+//	qb.Select("id", "name").From("user").Where("age > ?", 18).And(orm.In("status", 1, 2))
+//	o.Raw(qb.ToSQL()).QueryRows(&users)
+type SQLBuilder struct {
+	dialect   *qbDialect
+	op        string
+	fields    []string
+	table     string
+	from      []qbExpr
+	joins     []qbExpr
+	where     *SQLCond
+	groupBy   []string
+	having    *SQLCond
+	orderBy   []string
+	limit     int
+	offset    int
+	forUpdate bool
+	columns   []string
+	values    [][]interface{}
+	sets      []qbExpr
+	returning []string
+}
+
+// NewSQLBuilder return the SQLBuilder of driver, which is mysql, tidb, postgres or sqlite.
+func NewSQLBuilder(driver string) (*SQLBuilder, error) {
+	d, ok := qbDialects[driver]
+	if !ok {
+		return nil, errors.New("unknown driver for query builder")
+	}
+	return &SQLBuilder{dialect: d, limit: -1, offset: -1}, nil
+}
+
+// New create a builder of the same dialect, use it to build subqueries.
+func (qb *SQLBuilder) New() *SQLBuilder {
+	return &SQLBuilder{dialect: qb.dialect, limit: -1, offset: -1}
+}
+
+// Select start a SELECT statement of fields.
+func (qb *SQLBuilder) Select(fields ...string) *SQLBuilder {
+	qb.op = "SELECT"
+	qb.fields = fields
+	return qb
+}
+
+// From set the tables of SELECT.
+func (qb *SQLBuilder) From(tables ...string) *SQLBuilder {
+	for _, table := range tables {
+		qb.from = append(qb.from, qbExpr{sql: qbQuote(qb.dialect.quote, table)})
+	}
+	return qb
+}
+
+// FromSub select from the subquery as alias.
+func (qb *SQLBuilder) FromSub(sub *SQLBuilder, alias string) *SQLBuilder {
+	sql, args := sub.build()
+	qb.from = append(qb.from, qbExpr{fmt.Sprintf("(%s) AS %s", sql, qbQuote(qb.dialect.quote, alias)), args})
+	return qb
+}
+
+func (qb *SQLBuilder) join(typ, table string) *SQLBuilder {
+	qb.joins = append(qb.joins, qbExpr{sql: typ + " " + qbQuote(qb.dialect.quote, table)})
+	return qb
+}
+
+// InnerJoin INNER JOIN the table.
+func (qb *SQLBuilder) InnerJoin(table string) *SQLBuilder {
+	return qb.join("INNER JOIN", table)
+}
+
+// LeftJoin LEFT JOIN the table.
+func (qb *SQLBuilder) LeftJoin(table string) *SQLBuilder {
+	return qb.join("LEFT JOIN", table)
+}
+
+// RightJoin RIGHT JOIN the table.
+func (qb *SQLBuilder) RightJoin(table string) *SQLBuilder {
+	return qb.join("RIGHT JOIN", table)
+}
+
+// On set the condition of the last join.
+func (qb *SQLBuilder) On(cond string, args ...interface{}) *SQLBuilder {
+	if len(qb.joins) == 0 {
+		panic(fmt.Errorf("<SQLBuilder.On> need a join before"))
+	}
+	j := &qb.joins[len(qb.joins)-1]
+	sql, args := qbBind(cond, args)
+	if len(args) == 0 {
+		sql = qbQuoteOn(qb.dialect.quote, sql)
+	}
+	j.sql += " ON " + sql
+	j.args = append(j.args, args...)
+	return qb
+}
+
+// make condition of string with args or *SQLCond.
+func qbCond(method string, cond interface{}, args []interface{}) *SQLCond {
+	switch c := cond.(type) {
+	case string:
+		return Expr(c, args...)
+	case *SQLCond:
+		if len(args) > 0 {
+			panic(fmt.Errorf("<SQLBuilder.%s> args cannot be used with *SQLCond", method))
+		}
+		return c
+	}
+	panic(fmt.Errorf("<SQLBuilder.%s> cond need string or *SQLCond, but get %T", method, cond))
+}
+
+// Where set the WHERE condition, cond is sql with ? marks followed by values, or a *SQLCond.
+func (qb *SQLBuilder) Where(cond interface{}, args ...interface{}) *SQLBuilder {
+	qb.where = qbCond("Where", cond, args)
+	return qb
+}
+
+// And add condition to WHERE with AND.
+func (qb *SQLBuilder) And(cond interface{}, args ...interface{}) *SQLBuilder {
+	c := qbCond("And", cond, args)
+	if qb.where == nil {
+		qb.where = c
+	} else if qb.where.op == "AND" {
+		qb.where = AllOf(append(append([]*SQLCond{}, qb.where.conds...), c)...)
+	} else {
+		qb.where = AllOf(qb.where, c)
+	}
+	return qb
+}
+
+// Or add condition to WHERE with OR.
+func (qb *SQLBuilder) Or(cond interface{}, args ...interface{}) *SQLBuilder {
+	c := qbCond("Or", cond, args)
+	if qb.where == nil {
+		qb.where = c
+	} else if qb.where.op == "OR" {
+		qb.where = AnyOf(append(append([]*SQLCond{}, qb.where.conds...), c)...)
+	} else {
+		qb.where = AnyOf(qb.where, c)
+	}
+	return qb
+}
+
+// In add condition field IN (vals...) to WHERE with AND.
+func (qb *SQLBuilder) In(field string, vals ...interface{}) *SQLBuilder {
+	return qb.And(In(field, vals...))
+}
+
+// GroupBy set the GROUP BY fields.
+func (qb *SQLBuilder) GroupBy(fields ...string) *SQLBuilder {
+	qb.groupBy = append(qb.groupBy, fields...)
+	return qb
+}
+
+// Having set the HAVING condition, cond is sql with ? marks followed by values, or a *SQLCond.
+func (qb *SQLBuilder) Having(cond interface{}, args ...interface{}) *SQLBuilder {
+	qb.having = qbCond("Having", cond, args)
+	return qb
+}
+
+// OrderBy add ORDER BY fields, a field can end with ASC or DESC.
+func (qb *SQLBuilder) OrderBy(fields ...string) *SQLBuilder {
+	qb.orderBy = append(qb.orderBy, fields...)
+	return qb
+}
+
+// Limit set the LIMIT.
+func (qb *SQLBuilder) Limit(limit int) *SQLBuilder {
+	qb.limit = limit
+	return qb
+}
+
+// Offset set the OFFSET.
+func (qb *SQLBuilder) Offset(offset int) *SQLBuilder {
+	qb.offset = offset
+	return qb
+}
+
+// ForUpdate lock the selected rows, ignored by sqlite.
+func (qb *SQLBuilder) ForUpdate() *SQLBuilder {
+	qb.forUpdate = true
+	return qb
+}
+
+// InsertInto start an INSERT statement of columns into table.
+func (qb *SQLBuilder) InsertInto(table string, columns ...string) *SQLBuilder {
+	qb.op = "INSERT"
+	qb.table = table
+	qb.columns = columns
+	return qb
+}
+
+// Values add a row of values to INSERT, call it again to insert multiple rows.
+func (qb *SQLBuilder) Values(vals ...interface{}) *SQLBuilder {
+	qb.values = append(qb.values, vals)
+	return qb
+}
+
+// Update start an UPDATE statement of table.
+func (qb *SQLBuilder) Update(table string) *SQLBuilder {
+	qb.op = "UPDATE"
+	qb.table = table
+	return qb
+}
+
+// Set the column to value in UPDATE, a *SQLBuilder value is used as subquery.
+func (qb *SQLBuilder) Set(column string, value interface{}) *SQLBuilder {
+	sql, args := qbBind("?", []interface{}{value})
+	qb.sets = append(qb.sets, qbExpr{qbQuote(qb.dialect.quote, column) + " = " + sql, args})
+	return qb
+}
+
+// SetExpr set the column to the sql with ? marks in UPDATE, like SetExpr("count", "count + ?", 1).
+func (qb *SQLBuilder) SetExpr(column string, expr string, args ...interface{}) *SQLBuilder {
+	sql, args := qbBind(expr, args)
+	qb.sets = append(qb.sets, qbExpr{qbQuote(qb.dialect.quote, column) + " = " + sql, args})
+	return qb
+}
+
+// Delete start a DELETE statement of table.
+func (qb *SQLBuilder) Delete(table string) *SQLBuilder {
+	qb.op = "DELETE"
+	qb.table = table
+	return qb
+}
+
+// Returning set the RETURNING fields of INSERT, UPDATE and DELETE,
+// it is supported by postgres and sqlite 3.35.0 or later.
+func (qb *SQLBuilder) Returning(fields ...string) *SQLBuilder {
+	qb.returning = fields
+	return qb
+}
+
+// append the conditions with the keyword.
+func (qb *SQLBuilder) buildCond(tokens []string, args []interface{}, keyword string, cond *SQLCond) ([]string, []interface{}) {
+	if cond == nil {
+		return tokens, args
+	}
+	sql, a := cond.build(qb.dialect)
+	if sql == "" {
+		return tokens, args
+	}
+	return append(tokens, keyword, sql), append(args, a...)
+}
+
+// build sql with ? marks.
+func (qb *SQLBuilder) build() (string, []interface{}) {
+	var (
+		tokens []string
+		args   []interface{}
+		Q      = qb.dialect.quote
+	)
+
+	switch qb.op {
+	case "SELECT":
+		tokens = append(tokens, "SELECT", qbQuoteJoin(Q, qb.fields))
+		if len(qb.from) > 0 {
+			from := make([]string, 0, len(qb.from))
+			for _, f := range qb.from {
+				from = append(from, f.sql)
+				args = append(args, f.args...)
+			}
+			tokens = append(tokens, "FROM", strings.Join(from, CommaSpace))
+		}
+		for _, j := range qb.joins {
+			tokens = append(tokens, j.sql)
+			args = append(args, j.args...)
+		}
+	case "INSERT":
+		tokens = append(tokens, "INSERT INTO", qbQuote(Q, qb.table))
+		if len(qb.columns) > 0 {
+			tokens = append(tokens, "("+qbQuoteJoin(Q, qb.columns)+")")
+		}
+		rows := make([]string, 0, len(qb.values))
+		for _, vals := range qb.values {
+			marks := make([]string, 0, len(vals))
+			for _, val := range vals {
+				sql, a := qbBind("?", []interface{}{val})
+				marks = append(marks, sql)
+				args = append(args, a...)
+			}
+			rows = append(rows, "("+strings.Join(marks, CommaSpace)+")")
+		}
+		tokens = append(tokens, "VALUES", strings.Join(rows, CommaSpace))
+	case "UPDATE":
+		tokens = append(tokens, "UPDATE", qbQuote(Q, qb.table))
+		sets := make([]string, 0, len(qb.sets))
+		for _, s := range qb.sets {
+			sets = append(sets, s.sql)
+			args = append(args, s.args...)
+		}
+		tokens = append(tokens, "SET", strings.Join(sets, CommaSpace))
+	case "DELETE":
+		tokens = append(tokens, "DELETE FROM", qbQuote(Q, qb.table))
+	default:
+		panic(fmt.Errorf("<SQLBuilder> need Select, InsertInto, Update or Delete"))
+	}
+
+	tokens, args = qb.buildCond(tokens, args, "WHERE", qb.where)
+
+	if len(qb.groupBy) > 0 {
+		tokens = append(tokens, "GROUP BY", qbQuoteJoin(Q, qb.groupBy))
+	}
+	tokens, args = qb.buildCond(tokens, args, "HAVING", qb.having)
+	if len(qb.orderBy) > 0 {
+		tokens = append(tokens, "ORDER BY", qbQuoteJoin(Q, qb.orderBy))
+	}
+
+	if qb.limit >= 0 {
+		tokens = append(tokens, "LIMIT", strconv.Itoa(qb.limit))
+	} else if qb.offset >= 0 && qb.dialect.maxLimit != "" {
+		tokens = append(tokens, "LIMIT", qb.dialect.maxLimit)
+	}
+	if qb.offset >= 0 {
+		tokens = append(tokens, "OFFSET", strconv.Itoa(qb.offset))
+	}
+
+	if qb.forUpdate && qb.dialect.forUpdate {
+		tokens = append(tokens, "FOR UPDATE")
+	}
+	if len(qb.returning) > 0 {
+		tokens = append(tokens, "RETURNING", qbQuoteJoin(Q, qb.returning))
+	}
+
+	return strings.Join(tokens, " "), args
+}
+
+// ToSQL return the sql of the dialect and its values in order.
+func (qb *SQLBuilder) ToSQL() (string, []interface{}) {
+	sql, args := qb.build()
+	if qb.dialect.dollar {
+		sql = qbDollarMarks(sql)
+	}
+	return sql, args
+}
@@ -0,0 +1,213 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// the QueryBuilder quoting table and column names by the dialect,
+// it is shared by PostgresQueryBuilder and SQLiteQueryBuilder.
+type dialectQueryBuilder struct {
+	Tokens  []string
+	dialect *qbDialect
+	limit   int // index of the LIMIT num in Tokens, zero if no LIMIT is joined
+}
+
+var _ ReturningQueryBuilder = new(dialectQueryBuilder)
+
+// Select will join the fields
+func (qb *dialectQueryBuilder) Select(fields ...string) QueryBuilder {
+	qb.Tokens = append(qb.Tokens, "SELECT", qbQuoteJoin(qb.dialect.quote, fields))
+	return qb
+}
+
+// ForUpdate add the FOR UPDATE clause, it does nothing if the dialect doesn't support it
+func (qb *dialectQueryBuilder) ForUpdate() QueryBuilder {
+	if qb.dialect.forUpdate {
+		qb.Tokens = append(qb.Tokens, "FOR UPDATE")
+	}
+	return qb
+}
+
+// From join the tables
+func (qb *dialectQueryBuilder) From(tables ...string) QueryBuilder {
+	qb.Tokens = append(qb.Tokens, "FROM", qbQuoteJoin(qb.dialect.quote, tables))
+	return qb
+}
+
+// InnerJoin INNER JOIN the table
+func (qb *dialectQueryBuilder) InnerJoin(table string) QueryBuilder {
+	qb.Tokens = append(qb.Tokens, "INNER JOIN", qbQuote(qb.dialect.quote, table))
+	return qb
+}
+
+// LeftJoin LEFT JOIN the table
+func (qb *dialectQueryBuilder) LeftJoin(table string) QueryBuilder {
+	qb.Tokens = append(qb.Tokens, "LEFT JOIN", qbQuote(qb.dialect.quote, table))
+	return qb
+}
+
+// RightJoin RIGHT JOIN the table
+func (qb *dialectQueryBuilder) RightJoin(table string) QueryBuilder {
+	qb.Tokens = append(qb.Tokens, "RIGHT JOIN", qbQuote(qb.dialect.quote, table))
+	return qb
+}
+
+// On join with on cond
+func (qb *dialectQueryBuilder) On(cond string) QueryBuilder {
+	qb.Tokens = append(qb.Tokens, "ON", qbQuoteOn(qb.dialect.quote, cond))
+	return qb
+}
+
+// Where join the Where cond
+func (qb *dialectQueryBuilder) Where(cond string) QueryBuilder {
+	qb.Tokens = append(qb.Tokens, "WHERE", cond)
+	return qb
+}
+
+// And join the and cond
+func (qb *dialectQueryBuilder) And(cond string) QueryBuilder {
+	qb.Tokens = append(qb.Tokens, "AND", cond)
+	return qb
+}
+
+// Or join the or cond
+func (qb *dialectQueryBuilder) Or(cond string) QueryBuilder {
+	qb.Tokens = append(qb.Tokens, "OR", cond)
+	return qb
+}
+
+// In join the IN (vals)
+func (qb *dialectQueryBuilder) In(vals ...string) QueryBuilder {
+	qb.Tokens = append(qb.Tokens, "IN", "(", strings.Join(vals, CommaSpace), ")")
+	return qb
+}
+
+// OrderBy join the Order by fields
+func (qb *dialectQueryBuilder) OrderBy(fields ...string) QueryBuilder {
+	qb.Tokens = append(qb.Tokens, "ORDER BY", qbQuoteJoin(qb.dialect.quote, fields))
+	return qb
+}
+
+// Asc join the asc
+func (qb *dialectQueryBuilder) Asc() QueryBuilder {
+	qb.Tokens = append(qb.Tokens, "ASC")
+	return qb
+}
+
+// Desc join the desc
+func (qb *dialectQueryBuilder) Desc() QueryBuilder {
+	qb.Tokens = append(qb.Tokens, "DESC")
+	return qb
+}
+
+// Limit join the limit num.
+// it replaces the max LIMIT joined by a former Offset call.
+func (qb *dialectQueryBuilder) Limit(limit int) QueryBuilder {
+	if qb.limit > 0 {
+		qb.Tokens[qb.limit] = strconv.Itoa(limit)
+		return qb
+	}
+	qb.Tokens = append(qb.Tokens, "LIMIT", strconv.Itoa(limit))
+	qb.limit = len(qb.Tokens) - 1
+	return qb
+}
+
+// Offset join the offset num.
+// the max LIMIT is joined before if the dialect need LIMIT before OFFSET and Limit is not called.
+func (qb *dialectQueryBuilder) Offset(offset int) QueryBuilder {
+	if qb.limit == 0 && qb.dialect.maxLimit != "" {
+		qb.Tokens = append(qb.Tokens, "LIMIT", qb.dialect.maxLimit)
+		qb.limit = len(qb.Tokens) - 1
+	}
+	qb.Tokens = append(qb.Tokens, "OFFSET", strconv.Itoa(offset))
+	return qb
+}
+
+// GroupBy join the Group by fields
+func (qb *dialectQueryBuilder) GroupBy(fields ...string) QueryBuilder {
+	qb.Tokens = append(qb.Tokens, "GROUP BY", qbQuoteJoin(qb.dialect.quote, fields))
+	return qb
+}
+
+// Having join the Having cond
+func (qb *dialectQueryBuilder) Having(cond string) QueryBuilder {
+	qb.Tokens = append(qb.Tokens, "HAVING", cond)
+	return qb
+}
+
+// Update join the update table
+func (qb *dialectQueryBuilder) Update(tables ...string) QueryBuilder {
+	qb.Tokens = append(qb.Tokens, "UPDATE", qbQuoteJoin(qb.dialect.quote, tables))
+	return qb
+}
+
+// Set join the set kv
+func (qb *dialectQueryBuilder) Set(kv ...string) QueryBuilder {
+	qb.Tokens = append(qb.Tokens, "SET", strings.Join(kv, CommaSpace))
+	return qb
+}
+
+// Delete join the Delete tables.
+// only one table can be deleted from, Delete("user") is the same as Delete().From("user")
+func (qb *dialectQueryBuilder) Delete(tables ...string) QueryBuilder {
+	qb.Tokens = append(qb.Tokens, "DELETE")
+	if len(tables) != 0 {
+		qb.Tokens = append(qb.Tokens, "FROM", qbQuoteJoin(qb.dialect.quote, tables))
+	}
+	return qb
+}
+
+// InsertInto join the insert SQL
+func (qb *dialectQueryBuilder) InsertInto(table string, fields ...string) QueryBuilder {
+	qb.Tokens = append(qb.Tokens, "INSERT INTO", qbQuote(qb.dialect.quote, table))
+	if len(fields) != 0 {
+		qb.Tokens = append(qb.Tokens, "(", qbQuoteJoin(qb.dialect.quote, fields), ")")
+	}
+	return qb
+}
+
+// Values join the Values(vals)
+func (qb *dialectQueryBuilder) Values(vals ...string) QueryBuilder {
+	valsStr := strings.Join(vals, CommaSpace)
+	qb.Tokens = append(qb.Tokens, "VALUES", "(", valsStr, ")")
+	return qb
+}
+
+// Returning join the RETURNING fields of INSERT, UPDATE and DELETE
+func (qb *dialectQueryBuilder) Returning(fields ...string) QueryBuilder {
+	qb.Tokens = append(qb.Tokens, "RETURNING", qbQuoteJoin(qb.dialect.quote, fields))
+	return qb
+}
+
+// Subquery join the sub as alias, the placeholders of sub are numbered with the query if the dialect uses $n
+func (qb *dialectQueryBuilder) Subquery(sub string, alias string) string {
+	if qb.dialect.dollar {
+		sub = qbQuestionMarks(sub)
+	}
+	return fmt.Sprintf("(%s) AS %s", sub, qbQuote(qb.dialect.quote, alias))
+}
+
+// String join all Tokens, the placeholders are numbered if the dialect uses $n
+func (qb *dialectQueryBuilder) String() string {
+	query := strings.Join(qb.Tokens, " ")
+	if qb.dialect.dollar {
+		query = qbDollarMarks(query)
+	}
+	return query
+}
@@ -0,0 +1,26 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+// PostgresQueryBuilder is the SQL build for postgresql, create it by NewQueryBuilder("postgres").
+// table and column names are quoted, ? marks are turned into $n placeholders by String,
+// write ?? for the jsonb operators ?, ?| and ?&, like "attrs ?? 'color'".
+type PostgresQueryBuilder struct {
+	dialectQueryBuilder
+}
+
+func newPostgresQueryBuilder() *PostgresQueryBuilder {
+	return &PostgresQueryBuilder{dialectQueryBuilder{dialect: qbDialects["postgres"]}}
+}
@@ -0,0 +1,27 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+// SQLiteQueryBuilder is the SQL build for sqlite, create it by NewQueryBuilder("sqlite").
+// table and column names are quoted, ForUpdate does nothing as sqlite locks the whole database
+// in write transaction, and Offset without Limit joins LIMIT -1 as sqlite need LIMIT before OFFSET.
+// RightJoin need sqlite 3.39.0 and Returning need sqlite 3.35.0 or later.
+type SQLiteQueryBuilder struct {
+	dialectQueryBuilder
+}
+
+func newSQLiteQueryBuilder() *SQLiteQueryBuilder {
+	return &SQLiteQueryBuilder{dialectQueryBuilder{dialect: qbDialects["sqlite"]}}
+}
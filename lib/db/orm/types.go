@@ -46,26 +46,49 @@ type Ormer interface {
 	// 	u = &User{UserName: "astaxie", Password: "pass"}
 	//	err = Ormer.Read(u, "UserName")
 	Read(md interface{}, cols ...string) error
+	// like Read(), but the statement is bound to ctx,
+	// canceling ctx or reaching its deadline aborts the query.
+	ReadWithCtx(ctx context.Context, md interface{}, cols ...string) error
 	// Like Read(), but with "FOR UPDATE" clause, useful in transaction.
 	// Some databases are not support this feature.
 	ReadForUpdate(md interface{}, cols ...string) error
+	ReadForUpdateWithCtx(ctx context.Context, md interface{}, cols ...string) error
 	// Try to read a row from the database, or insert one if it doesn't exist
 	ReadOrCreate(md interface{}, col1 string, cols ...string) (bool, int64, error)
+	ReadOrCreateWithCtx(ctx context.Context, md interface{}, col1 string, cols ...string) (bool, int64, error)
 	// insert model data to database
 	// for example:
 	//  user := new(User)
 	//  id, err = Ormer.Insert(user)
 	//  user must a pointer and Insert will set user's pk field
+	// model with composite primary key returns the affected rows instead of id.
 	Insert(interface{}) (int64, error)
+	InsertWithCtx(context.Context, interface{}) (int64, error)
 	// mysql:InsertOrUpdate(model) or InsertOrUpdate(model,"colu=colu+value")
 	// if colu type is integer : can use(+-*/), string : convert(colu,"value")
 	// postgres: InsertOrUpdate(model,"conflictColumnName") or InsertOrUpdate(model,"conflictColumnName","colu=colu+value")
 	// if colu type is integer : can use(+-*/), string : colu || "value"
+	// sqlite: same as postgres. the conflict columns of postgres and sqlite are the pk columns
+	// when model has composite primary key and no conflict column is given.
+	// BeforeInsert and AfterInsert hooks are called whether the row is inserted or updated.
 	InsertOrUpdate(md interface{}, colConflitAndArgs ...string) (int64, error)
+	InsertOrUpdateWithCtx(ctx context.Context, md interface{}, colConflitAndArgs ...string) (int64, error)
 	// insert some models to database
 	InsertMulti(bulk int, mds interface{}) (int64, error)
+	InsertMultiWithCtx(ctx context.Context, bulk int, mds interface{}) (int64, error)
+	// insert some models to database, the rows conflicting on conflictCols are updated with updateCols.
+	// mysql and tidb use ON DUPLICATE KEY UPDATE and ignore conflictCols,
+	// postgres and sqlite use ON CONFLICT (conflictCols) DO UPDATE, conflictCols default to composite primary key.
+	// default updateCols are all inserted columns except conflictCols, pk and auto_now_add fields,
+	// auto_now fields are always updated. it returns the affected rows of every bulk,
+	// mysql counts an updated row as 2. BeforeInsert and AfterInsert hooks are called like InsertMulti.
+	// for example:
+	//	nums, err = Ormer.InsertMultiOrUpdate(100, users, []string{"UserName"}, []string{"Email"})
+	InsertMultiOrUpdate(bulk int, mds interface{}, conflictCols, updateCols []string) ([]int64, error)
+	InsertMultiOrUpdateWithCtx(ctx context.Context, bulk int, mds interface{}, conflictCols, updateCols []string) ([]int64, error)
 	// update model to database.
 	// cols set the columns those want to update.
+	// the shard key of sharded model cannot be in cols, it returns ErrShardKeyUpdate.
 	// find model by Id(pk) field and update columns specified by fields, if cols is null then update all columns
 	// for example:
 	// user := User{Id: 2}
@@ -74,8 +97,11 @@ type Ormer interface {
 	//	user.Extra.Data = "orm"
 	//	num, err = Ormer.Update(&user, "Langs", "Extra")
 	Update(md interface{}, cols ...string) (int64, error)
-	// delete model in database
+	UpdateWithCtx(ctx context.Context, md interface{}, cols ...string) (int64, error)
+	// delete model in database.
+	// model with soft_delete field is marked as deleted, Read still reads it.
 	Delete(md interface{}, cols ...string) (int64, error)
+	DeleteWithCtx(ctx context.Context, md interface{}, cols ...string) (int64, error)
 	// load related models to md model.
 	// args are limit, offset int and order string.
 	//
@@ -89,17 +115,34 @@ type Ormer interface {
 	//args[3] string order  for example : "-Id"
 	// make sure the relation is defined in model struct tags.
 	LoadRelated(md interface{}, name string, args ...interface{}) (int64, error)
+	LoadRelatedWithCtx(ctx context.Context, md interface{}, name string, args ...interface{}) (int64, error)
 	// create a models to models queryer
 	// for example:
 	// 	post := Post{Id: 4}
 	// 	m2m := Ormer.QueryM2M(&post, "Tags")
 	QueryM2M(md interface{}, name string) QueryM2Mer
+	QueryM2MWithCtx(ctx context.Context, md interface{}, name string) QueryM2Mer
 	// return a QuerySeter for table operations.
 	// table name can be string or struct.
 	// e.g. QueryTable("user"), QueryTable(&user{}) or QueryTable((*User)(nil)),
 	QueryTable(ptrStructOrTableName interface{}) QuerySeter
 	// switch to another registered database driver by given name.
 	Using(name string) error
+	// return an Ormer whose reads skip the replicas of the alias and go to the primary,
+	// useful to read your own writes when replicas are registered.
+	// the returned Ormer is a copy of the origin one, it snapshots the database and the current transaction,
+	// call it after Begin to read in the transaction, and do not use it after Commit or Rollback.
+	// for example:
+	//	o.Insert(&user)
+	//	o.ForcePrimary().Read(&user)
+	ForcePrimary() Ormer
+	// return an Ormer bound to the tenant in the metadata of ctx, see TenantResolver.
+	// the tenant models use the tables of the tenant, and the context of another tenant returns ErrCrossTenant.
+	// for example:
+	//	ctx = metadata.NewContext(ctx, metadata.MD{metadata.Tenant: "t1"})
+	//	to := o.WithTenant(ctx)
+	//	err = to.Read(&order) // read from order_t1
+	WithTenant(ctx context.Context) Ormer
 	// begin transaction
 	// for example:
 	// 	o := NewOrm()
@@ -122,14 +165,71 @@ type Ormer interface {
 	Commit() error
 	// rollback transaction
 	Rollback() error
+	// run fn in transaction, commit when fn returns nil, rollback when it returns error or panics.
+	// DoTx can be nested by calling it on txOrm, the nested one runs in a savepoint,
+	// which is rolled back alone when the nested fn fails.
+	// for example:
+	//	err := o.DoTx(ctx, nil, func(txOrm orm.Ormer) error {
+	//		_, err := txOrm.Insert(&user)
+	//		return err
+	//	})
+	DoTx(ctx context.Context, opts *sql.TxOptions, fn TxFunc) error
+	// run fn in transaction like DoTx, the whole transaction is run again
+	// when it fails with deadlock or serialization failure, according to the policy.
+	// for example:
+	//	err := o.DoTxWithRetry(ctx, nil, orm.TxRetryPolicy{MaxRetries: 3, Backoff: 10 * time.Millisecond}, fn)
+	DoTxWithRetry(ctx context.Context, opts *sql.TxOptions, policy TxRetryPolicy, fn TxFunc) error
 	// return a raw query seter for raw sql string.
 	// for example:
 	//	 ormer.Raw("UPDATE `user` SET `user_name` = ? WHERE `user_name` = ?", "slene", "testing").Exec()
 	//	// update user testing's name to slene
+	// the placeholder {{table}} is replaced by the quoted table, or the table of tenant for tenant model.
+	// for example:
+	//	num, err = o.WithTenant(ctx).Raw("SELECT * FROM {{order}} WHERE amount > ?", 10).QueryRows(&orders)
 	Raw(query string, args ...interface{}) RawSeter
 	Driver() Driver
 }
 
+// BeforeInserter is implemented by models that need to run logic before being inserted,
+// such as filling audit fields or validation.
+// returning an error aborts the insert.
+type BeforeInserter interface {
+	BeforeInsert(ctx context.Context) error
+}
+
+// AfterInserter is implemented by models that need to run logic after being inserted.
+type AfterInserter interface {
+	AfterInsert(ctx context.Context) error
+}
+
+// BeforeUpdater is implemented by models that need to run logic before being updated.
+// returning an error aborts the update.
+type BeforeUpdater interface {
+	BeforeUpdate(ctx context.Context) error
+}
+
+// AfterUpdater is implemented by models that need to run logic after being updated.
+type AfterUpdater interface {
+	AfterUpdate(ctx context.Context) error
+}
+
+// BeforeDeleter is implemented by models that need to run logic before being deleted.
+// returning an error aborts the delete.
+type BeforeDeleter interface {
+	BeforeDelete(ctx context.Context) error
+}
+
+// AfterDeleter is implemented by models that need to run logic after being deleted.
+type AfterDeleter interface {
+	AfterDelete(ctx context.Context) error
+}
+
+// AfterReader is implemented by models that need to run logic after being read
+// by Ormer.Read or filled by QuerySeter.One/All.
+type AfterReader interface {
+	AfterRead(ctx context.Context) error
+}
+
 // Inserter insert prepared statement
 type Inserter interface {
 	Insert(interface{}) (int64, error)
@@ -146,6 +246,9 @@ type QuerySeter interface {
 	//	Filter("profile__Age", 28)
 	// 	 // time compare
 	//	qs.Filter("created", time.Now())
+	//	// json document of json or jsonb field
+	//	qs.Filter("Attrs__json__size__width__gt", 10)
+	//	qs.Filter("Tags__json_contains", "go")
 	Filter(string, ...interface{}) QuerySeter
 	// add raw sql to querySeter.
 	// for example:
@@ -187,6 +290,13 @@ type QuerySeter interface {
 	// for example:
 	//	qs.GroupBy("id")
 	GroupBy(exprs ...string) QuerySeter
+	// add aggregates Count, Sum, Avg, Min or Max to the columns of Values, ValuesList and ValuesFlat.
+	// the expression can be a related field, the default alias is like sum_amount,
+	// without exprs of Values only the group fields and aggregates are selected.
+	// for example:
+	//	qs.GroupBy("User__ID").Annotate("Count(ID) AS posts", "Max(Created)").OrderBy("-posts").Values(&maps)
+	//	// maps[0]["User__ID"], maps[0]["posts"], maps[0]["max_created"]
+	Annotate(exprs ...string) QuerySeter
 	// add ORDER expression.
 	// "column" means ASC, "-column" means DESC.
 	// for example:
@@ -201,16 +311,38 @@ type QuerySeter interface {
 	//	qs.RelatedSel("profile").One(&user)
 	//	user.Profile.Age = 32
 	RelatedSel(params ...interface{}) QuerySeter
+	// load the relation fields of the models queried by All and One.
+	// each relation level is loaded by IN (...) queries and set to the parent models,
+	// cascaded relations are separated by __.
+	// for example:
+	//	num, err = o.QueryTable("post").Prefetch("Tags", "User__Profile").All(&posts)
+	//	// posts[0].Tags and posts[0].User.Profile are loaded
+	Prefetch(names ...string) QuerySeter
 	// Set Distinct
 	// for example:
 	//  o.QueryTable("policy").Filter("Groups__Group__Users__User", user).
 	//    Distinct().
 	//    All(&permissions)
 	Distinct() QuerySeter
+	// query the rows after the cursor in the order of OrderBy, for keyset paging.
+	// call OrderBy before After, the primary key is added as the last order.
+	// empty cursor means the first page, invalid cursor returns ErrInvalidCursor when executed.
+	// for example:
+	//	qs = o.QueryTable("user").OrderBy("-created").After(cursor).Limit(20)
+	//	num, err = qs.All(&users)
+	//	next, err = qs.Cursor(users[len(users)-1])
+	After(cursor string) QuerySeter
+	// return the cursor of the model md for After, md is usually the last row of a page.
+	// the order fields of md must not be null.
+	Cursor(md interface{}) (string, error)
 	// set FOR UPDATE to query.
 	// for example:
 	//  o.QueryTable("user").Filter("uid", uid).ForUpdate().All(&users)
 	ForUpdate() QuerySeter
+	// query the primary even if the alias has read replicas.
+	// for example:
+	//	o.QueryTable("user").Filter("uid", uid).ForcePrimary().One(&user)
+	ForcePrimary() QuerySeter
 	// return QuerySeter execution result number
 	// for example:
 	//	num, err = qs.Filter("profile__age__gt", 28).Count()
@@ -231,7 +363,31 @@ type QuerySeter interface {
 	//for example:
 	//	num ,err = qs.Filter("user_name__in", "testing1", "testing2").Delete()
 	// 	//delete two user  who's name is testing1 or testing2
+	// rows of model with soft_delete field are only marked as deleted,
+	// use Unscoped().Delete() to remove them.
 	Delete() (int64, error)
+	// include the deleted rows of model with soft_delete field,
+	// the deleted rows are excluded by default.
+	// for example:
+	//	num, err = qs.Unscoped().Count()
+	Unscoped() QuerySeter
+	// query only the deleted rows of model with soft_delete field.
+	// for example:
+	//	num, err = qs.OnlyDeleted().All(&users)
+	OnlyDeleted() QuerySeter
+	// restore the deleted rows of model with soft_delete field.
+	// for example:
+	//	num, err = qs.Filter("id", 1).Restore()
+	Restore() (int64, error)
+	// query all shards of sharded model, see ShardRule.
+	// a query of sharded model runs on one shard with the exact condition of shard key,
+	// otherwise it returns ErrNoShardKey unless AllShards is set.
+	// counts and affected rows of the shards are summed, All merges the rows by OrderBy
+	// and applies Limit and Offset on the merged rows, Values concatenate the rows of the shards.
+	// PrepareInsert, Rows, Iterate, Aggregate, ToSQL and Explain return ErrShardFanOut on more than one shard.
+	// for example:
+	//	num, err = qs.AllShards().OrderBy("-amount").Limit(10).All(&orders)
+	AllShards() QuerySeter
 	// return a insert queryer.
 	// it can be used in times.
 	// example:
@@ -240,6 +396,38 @@ type QuerySeter interface {
 	//	num, err = i.Insert(&user2) // user table will add one record user2 at once
 	//	err = i.Close() //don't forget call Close
 	PrepareInsert() (Inserter, error)
+	// query rows with context and return a cursor scanning one row at a time.
+	// close the Rows after use.
+	// for example:
+	//	rows, err := qs.RelatedSel().Rows(ctx)
+	//	defer rows.Close()
+	//	var user User
+	//	for rows.Next() {
+	//		err = rows.Scan(&user)
+	//	}
+	//	err = rows.Err()
+	Rows(ctx context.Context, cols ...string) (Rows, error)
+	// query rows one at a time and call fn with the row, a ptr of model struct reused for every row.
+	// it stops and closes the rows when fn returns error, and returns the error.
+	// for example:
+	//	num, err = qs.Iterate(ctx, func(row interface{}) error {
+	//		user := row.(*User)
+	//		return w.Write(user)
+	//	})
+	Iterate(ctx context.Context, fn func(row interface{}) error, cols ...string) (int64, error)
+	// return the SELECT statement of All and its args without executing it,
+	// the statement uses the placeholders of driver.
+	// for example:
+	//	query, args, err := qs.Filter("profile__age__gt", 18).OrderBy("-id").ToSQL()
+	ToSQL(cols ...string) (string, []interface{}, error)
+	// run the EXPLAIN of database for the SELECT statement of All and return the plan,
+	// mysql, tidb, postgres and sqlite are supported.
+	// for example:
+	//	plan, err := qs.Filter("user_name", "slene").Explain(ctx)
+	//	for _, step := range plan.FullScans() {
+	//		log.Printf("full scan of table %s", step.Table)
+	//	}
+	Explain(ctx context.Context, cols ...string) (*QueryPlan, error)
 	// query all data and map to containers.
 	// cols means the columns when querying.
 	// for example:
@@ -271,6 +459,16 @@ type QuerySeter interface {
 	//	var list ParamsList
 	//	qs.ValuesFlat(&list, "UserName") // list[0] == "slene"
 	ValuesFlat(result *ParamsList, expr string) (int64, error)
+	// query the aggregates like Annotate and map to container.
+	// container can be ptr of Params, struct, []Params or struct slice,
+	// struct fields are matched by column tag, field name or snake field name.
+	// for example:
+	//	var stat struct {
+	//		Total int64   `orm:"column(total)"`
+	//		AvgAge float64
+	//	}
+	//	num, err = o.QueryTable("user_profile").Aggregate(&stat, "Count(*) AS total", "Avg(Age)")
+	Aggregate(container interface{}, exprs ...string) (int64, error)
 	// query all rows into map[string]interface with specify key and value column name.
 	// keyCol = "name", valueCol = "value"
 	// table data
@@ -293,6 +491,24 @@ type QuerySeter interface {
 	// 	Found int
 	// }
 	RowsToStruct(ptrStruct interface{}, keyCol, valueCol string) (int64, error)
+	// bind context to QuerySeter.
+	// all statements executed by the returned QuerySeter use ctx,
+	// so a canceled request or an expired deadline aborts the query.
+	// for example:
+	//	qs.WithCtx(ctx).Filter("UserName", "slene").All(&users)
+	WithCtx(ctx context.Context) QuerySeter
+}
+
+// Rows is the cursor of QuerySeter.Rows
+type Rows interface {
+	// prepare the next row, return false when no more rows or an error met
+	Next() bool
+	// scan current row into a ptr of model struct
+	Scan(md interface{}) error
+	// return the error met during iteration
+	Err() error
+	// close the rows
+	Close() error
 }
 
 // QueryM2Mer model to model query struct
@@ -322,6 +538,9 @@ type QueryM2Mer interface {
 	Clear() (int64, error)
 	// count all related models of origin model
 	Count() (int64, error)
+	// include the deleted rows of m2m table and related models with soft_delete field.
+	// the deleted related models are excluded by Exist and Count by default.
+	Unscoped() QueryM2Mer
 }
 
 // RawPreparer raw query statement
@@ -351,7 +570,30 @@ type RawSeter interface {
 	//	query = fmt.Sprintf("SELECT 'id','name' FROM %suser%s", Q, Q)
 	//	num, err = dORM.Raw(query).QueryRows(&ids,&names) // ids=>{1,2},names=>{"nobody","slene"}
 	QueryRows(containers ...interface{}) (int64, error)
+	// query data rows one at a time into the struct ptr container and call fn with it.
+	// the container is reused for every row, it stops when fn returns error.
+	// for example:
+	//	var user User
+	//	num, err = dORM.Raw("SELECT * FROM user").Iterate(ctx, &user, func(row interface{}) error {
+	//		return w.Write(&user)
+	//	})
+	Iterate(ctx context.Context, container interface{}, fn func(row interface{}) error) (int64, error)
 	SetArgs(...interface{}) RawSeter
+	// bind the named params like :name of raw sql to the fields of struct ptr,
+	// the name is the column name of field, the same as QueryRow maps the columns to struct.
+	// slice params are expanded for IN, the marks are replaced for the driver.
+	// the bound params replace the args of SetArgs, Prepare doesn't use them.
+	// for example:
+	//	filter := struct {
+	//		Status int
+	//		Ids    []int `orm:"column(ids)"`
+	//	}{1, []int{1, 2}}
+	//	num, err = dORM.Raw("SELECT * FROM user WHERE status = :status AND id IN (:ids)").BindStruct(&filter).QueryRows(&users)
+	BindStruct(ptrStruct interface{}) RawSeter
+	// bind the named params like :name of raw sql to the values of params, see BindStruct.
+	// for example:
+	//	num, err = dORM.Raw("SELECT * FROM user WHERE id IN (:ids)").BindMap(orm.Params{"ids": ids}).QueryRows(&users)
+	BindMap(params Params) RawSeter
 	// query data to []map[string]interface
 	// see QuerySeter's Values
 	Values(container *[]Params, cols ...string) (int64, error)
@@ -389,6 +631,10 @@ type RawSeter interface {
 	// 	pre, err := dORM.Raw("INSERT INTO tag (name) VALUES (?)").Prepare()
 	// 	r, err := pre.Exec("name1") // INSERT INTO tag (name) VALUES (`name1`)
 	Prepare() (RawPreparer, error)
+	// bind context to RawSeter.
+	// for example:
+	//	num, err = ormer.Raw("SELECT ...").WithCtx(ctx).QueryRows(&users)
+	WithCtx(ctx context.Context) RawSeter
 }
 
 // stmtQuerier statement querier
@@ -440,11 +686,13 @@ type dbBaser interface {
 	Insert(dbQuerier, *modelInfo, reflect.Value, *time.Location) (int64, error)
 	InsertOrUpdate(dbQuerier, *modelInfo, reflect.Value, *alias, ...string) (int64, error)
 	InsertMulti(dbQuerier, *modelInfo, reflect.Value, int, *time.Location) (int64, error)
+	InsertMultiOrUpdate(dbQuerier, *modelInfo, reflect.Value, int, *alias, []string, []string) ([]int64, error)
 	InsertValue(dbQuerier, *modelInfo, bool, []string, []interface{}) (int64, error)
 	InsertStmt(stmtQuerier, *modelInfo, reflect.Value, *time.Location) (int64, error)
 	Update(dbQuerier, *modelInfo, reflect.Value, *time.Location, []string) (int64, error)
 	Delete(dbQuerier, *modelInfo, reflect.Value, *time.Location, []string) (int64, error)
 	ReadBatch(dbQuerier, *querySet, *modelInfo, *Condition, interface{}, *time.Location, []string) (int64, error)
+	ReadRows(dbQuerier, *querySet, *modelInfo, *Condition, *time.Location, []string) (Rows, error)
 	SupportUpdateJoin() bool
 	UpdateBatch(dbQuerier, *querySet, *modelInfo, *Condition, Params, *time.Location) (int64, error)
 	DeleteBatch(dbQuerier, *querySet, *modelInfo, *Condition, *time.Location) (int64, error)
@@ -452,6 +700,8 @@ type dbBaser interface {
 	OperatorSQL(string) string
 	GenerateOperatorSQL(*modelInfo, *fieldInfo, string, []interface{}, *time.Location) (string, []interface{})
 	GenerateOperatorLeftCol(*fieldInfo, string, *string)
+	GenerateJSONPath(string, []string, []interface{}) string
+	GenerateJSONContains(string, []string) string
 	PrepareInsert(dbQuerier, *modelInfo) (stmtQuerier, string, error)
 	ReadValues(dbQuerier, *querySet, *modelInfo, *Condition, []string, interface{}, *time.Location) (int64, error)
 	RowsTo(dbQuerier, *querySet, *modelInfo, *Condition, interface{}, string, string, *time.Location) (int64, error)
@@ -467,6 +717,9 @@ type dbBaser interface {
 	ShowTablesQuery() string
 	ShowColumnsQuery(string) string
 	IndexExists(dbQuerier, string, string) bool
+	GetTableSchema(dbQuerier, string) (*tableSchema, error)
 	collectFieldValue(*modelInfo, *fieldInfo, reflect.Value, bool, *time.Location) (interface{}, error)
 	setval(dbQuerier, *modelInfo, []string) error
+	readBatchQuery(*querySet, *modelInfo, *Condition, *time.Location, []string) (string, []interface{}, []string, *dbTables, int, error)
+	explain(context.Context, dbQuerier, string, []interface{}) (*QueryPlan, error)
 }
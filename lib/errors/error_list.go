@@ -5,6 +5,7 @@ var (
 	ErrIDNotExistInDataBase    = New(1)
 	ErrQualifiedRecordNotFound = New(100)
 	ErrParameter               = New(400)
+	ErrConflict                = New(409) //记录已被其他请求修改,乐观锁版本冲突
 
 	ErrSystem = add(-1) //小于0的错误定义为系统错误，大于0的定义为业务错误
 )
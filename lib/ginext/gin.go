@@ -13,6 +13,7 @@ import (
 	"github.com/gopherchai/contrib/lib/metadata"
 
 	"github.com/gin-gonic/gin"
+	pkgerr "github.com/pkg/errors"
 )
 
 const (
@@ -83,7 +84,7 @@ func BadParameter(c *gin.Context, err error) {
 
 	if err != nil {
 
-		e, ok := err.(ecode.Codes)
+		e, ok := pkgerr.Cause(err).(ecode.Codes)
 		if ok {
 			resp.Code = e.Code()
 			resp.Msg = e.Message()
@@ -122,7 +123,7 @@ func RendResponse(c *gin.Context, err error, data interface{}) {
 	statusCode := http.StatusOK
 	if err != nil {
 		c.Error(err)
-		e, ok := err.(ecode.Codes)
+		e, ok := pkgerr.Cause(err).(ecode.Codes)
 		if !ok {
 			resp.Code = ecode.ErrSystem.Code()
 			resp.Msg = ecode.ErrSystem.Message()
@@ -134,6 +135,9 @@ func RendResponse(c *gin.Context, err error, data interface{}) {
 	if resp.Code < 0 {
 		statusCode = http.StatusBadGateway
 	}
+	if resp.Code == ecode.ErrConflict.Code() {
+		statusCode = http.StatusConflict
+	}
 
 	SetResp(c, resp)
 	c.JSON(statusCode, resp)
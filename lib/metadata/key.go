@@ -49,6 +49,10 @@ const (
 
 	// Criticality 重要性
 	Criticality = "criticality"
+
+	// Tenant 租户id,由服务的鉴权中间件根据认证后的身份设置
+	// 不随rpc传递,也不从上游的rpc metadata中读取,避免调用方伪造租户
+	Tenant = "tenant"
 )
 
 var outgoingKey = map[string]struct{}{
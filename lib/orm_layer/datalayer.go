@@ -29,6 +29,7 @@ const (
 	TableFieldCreatorUserId    = "creator_user_id"
 	TableFieldMaintainerUserId = "maintainer_user_id"
 	TableFieldId               = "id"
+	TableFieldVersion          = "version"
 	StructFieldID              = "Id"
 )
 
@@ -89,9 +90,15 @@ func NewDataLayer(dsn, alias, prefix string, maxIdleConns, maxOpenConns int, rdC
 	if err != nil {
 		return nil, err
 	}
+	return NewDataLayerWithOrmer(o, prefix, rdCli, models...), nil
+}
+
+//NewDataLayerWithOrmer 使用已注册的数据库别名和模型创建DataLayer,不注册驱动、数据库和模型
+//o为DataLayer默认使用的Ormer,rdCli为nil时不使用缓存,直接读写数据库
+func NewDataLayerWithOrmer(o orm.Ormer, prefix string, rdCli *redis.Client, models ...interface{}) *DataLayer {
 	dl := DataLayer{
 		redisKeyPrefix: prefix,
-		dbAlias:        alias,
+		dbAlias:        o.Driver().Name(),
 		rdCli:          rdCli,
 
 		globalModInfo: globalModInfo,
@@ -104,7 +111,7 @@ func NewDataLayer(dsn, alias, prefix string, maxIdleConns, maxOpenConns int, rdC
 	}
 	dl.registerTable(baseModels...)
 
-	return &dl, nil
+	return &dl
 }
 
 func InitDefaultDataLayer(dsn, alias, prefix string, maxIdleConns, maxOpenConns int, rdCli *redis.Client,
@@ -212,6 +219,9 @@ func (d *DataLayer) GetModByIDFromDB(id int64, mod base.BaseModel) error {
 }
 
 func (d *DataLayer) GetModByIDFromCacheOrDB(id int64, mod base.BaseModel) error {
+	if d.rdCli == nil {
+		return d.GetModByIDFromDB(id, mod)
+	}
 	key := getModCacheKeyWithID(d.redisKeyPrefix, getDbModCachedKeySuffixWithIDAndTableName(id, mod.TableName()))
 	res, err := d.rdCli.Get(key).Result()
 	if err != nil {
@@ -293,6 +303,31 @@ func (d *DataLayer) UpdateUndeletedModByIDAndDeleteCache(tableName string, id in
 	return int(num), nil
 }
 
+//UpdateUndeletedModByIDWithVersionAndDeleteCache 按乐观锁更新未删除的记录,version为读取时的版本,更新后版本加1
+//记录不存在或已删除时返回ErrIDNotExistInDataBase,版本不一致返回ErrConflict
+func (d *DataLayer) UpdateUndeletedModByIDWithVersionAndDeleteCache(tableName string, id int64, version int64, values map[string]interface{}, mainterUserId int) (int, error) {
+	delete(values, TableFieldCreateTime)
+	delete(values, TableFieldCreatorUserId)
+	values[TableFieldMaintainerUserId] = mainterUserId
+	values = d.getMatchedFilterByTableName(tableName, values)
+	values[TableFieldVersion] = orm.ColValue(orm.ColAdd, 1)
+	qs := d.globalOrmer.QueryTable(tableName).Filter(TableFieldId, id).Filter(TableFieldIsDeleted, false)
+	num, err := qs.Filter(TableFieldVersion, version).Update(orm.Params(values))
+	if err != nil {
+		return 0, pkgerr.Wrapf(localErr.ErrSystem, "error:%s with args:%+v", err, []interface{}{tableName, id, version, values})
+	}
+	if num == 0 {
+		//从主库确认记录是否存在,避免从库延迟把版本冲突误报为记录不存在
+		if !qs.ForcePrimary().Exist() {
+			return 0, pkgerr.Wrapf(localErr.ErrIDNotExistInDataBase, "id:%d not exist in table:%s", id, tableName)
+		}
+		return 0, pkgerr.Wrapf(localErr.ErrConflict, "version %d of %s:%d is stale", version, tableName, id)
+	}
+
+	go d.DeleteModCacheByID(id, tableName)
+	return int(num), nil
+}
+
 //UpdateModsWithFilter TODO avoid to update updateTime,createTime in values ;avoid update deleted record
 func (d *DataLayer) UpdateUndeletedModsWithFilter(filter map[string]interface{}, values map[string]interface{}, mainterUserId int64, tableName string) (int, error) {
 
@@ -334,6 +369,9 @@ func (d *DataLayer) GetUndeletedModByUniqueKeyFromDB(mod base.BaseModel, keyName
 }
 
 func (d *DataLayer) GetUndeletedModByUniqueKeyFromCacheOrDB(mod base.BaseModel, keyName string, keyValue interface{}) error {
+	if d.rdCli == nil {
+		return d.GetUndeletedModByUniqueKeyFromDB(mod, keyName, keyValue)
+	}
 
 	key := getModCacheKeyWithID(d.redisKeyPrefix, getDbModCachedKeyWithUniqueKey(keyName, keyValue, mod))
 	res, err := d.rdCli.Get(key).Result()
@@ -355,6 +393,9 @@ func (d *DataLayer) GetUndeletedModByUniqueKeyFromCacheOrDB(mod base.BaseModel,
 }
 
 func (d *DataLayer) DeleteModCacheByID(id int64, tableName string) error {
+	if d.rdCli == nil {
+		return nil
+	}
 
 	key := getModCacheKeyWithID(d.redisKeyPrefix, getDbModCachedKeySuffixWithIDAndTableName(id, tableName))
 	return d.rdCli.Del(key).Err()
@@ -459,6 +500,9 @@ func (d *DataLayer) GetNumberOfModsMatchWithFilter(o orm.Ormer, tableName string
 }
 
 func (dl *DataLayer) GetOneModWithFilterAndOrderFromCacheOrDB(o orm.Ormer, mod interface{}, tableName string, filters []map[string]interface{}, orders []string, duration time.Duration) error {
+	if dl.rdCli == nil {
+		return dl.GetOneModWithFilterAndOrder(o, mod, tableName, filters, orders)
+	}
 	m := map[string]interface{}{
 		"filters": filters,
 		"orders":  orders,
@@ -488,6 +532,9 @@ func (dl *DataLayer) GetOneModWithFilterAndOrderFromCacheOrDB(o orm.Ormer, mod i
 }
 
 func (dl *DataLayer) GetModWithIDFromCache(container interface{}, tableName string, id int64) (err error) {
+	if dl.rdCli == nil {
+		return pkgerr.Wrapf(localErr.ErrSystem, "get %s:%d from cache without redis client", tableName, id)
+	}
 	suffix := getDbModCachedKeySuffixWithIDAndTableName(id, tableName)
 	key := dl.redisKeyPrefix + "_" + suffix
 	data, err := dl.rdCli.Get(key).Bytes()
@@ -502,6 +549,9 @@ func (dl *DataLayer) GetModWithIDFromCache(container interface{}, tableName stri
 }
 
 func (dl *DataLayer) CacheModWithIdAndTableName(container interface{}, tableName string, id int64, duration time.Duration) (err error) {
+	if dl.rdCli == nil {
+		return nil
+	}
 	data, err := json.Marshal(container)
 	if err != nil {
 		return pkgerr.Wrapf(localErr.ErrSystem, "marshal mod:%+v table:%s meet error:%+v", container, tableName, err)
@@ -517,6 +567,9 @@ func (dl *DataLayer) CacheModWithIdAndTableName(container interface{}, tableName
 }
 
 func (dl *DataLayer) GetNumberOfModsMatchWithFilterFromCacheOrDB(o orm.Ormer, tableName string, filters []map[string]interface{}, duration time.Duration) (int, error) {
+	if dl.rdCli == nil {
+		return dl.GetNumberOfModsMatchWithFilter(o, tableName, filters)
+	}
 	key, err := dl.getCacheKeyWithFilter([]interface{}{filters}, tableName)
 	if err != nil {
 		return 0, err
@@ -574,6 +627,9 @@ func (dl *DataLayer) GetModsFromDb(o orm.Ormer, container interface{}, tableName
 }
 
 func (dl *DataLayer) cacheData(args []interface{}, tableName, value string, duration time.Duration) error {
+	if dl.rdCli == nil {
+		return nil
+	}
 	key, err := dl.getCacheKeyWithFilter(args, tableName)
 	if err != nil {
 		return err
@@ -600,6 +656,9 @@ func (dl *DataLayer) getCacheKeyWithFilter(args []interface{}, tableName string)
 }
 
 func (dl *DataLayer) GetModsFromCache(o orm.Ormer, container interface{}, tableName string, filter []map[string]interface{}, orders []string, pageNo, pageSize uint, columns []string) error {
+	if dl.rdCli == nil {
+		return pkgerr.Wrapf(localErr.ErrSystem, "get %s from cache without redis client", tableName)
+	}
 
 	args := []interface{}{filter, orders, pageNo, pageSize, columns}
 	key, err := dl.getCacheKeyWithFilter(args, tableName)
@@ -710,6 +769,51 @@ func (d *DataLayer) GetModsWithFilterAndOrderByPage(o orm.Ormer, mods interface{
 
 }
 
+//GetModsWithFilterAndOrderAfterCursor 按游标分页查询,cursor为空时查询第一页,返回下一页的游标,没有下一页时返回空字符串
+//mods参数必须是*[]*Type类型,orderFields为空时按id排序
+func (d *DataLayer) GetModsWithFilterAndOrderAfterCursor(o orm.Ormer, mods interface{}, tableName string, filters []map[string]interface{}, orderFields []string, cursor string, pageSize uint) (string, error) {
+	oo := d.globalOrmer
+	if o != nil {
+		oo = o
+	}
+	qs := oo.QueryTable(tableName)
+	for _, filter := range filters {
+		for k, v := range filter {
+			qs = qs.Filter(k, v)
+		}
+	}
+
+	if len(orderFields) > 0 {
+		qs = qs.OrderBy(orderFields...)
+	}
+	qs = qs.After(cursor).Limit(pageSize)
+	num, err := qs.All(mods)
+	if err == orm.ErrInvalidCursor {
+		return "", pkgerr.Wrapf(localErr.ErrParameter, "invalid cursor:%s", cursor)
+	}
+	if err != nil {
+		return "", pkgerr.Wrapf(localErr.ErrSystem, "query table :%s with args %+v meet error:%+v", tableName, []interface{}{
+			filters, orderFields, cursor, pageSize,
+		}, err)
+	}
+	if num == 0 || uint(num) < pageSize {
+		return "", nil
+	}
+
+	last := reflect.Indirect(reflect.ValueOf(mods)).Index(int(num) - 1).Interface()
+	next, err := qs.Cursor(last)
+	if err != nil {
+		return "", pkgerr.Wrapf(localErr.ErrSystem, "cursor of table :%s meet error:%+v", tableName, err)
+	}
+	return next, nil
+}
+
+//GetUndeletedModsWithFilterAfterCursor 按游标分页查询未删除的记录,返回下一页的游标
+func (d *DataLayer) GetUndeletedModsWithFilterAfterCursor(o orm.Ormer, mods interface{}, tableName string, filter map[string]interface{}, orderFields []string, cursor string, pageSize uint) (string, error) {
+	filter[TableFieldIsDeleted] = false
+	return d.GetModsWithFilterAndOrderAfterCursor(o, mods, tableName, []map[string]interface{}{filter}, orderFields, cursor, pageSize)
+}
+
 func (d *DataLayer) GetOneModWithFilterAndOrder(o orm.Ormer, mod interface{}, tableName string, filters []map[string]interface{}, ordersFields []string) error {
 	oo := d.globalOrmer
 	if o != nil {
@@ -778,6 +882,23 @@ func (d *DataLayer) Insert(o orm.Ormer, mod interface{}) (id int64, err error) {
 	return
 }
 
+//UpdateMod 按主键更新mod,mod有version字段时版本不一致返回ErrConflict,记录不存在时返回0
+func (d *DataLayer) UpdateMod(o orm.Ormer, mod base.BaseModel, cols ...string) (int64, error) {
+	oo := d.globalOrmer
+	if o != nil {
+		oo = o
+	}
+	num, err := oo.Update(mod, cols...)
+	if err == orm.ErrStaleObject {
+		return 0, pkgerr.Wrapf(localErr.ErrConflict, "update %s:%d meet error:%+v", mod.TableName(), mod.GetID(), err)
+	}
+	if err != nil {
+		return 0, pkgerr.Wrapf(localErr.ErrSystem, "update %s:%d meet error:%+v with cols:%+v", mod.TableName(), mod.GetID(), err, cols)
+	}
+	go d.DeleteModCacheByID(mod.GetID(), mod.TableName())
+	return num, nil
+}
+
 func getModCacheKeyWithID(service, idTableKey string) string {
 	return service + "_" + idTableKey
 }
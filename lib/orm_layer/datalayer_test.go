@@ -0,0 +1,90 @@
+package dao
+
+import (
+	"os"
+	"testing"
+
+	"github.com/gopherchai/contrib/lib/db/orm/ormtest"
+	localErr "github.com/gopherchai/contrib/lib/errors"
+	base "github.com/gopherchai/contrib/lib/model"
+	pkgerr "github.com/pkg/errors"
+)
+
+type Item struct {
+	Id      int64
+	Name    string `orm:"size(50);unique"`
+	Version int64
+	base.OrmCommon
+}
+
+func (i *Item) TableName() string {
+	return "item"
+}
+
+func (i *Item) SetID(id int64) {
+	i.Id = id
+}
+
+func (i *Item) GetID() int64 {
+	return i.Id
+}
+
+func TestMain(m *testing.M) {
+	if err := ormtest.Setup("default", new(Item)); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func TestDataLayerWithOrmer(t *testing.T) {
+	dl := NewDataLayerWithOrmer(ormtest.Begin(t, "default"), "test", nil, new(Item))
+
+	id, err := dl.Create(&Item{Name: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dl.Create(&Item{Name: "b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	item := new(Item)
+	if err := dl.GetModByIDFromCacheOrDB(id, item); err != nil {
+		t.Fatal(err)
+	}
+	if item.Name != "a" {
+		t.Fatalf("name %s, want a", item.Name)
+	}
+	if err := dl.GetUndeletedModByUniqueKeyFromCacheOrDB(item, "name", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if item.Name != "b" {
+		t.Fatalf("name %s, want b", item.Name)
+	}
+
+	num, err := dl.UpdateUndeletedModByIDWithVersionAndDeleteCache("item", id, 0, map[string]interface{}{"name": "c"}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if num != 1 {
+		t.Fatalf("updated %d, want 1", num)
+	}
+	_, err = dl.UpdateUndeletedModByIDWithVersionAndDeleteCache("item", id, 0, map[string]interface{}{"name": "d"}, 1)
+	if pkgerr.Cause(err) != localErr.ErrConflict {
+		t.Fatalf("error %v, want ErrConflict", err)
+	}
+	_, err = dl.UpdateUndeletedModByIDWithVersionAndDeleteCache("item", id+100, 1, map[string]interface{}{"name": "d"}, 1)
+	if pkgerr.Cause(err) != localErr.ErrIDNotExistInDataBase {
+		t.Fatalf("error %v, want ErrIDNotExistInDataBase", err)
+	}
+
+	if _, err := dl.DeleteSoftModWithID(id, "item", 1); err != nil {
+		t.Fatal(err)
+	}
+	cnt, err := dl.GetTotalUndeletedModNumByFilter(map[string]interface{}{}, "item")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cnt != 1 {
+		t.Fatalf("undeleted %d, want 1", cnt)
+	}
+}